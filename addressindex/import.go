@@ -0,0 +1,183 @@
+package addressindex
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ImportStats summarizes an import, skipped rows lack a street, a number or coordinates.
+type ImportStats struct {
+	Imported int
+	Skipped  int
+}
+
+var (
+	csvStreetColumns    = []string{"street", "addr:street", "strada"}
+	csvNumberColumns    = []string{"number", "housenumber", "addr:housenumber", "numar"}
+	csvLatitudeColumns  = []string{"latitude", "lat", "y"}
+	csvLongitudeColumns = []string{"longitude", "lon", "lng", "x"}
+)
+
+// ImportCSV reads a CSV file with a header row. Column names are matched case
+// insensitively, both plain names (street, number, lat, lon) and OSM tags
+// (addr:street, addr:housenumber) are accepted.
+func ImportCSV(r io.Reader, b *Builder) (ImportStats, error) {
+	var stats ImportStats
+
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return stats, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columnMap := make(map[string]int, len(header))
+	for i, col := range header {
+		columnMap[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+
+	streetCol, okStreet := findColumn(columnMap, csvStreetColumns)
+	numberCol, okNumber := findColumn(columnMap, csvNumberColumns)
+	latCol, okLat := findColumn(columnMap, csvLatitudeColumns)
+	lonCol, okLon := findColumn(columnMap, csvLongitudeColumns)
+	if !okStreet || !okNumber || !okLat || !okLon {
+		return stats, errors.New("CSV header must have street, number, latitude and longitude columns")
+	}
+
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("failed to read CSV row: %w", err)
+		}
+
+		if max(streetCol, numberCol, latCol, lonCol) >= len(row) {
+			stats.Skipped++
+			continue
+		}
+
+		lat, errLat := strconv.ParseFloat(strings.TrimSpace(row[latCol]), 64)
+		lon, errLon := strconv.ParseFloat(strings.TrimSpace(row[lonCol]), 64)
+		if errLat != nil || errLon != nil {
+			stats.Skipped++
+			continue
+		}
+
+		if b.Add(row[streetCol], row[numberCol], lat, lon) {
+			stats.Imported++
+		} else {
+			stats.Skipped++
+		}
+	}
+
+	return stats, nil
+}
+
+func findColumn(columnMap map[string]int, candidates []string) (int, bool) {
+	for _, c := range candidates {
+		if i, exists := columnMap[c]; exists {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+type geoJSONFeatureCollection struct {
+	Features []struct {
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+// ImportGeoJSON reads a FeatureCollection such as an OSM extract. Points are used
+// as is, polygons (buildings, blocks) are reduced to the average of their outer ring.
+func ImportGeoJSON(r io.Reader, b *Builder) (ImportStats, error) {
+	var stats ImportStats
+
+	var collection geoJSONFeatureCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return stats, fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+
+	for _, feature := range collection.Features {
+		streetName := propertyString(feature.Properties, "addr:street", "street")
+		number := propertyString(feature.Properties, "addr:housenumber", "housenumber", "number")
+
+		lon, lat, ok := representativePoint(feature.Geometry.Type, feature.Geometry.Coordinates)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+
+		if b.Add(streetName, number, lat, lon) {
+			stats.Imported++
+		} else {
+			stats.Skipped++
+		}
+	}
+
+	return stats, nil
+}
+
+func propertyString(properties map[string]any, keys ...string) string {
+	for _, k := range keys {
+		switch v := properties[k].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+func representativePoint(geometryType string, coordinates json.RawMessage) (lon, lat float64, ok bool) {
+	switch geometryType {
+	case "Point":
+		var point [2]float64
+		if err := json.Unmarshal(coordinates, &point); err != nil {
+			return 0, 0, false
+		}
+		return point[0], point[1], true
+	case "Polygon":
+		var rings [][][2]float64
+		if err := json.Unmarshal(coordinates, &rings); err != nil || len(rings) == 0 {
+			return 0, 0, false
+		}
+		return ringAverage(rings[0])
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err := json.Unmarshal(coordinates, &polygons); err != nil || len(polygons) == 0 || len(polygons[0]) == 0 {
+			return 0, 0, false
+		}
+		return ringAverage(polygons[0][0])
+	default:
+		return 0, 0, false
+	}
+}
+
+func ringAverage(ring [][2]float64) (lon, lat float64, ok bool) {
+	// the closing point repeats the first one
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) == 0 {
+		return 0, 0, false
+	}
+	for _, p := range ring {
+		lon += p[0]
+		lat += p[1]
+	}
+	return lon / float64(len(ring)), lat / float64(len(ring)), true
+}
@@ -0,0 +1,335 @@
+package addressindex
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+var ErrStreetNotFound = errors.New("street not found")
+var ErrInvalidIndex = errors.New("invalid address index")
+
+const (
+	indexMagic   = "TADX"
+	indexVersion = 1
+
+	maxStringLength = 1024
+)
+
+// Address is a located street number.
+type Address struct {
+	Street    string
+	Number    string
+	Latitude  float64
+	Longitude float64
+}
+
+type street struct {
+	name      string
+	addresses []Address // sorted by number
+}
+
+// Index maps normalized street names and numbers to coordinates.
+type Index struct {
+	streets []street // sorted by name
+}
+
+// Match is the result of an address lookup.
+type Match struct {
+	Address Address
+	// Exact is false when the number was not found and the closest number
+	// on the same street was used instead.
+	Exact bool
+}
+
+// Builder accumulates addresses before freezing them into an Index.
+type Builder struct {
+	streets map[string]map[string]Address
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		streets: make(map[string]map[string]Address, 4096),
+	}
+}
+
+// Add registers an address, it returns false if the street or number is empty
+// once normalized. Duplicated addresses keep the last coordinates.
+func (b *Builder) Add(streetName, number string, latitude, longitude float64) bool {
+	s := NormalizeStreet(streetName)
+	n := NormalizeNumber(number)
+	if s == "" || n == "" {
+		return false
+	}
+	if _, exists := b.streets[s]; !exists {
+		b.streets[s] = make(map[string]Address, 16)
+	}
+	b.streets[s][n] = Address{
+		Street:    s,
+		Number:    n,
+		Latitude:  roundCoordinate(latitude),
+		Longitude: roundCoordinate(longitude),
+	}
+	return true
+}
+
+func (b *Builder) Build() *Index {
+	idx := &Index{
+		streets: make([]street, 0, len(b.streets)),
+	}
+	for name, numbers := range b.streets {
+		st := street{
+			name:      name,
+			addresses: make([]Address, 0, len(numbers)),
+		}
+		for _, addr := range numbers {
+			st.addresses = append(st.addresses, addr)
+		}
+		slices.SortFunc(st.addresses, compareAddresses)
+		idx.streets = append(idx.streets, st)
+	}
+	slices.SortFunc(idx.streets, func(a, b street) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return idx
+}
+
+func compareAddresses(a, b Address) int {
+	na, _ := leadingNumber(a.Number)
+	nb, _ := leadingNumber(b.Number)
+	if na != nb {
+		return na - nb
+	}
+	return strings.Compare(a.Number, b.Number)
+}
+
+// NumAddresses returns the total number of addresses in the index.
+func (idx *Index) NumAddresses() int {
+	total := 0
+	for _, st := range idx.streets {
+		total += len(st.addresses)
+	}
+	return total
+}
+
+// Lookup finds the coordinates of a street number. Streets are matched on their
+// normalized name, then on the shortest name containing the query. When the number
+// is unknown, the address with the closest numeric value on the same side of the
+// street is returned.
+func (idx *Index) Lookup(streetName, number string) (Match, error) {
+	st, found := idx.findStreet(NormalizeStreet(streetName))
+	if !found {
+		return Match{}, ErrStreetNotFound
+	}
+
+	n := NormalizeNumber(number)
+	for _, addr := range st.addresses {
+		if addr.Number == n {
+			return Match{Address: addr, Exact: true}, nil
+		}
+	}
+
+	wanted, isNumeric := leadingNumber(n)
+	if !isNumeric {
+		return Match{Address: st.addresses[0]}, nil
+	}
+
+	best := st.addresses[0]
+	bestScore := math.MaxInt
+	for _, addr := range st.addresses {
+		value, ok := leadingNumber(addr.Number)
+		if !ok {
+			continue
+		}
+		score := abs(value - wanted)
+		// odd and even numbers are on opposite sides of the street
+		if value%2 != wanted%2 {
+			score += 1 << 20
+		}
+		if score < bestScore {
+			best, bestScore = addr, score
+		}
+	}
+
+	return Match{Address: best}, nil
+}
+
+func (idx *Index) findStreet(name string) (street, bool) {
+	if name == "" {
+		return street{}, false
+	}
+
+	i, found := slices.BinarySearchFunc(idx.streets, name, func(s street, target string) int {
+		return strings.Compare(s.name, target)
+	})
+	if found {
+		return idx.streets[i], true
+	}
+
+	var best street
+	for _, st := range idx.streets {
+		if strings.Contains(st.name, name) && (best.name == "" || len(st.name) < len(best.name)) {
+			best = st
+		}
+	}
+	return best, best.name != ""
+}
+
+// WriteTo serializes the index to its gzip compressed binary format.
+// Coordinates are stored as integer microdegrees.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	gz := gzip.NewWriter(counter)
+	bw := bufio.NewWriter(gz)
+
+	bw.WriteString(indexMagic)
+	writeUvarint(bw, indexVersion)
+	writeUvarint(bw, uint64(len(idx.streets)))
+	for _, st := range idx.streets {
+		writeString(bw, st.name)
+		writeUvarint(bw, uint64(len(st.addresses)))
+		for _, addr := range st.addresses {
+			writeString(bw, addr.Number)
+			writeVarint(bw, int64(math.Round(addr.Latitude*1e6)))
+			writeVarint(bw, int64(math.Round(addr.Longitude*1e6)))
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return counter.n, err
+	}
+	if err := gz.Close(); err != nil {
+		return counter.n, err
+	}
+	return counter.n, nil
+}
+
+// ReadIndex loads an index serialized by WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+	}
+	defer gz.Close()
+	br := bufio.NewReader(gz)
+
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != indexMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidIndex)
+	}
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+	}
+	if version != indexVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidIndex, version)
+	}
+
+	numStreets, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+	}
+
+	idx := &Index{
+		streets: make([]street, 0, min(numStreets, 1<<16)),
+	}
+	for range numStreets {
+		name, err := readString(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+		}
+		numAddresses, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+		}
+		// lookups fall back on the first address of the street
+		if numAddresses == 0 {
+			return nil, fmt.Errorf("%w: street %q without addresses", ErrInvalidIndex, name)
+		}
+		st := street{
+			name:      name,
+			addresses: make([]Address, 0, min(numAddresses, 1<<12)),
+		}
+		for range numAddresses {
+			number, err := readString(br)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+			}
+			lat, err := binary.ReadVarint(br)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+			}
+			lon, err := binary.ReadVarint(br)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidIndex, err)
+			}
+			st.addresses = append(st.addresses, Address{
+				Street:    name,
+				Number:    number,
+				Latitude:  float64(lat) / 1e6,
+				Longitude: float64(lon) / 1e6,
+			})
+		}
+		idx.streets = append(idx.streets, st)
+	}
+
+	return idx, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func writeUvarint(w *bufio.Writer, v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func writeVarint(w *bufio.Writer, v int64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutVarint(buf, v)])
+}
+
+func writeString(w *bufio.Writer, s string) {
+	writeUvarint(w, uint64(len(s)))
+	w.WriteString(s)
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if length > maxStringLength {
+		return "", errors.New("string too long")
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func roundCoordinate(c float64) float64 {
+	return math.Round(c*1e6) / 1e6
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,178 @@
+package addressindex
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeStreet(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "Str. Ion Creangă", want: "ion creanga"},
+		{input: "ion creanga", want: "ion creanga"},
+		{input: "Bd. Ștefan cel Mare", want: "stefan cel mare"},
+		{input: "Bulevardul  Iuliu   Maniu", want: "iuliu maniu"},
+		{input: "Calea", want: "calea"},
+		{input: "Șos. Pantelimon", want: "pantelimon"},
+		{input: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeStreet(tt.input); got != tt.want {
+				t.Errorf("NormalizeStreet(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "12", want: "12"},
+		{input: "12 A", want: "12a"},
+		{input: "12-14", want: "1214"},
+		{input: " 7b ", want: "7b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeNumber(tt.input); got != tt.want {
+				t.Errorf("NormalizeNumber(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func buildTestIndex(t *testing.T) *Index {
+	t.Helper()
+
+	csvData := `street,housenumber,lat,lon
+Strada Ion Creangă,10,44.4301,26.1001
+Strada Ion Creangă,12,44.4302,26.1002
+Strada Ion Creangă,11,44.4311,26.1011
+Strada Ion Creangă,15A,44.4315,26.1015
+Bulevardul Iuliu Maniu,7,44.4350,26.0300
+Bulevardul Iuliu Maniu,,44.4350,26.0300
+Bulevardul Iuliu Maniu,9,not-a-number,26.0300
+`
+	b := NewBuilder()
+	stats, err := ImportCSV(strings.NewReader(csvData), b)
+	if err != nil {
+		t.Fatalf("ImportCSV() error = %v", err)
+	}
+	if stats.Imported != 5 || stats.Skipped != 2 {
+		t.Fatalf("ImportCSV() stats = %+v, want 5 imported and 2 skipped", stats)
+	}
+	return b.Build()
+}
+
+func TestLookup(t *testing.T) {
+	idx := buildTestIndex(t)
+
+	tests := []struct {
+		name       string
+		street     string
+		number     string
+		wantNumber string
+		wantExact  bool
+		wantErr    error
+	}{
+		{name: "exact match", street: "ion creanga", number: "12", wantNumber: "12", wantExact: true},
+		{name: "diacritics and street type", street: "Str. Ion Creangă", number: "10", wantNumber: "10", wantExact: true},
+		{name: "letter suffix", street: "Ion Creanga", number: "15 A", wantNumber: "15a", wantExact: true},
+		{name: "closest number on the same side", street: "Ion Creanga", number: "13", wantNumber: "11"},
+		{name: "partial street name", street: "Maniu", number: "7", wantNumber: "7", wantExact: true},
+		{name: "unknown street", street: "Victoriei", number: "1", wantErr: ErrStreetNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := idx.Lookup(tt.street, tt.number)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Lookup() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if match.Address.Number != tt.wantNumber {
+				t.Errorf("Lookup() number = %q, want %q", match.Address.Number, tt.wantNumber)
+			}
+			if match.Exact != tt.wantExact {
+				t.Errorf("Lookup() exact = %v, want %v", match.Exact, tt.wantExact)
+			}
+		})
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	idx := buildTestIndex(t)
+
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	loaded, err := ReadIndex(&buf)
+	if err != nil {
+		t.Fatalf("ReadIndex() error = %v", err)
+	}
+	if loaded.NumAddresses() != idx.NumAddresses() {
+		t.Fatalf("loaded %d addresses, want %d", loaded.NumAddresses(), idx.NumAddresses())
+	}
+
+	match, err := loaded.Lookup("ion creanga", "11")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if match.Address.Latitude != 44.4311 || match.Address.Longitude != 26.1011 {
+		t.Errorf("Lookup() coordinates = %f,%f, want 44.4311,26.1011", match.Address.Latitude, match.Address.Longitude)
+	}
+
+	if _, err := ReadIndex(strings.NewReader("garbage")); !errors.Is(err, ErrInvalidIndex) {
+		t.Errorf("ReadIndex() on garbage error = %v, want %v", err, ErrInvalidIndex)
+	}
+
+	buf.Reset()
+	empty := &Index{streets: []street{{name: "ion creanga"}}}
+	if _, err := empty.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if _, err := ReadIndex(&buf); !errors.Is(err, ErrInvalidIndex) {
+		t.Errorf("ReadIndex() on a street without addresses error = %v, want %v", err, ErrInvalidIndex)
+	}
+}
+
+func TestImportGeoJSON(t *testing.T) {
+	geojson := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","geometry":{"type":"Point","coordinates":[26.1,44.4]},"properties":{"addr:street":"Strada Lipscani","addr:housenumber":"5"}},
+		{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[26.0,44.0],[26.2,44.0],[26.2,44.2],[26.0,44.2],[26.0,44.0]]]},"properties":{"addr:street":"Strada Lipscani","addr:housenumber":"6"}},
+		{"type":"Feature","geometry":{"type":"LineString","coordinates":[[26.0,44.0],[26.2,44.0]]},"properties":{"addr:street":"Strada Lipscani","addr:housenumber":"8"}}
+	]}`
+
+	b := NewBuilder()
+	stats, err := ImportGeoJSON(strings.NewReader(geojson), b)
+	if err != nil {
+		t.Fatalf("ImportGeoJSON() error = %v", err)
+	}
+	if stats.Imported != 2 || stats.Skipped != 1 {
+		t.Fatalf("ImportGeoJSON() stats = %+v, want 2 imported and 1 skipped", stats)
+	}
+
+	match, err := b.Build().Lookup("lipscani", "6")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if match.Address.Latitude != 44.1 || match.Address.Longitude != 26.1 {
+		t.Errorf("polygon centroid = %f,%f, want 44.1,26.1", match.Address.Latitude, match.Address.Longitude)
+	}
+}
@@ -0,0 +1,77 @@
+package addressindex
+
+import (
+	"strings"
+	"unicode"
+)
+
+var diacriticsReplacer = strings.NewReplacer(
+	"ă", "a", "â", "a", "î", "i", "ș", "s", "ş", "s", "ț", "t", "ţ", "t",
+	"Ă", "a", "Â", "a", "Î", "i", "Ș", "s", "Ş", "s", "Ț", "t", "Ţ", "t",
+)
+
+// street type words that residents often omit or abbreviate
+var streetTypeWords = map[string]bool{
+	"strada":     true,
+	"str":        true,
+	"bulevardul": true,
+	"bulevard":   true,
+	"bd":         true,
+	"bdul":       true,
+	"blvd":       true,
+	"calea":      true,
+	"cal":        true,
+	"soseaua":    true,
+	"sos":        true,
+	"aleea":      true,
+	"al":         true,
+	"intrarea":   true,
+	"intr":       true,
+	"piata":      true,
+	"pta":        true,
+	"splaiul":    true,
+	"spl":        true,
+	"drumul":     true,
+	"dr":         true,
+}
+
+// NormalizeStreet lowercases the street name, removes diacritics, punctuation
+// and the street type so that "Str. Ion Creangă" and "ion creanga" match.
+func NormalizeStreet(street string) string {
+	words := splitWords(street)
+	kept := make([]string, 0, len(words))
+	for i, w := range words {
+		// a name made only of a type word (eg: "Calea") is kept as is
+		if i == 0 && len(words) > 1 && streetTypeWords[w] {
+			continue
+		}
+		kept = append(kept, w)
+	}
+	return strings.Join(kept, " ")
+}
+
+// NormalizeNumber lowercases the street number and strips spaces and
+// punctuation, "12 A" becomes "12a".
+func NormalizeNumber(number string) string {
+	return strings.Join(splitWords(number), "")
+}
+
+// leadingNumber returns the numeric prefix of a normalized street number, "12a" gives 12.
+func leadingNumber(number string) (int, bool) {
+	n, found := 0, false
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			break
+		}
+		n = n*10 + int(r-'0')
+		found = true
+	}
+	return n, found
+}
+
+func splitWords(s string) []string {
+	s = strings.ToLower(diacriticsReplacer.Replace(s))
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,67 @@
+// Package cloudevents encodes the domain events of the system in the
+// CloudEvents 1.0 JSON format and publishes them to a pluggable sink.
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	SpecVersion = "1.0"
+	// BatchContentType is the content type of a JSON array of events
+	BatchContentType = "application/cloudevents-batch+json"
+)
+
+// Sink kinds, as set in the EVENTS_SINK environment variable. The target of a
+// sink is the path of the file, the URL posted to, the ARN of the topic or the
+// name of the event bus.
+const (
+	SinkNone        = "none"
+	SinkStdout      = "stdout"
+	SinkFile        = "file"
+	SinkHTTP        = "http"
+	SinkSNS         = "sns"
+	SinkEventBridge = "eventbridge"
+)
+
+// Event is a CloudEvent with a JSON payload.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// New returns the event of type about the subject at a time. Its id is
+// derived from them, so an event built twice, such as by a retried run, is
+// recognized by the consumers.
+func New(source, eventType, subject string, at time.Time, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to marshal %s event data: %w", eventType, err)
+	}
+	return Event{
+		SpecVersion:     SpecVersion,
+		Id:              eventType + ":" + subject + ":" + strconv.FormatInt(at.Unix(), 10),
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            at.UTC(),
+		DataContentType: "application/json",
+		Data:            payload,
+	}, nil
+}
+
+// Publisher delivers events. Publish returns an error unless every event was
+// accepted by the sink, the events may then have been delivered in part.
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+}
@@ -0,0 +1,163 @@
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+func newTestEvents(t *testing.T, n int) []Event {
+	t.Helper()
+	events := make([]Event, n)
+	for i := range events {
+		event, err := New("/test", "ro.test.happened", "things/"+string(rune('a'+i)), time.Unix(1000, 0), map[string]int{"n": i})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		events[i] = event
+	}
+	return events
+}
+
+func TestNew(t *testing.T) {
+	at := time.Date(2025, 1, 10, 12, 0, 0, 0, time.FixedZone("EET", 2*3600))
+	first, err := New("/test", "ro.test.happened", "things/1", at, map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	second, _ := New("/test", "ro.test.happened", "things/1", at, map[string]string{"a": "c"})
+	if first.Id != second.Id {
+		t.Errorf("ids = %s and %s, want the same id for the same type, subject and time", first.Id, second.Id)
+	}
+
+	encoded, err := json.Marshal(first)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"specversion":"1.0","id":"ro.test.happened:things/1:1736503200","source":"/test","type":"ro.test.happened",` +
+		`"subject":"things/1","time":"2025-01-10T10:00:00Z","datacontenttype":"application/json","data":{"a":"b"}}`
+	if string(encoded) != want {
+		t.Errorf("encoded event = %s, want %s", encoded, want)
+	}
+
+	if _, err := New("/test", "ro.test.happened", "things/1", at, func() {}); err == nil {
+		t.Error("New() with unmarshalable data succeeded")
+	}
+}
+
+func TestWriterPublisher(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := NewWriterPublisher(buffer).Publish(context.Background(), newTestEvents(t, 2)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"subject":"things/b"`) {
+		t.Errorf("written lines = %q, want one line per event", lines)
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "accepted", status: http.StatusAccepted},
+		{name: "refused", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []Event
+			var contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &received)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewHTTPPublisher(server.URL, server.Client()).Publish(context.Background(), newTestEvents(t, 3))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if contentType != BatchContentType || len(received) != 3 {
+				t.Errorf("received %d events as %q, want 3 as %q", len(received), contentType, BatchContentType)
+			}
+		})
+	}
+}
+
+type fakeSNSClient struct {
+	batches [][]snstypes.PublishBatchRequestEntry
+	failed  []snstypes.BatchResultErrorEntry
+}
+
+func (c *fakeSNSClient) PublishBatch(ctx context.Context, input *sns.PublishBatchInput, optFns ...func(*sns.Options)) (*sns.PublishBatchOutput, error) {
+	c.batches = append(c.batches, input.PublishBatchRequestEntries)
+	return &sns.PublishBatchOutput{Failed: c.failed}, nil
+}
+
+func TestSNSPublisher(t *testing.T) {
+	client := &fakeSNSClient{}
+	publisher := NewSNSPublisher(client, "arn:aws:sns:eu-central-1:123456789012:events")
+	if err := publisher.Publish(context.Background(), newTestEvents(t, 12)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(client.batches) != 2 || len(client.batches[0]) != 10 || len(client.batches[1]) != 2 {
+		t.Fatalf("published batches = %d, want 10 then 2 events", len(client.batches))
+	}
+	if aws.ToString(client.batches[1][0].MessageAttributes["type"].StringValue) != "ro.test.happened" {
+		t.Errorf("type attribute = %+v, want the event type", client.batches[1][0].MessageAttributes)
+	}
+
+	client.failed = []snstypes.BatchResultErrorEntry{{Id: aws.String("0"), Code: aws.String("InternalError")}}
+	if err := publisher.Publish(context.Background(), newTestEvents(t, 1)); err == nil {
+		t.Error("Publish() with a failed entry succeeded")
+	}
+}
+
+type fakeEventBridgeClient struct {
+	entries []eventbridgetypes.PutEventsRequestEntry
+	fail    bool
+}
+
+func (c *fakeEventBridgeClient) PutEvents(ctx context.Context, input *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	c.entries = append(c.entries, input.Entries...)
+	output := &eventbridge.PutEventsOutput{Entries: make([]eventbridgetypes.PutEventsResultEntry, len(input.Entries))}
+	if c.fail {
+		output.FailedEntryCount = 1
+		output.Entries[0].ErrorCode = aws.String("ThrottlingException")
+	}
+	return output, nil
+}
+
+func TestEventBridgePublisher(t *testing.T) {
+	client := &fakeEventBridgeClient{}
+	publisher := NewEventBridgePublisher(client, "termoficare")
+	if err := publisher.Publish(context.Background(), newTestEvents(t, 3)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	entry := client.entries[0]
+	if len(client.entries) != 3 || aws.ToString(entry.DetailType) != "ro.test.happened" || aws.ToString(entry.EventBusName) != "termoficare" {
+		t.Errorf("entries = %+v, want 3 entries on the bus with the event type", client.entries)
+	}
+
+	client.fail = true
+	err := publisher.Publish(context.Background(), newTestEvents(t, 1))
+	if err == nil || !strings.Contains(err.Error(), "ThrottlingException") {
+		t.Errorf("Publish() error = %v, want the failed entry code", err)
+	}
+}
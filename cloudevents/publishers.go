@@ -0,0 +1,189 @@
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// maximum number of entries of a PublishBatch or PutEvents call
+const awsBatchMaxEntries = 10
+
+// WriterPublisher writes a JSON line per event, to stdout or to a file.
+type WriterPublisher struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterPublisher(writer io.Writer) *WriterPublisher {
+	return &WriterPublisher{writer: writer}
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, events []Event) error {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to marshal event %s: %w", event.Id, err)
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	_, err := p.writer.Write(buffer.Bytes())
+	return err
+}
+
+// HTTPPublisher posts the events to a URL in the batched JSON format, a
+// status other than 2xx fails the whole batch.
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string, client *http.Client) *HTTPPublisher {
+	return &HTTPPublisher{url: url, client: client}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", BatchContentType)
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to post events: %w", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("event sink answered with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// SNSClient is the part of the SNS client the publisher uses.
+type SNSClient interface {
+	PublishBatch(ctx context.Context, input *sns.PublishBatchInput, optFns ...func(*sns.Options)) (*sns.PublishBatchOutput, error)
+}
+
+// SNSPublisher publishes every event as a message of a topic, with its type as
+// the "type" message attribute so subscriptions can filter on it.
+type SNSPublisher struct {
+	client   SNSClient
+	topicArn string
+}
+
+func NewSNSPublisher(client SNSClient, topicArn string) *SNSPublisher {
+	return &SNSPublisher{client: client, topicArn: topicArn}
+}
+
+func (p *SNSPublisher) Publish(ctx context.Context, events []Event) error {
+	for start := 0; start < len(events); start += awsBatchMaxEntries {
+		chunk := events[start:min(start+awsBatchMaxEntries, len(events))]
+
+		entries := make([]snstypes.PublishBatchRequestEntry, 0, len(chunk))
+		for i, event := range chunk {
+			message, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal event %s: %w", event.Id, err)
+			}
+			entries = append(entries, snstypes.PublishBatchRequestEntry{
+				// only unique within the batch, the event ids have forbidden characters
+				Id:      aws.String(strconv.Itoa(i)),
+				Message: aws.String(string(message)),
+				MessageAttributes: map[string]snstypes.MessageAttributeValue{
+					"type": {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+				},
+			})
+		}
+
+		output, err := p.client.PublishBatch(ctx, &sns.PublishBatchInput{
+			TopicArn:                   aws.String(p.topicArn),
+			PublishBatchRequestEntries: entries,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to publish events to %s: %w", p.topicArn, err)
+		}
+		if len(output.Failed) > 0 {
+			failure := output.Failed[0]
+			return fmt.Errorf("%d events refused by %s, first one with %s: %s",
+				len(output.Failed), p.topicArn, aws.ToString(failure.Code), aws.ToString(failure.Message))
+		}
+	}
+	return nil
+}
+
+// EventBridgeClient is the part of the EventBridge client the publisher uses.
+type EventBridgeClient interface {
+	PutEvents(ctx context.Context, input *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// EventBridgePublisher puts the events on a bus, with their type as the detail
+// type and the whole event as the detail.
+type EventBridgePublisher struct {
+	client  EventBridgeClient
+	busName string
+}
+
+func NewEventBridgePublisher(client EventBridgeClient, busName string) *EventBridgePublisher {
+	return &EventBridgePublisher{client: client, busName: busName}
+}
+
+func (p *EventBridgePublisher) Publish(ctx context.Context, events []Event) error {
+	for start := 0; start < len(events); start += awsBatchMaxEntries {
+		chunk := events[start:min(start+awsBatchMaxEntries, len(events))]
+
+		entries := make([]eventbridgetypes.PutEventsRequestEntry, 0, len(chunk))
+		for _, event := range chunk {
+			detail, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal event %s: %w", event.Id, err)
+			}
+			entries = append(entries, eventbridgetypes.PutEventsRequestEntry{
+				EventBusName: aws.String(p.busName),
+				Source:       aws.String(event.Source),
+				DetailType:   aws.String(event.Type),
+				Detail:       aws.String(string(detail)),
+				Time:         aws.Time(event.Time),
+			})
+		}
+
+		output, err := p.client.PutEvents(ctx, &eventbridge.PutEventsInput{Entries: entries})
+		if err != nil {
+			return fmt.Errorf("failed to put events on %s: %w", p.busName, err)
+		}
+		if output.FailedEntryCount > 0 {
+			return fmt.Errorf("%d events refused by %s: %w", output.FailedEntryCount, p.busName, firstEventBridgeFailure(output.Entries))
+		}
+	}
+	return nil
+}
+
+func firstEventBridgeFailure(entries []eventbridgetypes.PutEventsResultEntry) error {
+	for _, entry := range entries {
+		if entry.ErrorCode != nil {
+			return fmt.Errorf("%s: %s", aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage))
+		}
+	}
+	return errors.New("no error given")
+}
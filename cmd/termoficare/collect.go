@@ -0,0 +1,187 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/etl"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/scrape"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+)
+
+// collectOptions are the schedule of the collector, the pipeline is configured
+// like the ETL function.
+type collectOptions struct {
+	rawDir           string
+	interval         time.Duration
+	incidentInterval time.Duration
+	runTimeout       time.Duration
+}
+
+// runCollect runs the ETL on its own schedule until interrupted, polling more
+// often while stations have an open incident, and prints a status line after
+// each run. Snapshots breaking the plausibility rules are quarantined unless
+// -force-accept is set. With -dry-run, a single run is compared with the store
+// and its report is printed as JSON.
+func runCollect(ctx context.Context, args []string) error {
+	var sf sourceFlags
+	var opts collectOptions
+	flags := flag.NewFlagSet("collect", flag.ExitOnError)
+	sf.register(flags)
+	flags.StringVar(&opts.rawDir, "raw-dir", "", "directory to archive the raw map pages to, disabled when empty")
+	flags.DurationVar(&opts.interval, "interval", 30*time.Minute, "polling interval when no incident is open")
+	flags.DurationVar(&opts.incidentInterval, "incident-interval", 10*time.Minute, "polling interval while incidents are open or after a failed run")
+	flags.DurationVar(&opts.runTimeout, "run-timeout", 2*time.Minute, "maximum duration of a run")
+	dryRun := flags.Bool("dry-run", false, "run once and print what would be written instead of writing it")
+	forceAccept := flags.Bool("force-accept", false, "write the snapshots breaking the plausibility rules instead of quarantining them")
+	flags.Parse(args)
+
+	if opts.interval <= 0 || opts.incidentInterval <= 0 {
+		return errors.New("polling intervals must be positive")
+	}
+
+	source, err := sf.source()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadETL(source)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := tracing.Setup("etl", cfg.TracingExporter, cfg.OTLPEndpoint); err != nil {
+		return err
+	}
+
+	// the collector prints its outcome instead of metrics
+	pipeline, err := scrape.NewPipeline(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+	pipeline.ForceAccept = cfg.ForceAccept || *forceAccept
+
+	if cfg.DryRun || *dryRun {
+		return collectDryRun(ctx, pipeline, opts)
+	}
+
+	slog.Info("Collector started",
+		"storageDir", cfg.StorageDir,
+		"rawDir", opts.rawDir,
+		"interval", opts.interval.String(),
+		"incidentInterval", opts.incidentInterval.String(),
+	)
+
+	for {
+		next := collectOnce(ctx, pipeline, opts)
+
+		timer := time.NewTimer(next)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("Collector stopped")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// collectDryRun prints the report of a single run that writes nothing.
+func collectDryRun(ctx context.Context, pipeline *etl.Pipeline, opts collectOptions) error {
+	runCtx, cancel := context.WithTimeout(ctx, opts.runTimeout)
+	defer cancel()
+
+	report, err := pipeline.DryRun(runCtx)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// collectOnce executes a run, archives its page and returns the delay before the next one.
+func collectOnce(ctx context.Context, pipeline *etl.Pipeline, opts collectOptions) time.Duration {
+	// a shutdown signal lets the current run finish its writes
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), opts.runTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result, runErr := pipeline.Run(runCtx)
+	duration := time.Since(start)
+	tracing.Flush(runCtx)
+
+	// the page is kept even when it could not be parsed
+	if opts.rawDir != "" && len(pipeline.Scrapper.RawPage()) > 0 {
+		if err := archiveRawPage(opts.rawDir, pipeline.Scrapper.FetchTime(), pipeline.Scrapper.RawPage()); err != nil {
+			slog.Error("Failed to archive raw page", "error_msg", err.Error())
+		}
+	}
+
+	next := opts.interval
+	if runErr != nil || result.OpenIncidents() > 0 {
+		next = opts.incidentInterval
+	}
+
+	if errors.Is(runErr, etl.ErrImplausibleSnapshot) {
+		fmt.Printf("%s QUARANTINED duration=%s next=%s error=%q\n",
+			start.UTC().Format(time.RFC3339), duration.Round(time.Millisecond), next, runErr.Error())
+		return next
+	}
+
+	if runErr != nil {
+		slog.Error("Collector run failed", "error_msg", runErr.Error())
+		fmt.Printf("%s FAILED class=%s duration=%s next=%s error=%q\n",
+			start.UTC().Format(time.RFC3339), etl.ErrorClass(runErr), duration.Round(time.Millisecond), next, runErr.Error())
+		return next
+	}
+
+	fmt.Printf("%s OK stations=%d green=%d yellow=%d red=%d opened=%d resolved=%d overdue=%d missing=%d stationsWritten=%d statusesWritten=%d unchanged=%d duration=%s next=%s\n",
+		result.FetchTime.Format(time.RFC3339),
+		result.NumStatuses,
+		result.Counts.NumGreen,
+		result.Counts.NumYellow,
+		result.Counts.NumRed,
+		result.Counts.IncidentsOpened,
+		result.Counts.IncidentsResolved,
+		result.Counts.OverdueIncidents,
+		result.Counts.MissingStations,
+		result.NumStationsWritten,
+		result.NumStatusesWritten,
+		result.NumUnchanged,
+		duration.Round(time.Millisecond),
+		next,
+	)
+	return next
+}
+
+// archiveRawPage writes the page gzipped to <rawDir>/<date>/<time>.html.gz.
+func archiveRawPage(rawDir string, fetchTime time.Time, page []byte) error {
+	dayDir := filepath.Join(rawDir, fetchTime.Format("2006-01-02"))
+	if err := os.MkdirAll(dayDir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(dayDir, fetchTime.Format("150405")+".html.gz"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzWriter := gzip.NewWriter(file)
+	if _, err := gzWriter.Write(page); err != nil {
+		return err
+	}
+	if err := gzWriter.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/addressindex"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/aggregate"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/scrape"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/repair"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+	"github.com/aws/aws-lambda-go/events"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// sourceFlags are the configuration flags of every subcommand.
+type sourceFlags struct {
+	configFile string
+	storageDir string
+}
+
+func (f *sourceFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.configFile, "config", "", "JSON file of configuration variables, overriding the environment")
+	flags.StringVar(&f.storageDir, "storage-dir", "", "directory of the local store, overriding STORAGE_DIR")
+}
+
+// source reads the environment, with the overrides of the flags.
+func (f *sourceFlags) source() (*config.Source, error) {
+	source, err := config.NewSource(func(name string) (string, bool) {
+		if name == config.ConfigFileVariable && f.configFile != "" {
+			return f.configFile, true
+		}
+		return os.LookupEnv(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f.storageDir != "" {
+		source.Set("STORAGE_DIR", f.storageDir)
+	}
+	return source, nil
+}
+
+// runScrape runs the ETL once, a dry run prints its report as JSON.
+func runScrape(ctx context.Context, args []string) error {
+	var sf sourceFlags
+	var ev scrape.Event
+	flags := flag.NewFlagSet("scrape", flag.ExitOnError)
+	sf.register(flags)
+	flags.BoolVar(&ev.DryRun, "dry-run", false, "print what the run would write instead of writing it")
+	flags.BoolVar(&ev.ForceAccept, "force-accept", false, "write a snapshot breaking the plausibility rules instead of quarantining it")
+	flags.Parse(args)
+
+	source, err := sf.source()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadETL(source)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := tracing.Setup("etl", cfg.TracingExporter, cfg.OTLPEndpoint); err != nil {
+		return err
+	}
+
+	// local runs print their outcome instead of metrics
+	h, err := scrape.New(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+	report, err := h.Handle(ctx, ev)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return nil
+}
+
+// runAggregate runs the nightly aggregation once.
+func runAggregate(ctx context.Context, args []string) error {
+	var sf sourceFlags
+	flags := flag.NewFlagSet("aggregate", flag.ExitOnError)
+	sf.register(flags)
+	flags.Parse(args)
+
+	source, err := sf.source()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadAggregator(source)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := tracing.Setup("aggregator", cfg.TracingExporter, cfg.OTLPEndpoint); err != nil {
+		return err
+	}
+
+	a, err := aggregate.New(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+	return a.Handle(ctx, events.CloudWatchEvent{
+		ID:         fmt.Sprintf("local-%d", time.Now().Unix()),
+		Source:     "termoficare.cli",
+		DetailType: "Local Aggregation",
+		Time:       time.Now(),
+	})
+}
+
+// runRollups recomputes the daily rollups of a range of days from the archive.
+func runRollups(ctx context.Context, args []string) error {
+	var sf sourceFlags
+	flags := flag.NewFlagSet("rollups", flag.ExitOnError)
+	sf.register(flags)
+	first := flags.String("from", "", "first day to recompute, as YYYY-MM-DD in Bucharest")
+	last := flags.String("to", "", "last day to recompute, the first day when empty")
+	flags.Parse(args)
+
+	if *first == "" {
+		return errors.New("missing -from day")
+	}
+	if *last == "" {
+		*last = *first
+	}
+
+	source, err := sf.source()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadAggregator(source)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := tracing.Setup("aggregator", cfg.TracingExporter, cfg.OTLPEndpoint); err != nil {
+		return err
+	}
+
+	a, err := aggregate.New(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+	written, err := a.RecomputeRollups(ctx, *first, *last)
+	if err != nil {
+		return err
+	}
+	slog.Info("Daily rollups recomputed", "from", *first, "to", *last, "numRows", written)
+	return nil
+}
+
+// runRepairCounts rebuilds the day counts of a period from the status history
+// or its archive, and prints how they differ from the stored rows as JSON.
+// Nothing is written without -write.
+func runRepairCounts(ctx context.Context, args []string) error {
+	var sf sourceFlags
+	flags := flag.NewFlagSet("repair-counts", flag.ExitOnError)
+	sf.register(flags)
+	fromFlag := flags.String("from", "", "start of the period, RFC 3339 or YYYY-MM-DD in Bucharest")
+	toFlag := flags.String("to", "", "end of the period, RFC 3339 or YYYY-MM-DD in Bucharest for the whole day, now when empty")
+	statusSource := flags.String("source", "", "statuses to rebuild from, history or archive, overriding REPAIR_SOURCE")
+	write := flags.Bool("write", false, "write the rebuilt rows which differ from the stored ones")
+	onlyMissing := flags.Bool("only-missing", false, "only write the rows missing from the table, leave the stored ones")
+	flags.Parse(args)
+
+	location, err := time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		return fmt.Errorf("failed to load the Europe/Bucharest time zone: %w", err)
+	}
+	if *fromFlag == "" {
+		return errors.New("missing -from time")
+	}
+	from, err := parseRepairTime(*fromFlag, location, false)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		if to, err = parseRepairTime(*toFlag, location, true); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	if to.Before(from) {
+		return fmt.Errorf("-to %s is before -from %s", to, from)
+	}
+
+	source, err := sf.source()
+	if err != nil {
+		return err
+	}
+	if *statusSource != "" {
+		source.Set("REPAIR_SOURCE", *statusSource)
+	}
+	cfg, err := config.LoadRepair(source)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := tracing.Setup("repair", cfg.TracingExporter, cfg.OTLPEndpoint); err != nil {
+		return err
+	}
+	defer tracing.Flush(ctx)
+
+	var (
+		countsRepository storage.DayCountsRepository
+		stations         storage.StationRepository
+		history          storage.StatusRetentionRepository
+		archive          storage.StatusRangeArchive
+		weights          *scrapper.StationWeights
+	)
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return fmt.Errorf("failed to open file store: %w", err)
+		}
+		countsRepository, stations, history, archive = fileStore, fileStore, fileStore, fileStore
+		weights, err = storage.LoadFileStationWeights(cfg.StorageDir, cfg.StationWeightsKey, cfg.DefaultStationWeight)
+		if err != nil {
+			return err
+		}
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		dbClient := dynamodb.NewFromConfig(awsCfg)
+		s3Client := s3.NewFromConfig(awsCfg)
+		countsRepository = storage.NewDynamoDayCountsRepository(dbClient, cfg.DayCountsTable)
+		if cfg.Source == config.RepairSourceHistory {
+			stations = storage.NewDynamoStationRepository(dbClient, cfg.StationsTable)
+			history = storage.NewDynamoStatusHistoryRepository(dbClient, cfg.StatusesTable)
+		} else {
+			archive = storage.NewS3StatusArchive(s3Client, cfg.Bucket)
+		}
+		weights, err = storage.LoadS3StationWeights(ctx, s3Client, cfg.Bucket, cfg.StationWeightsKey, cfg.DefaultStationWeight)
+		if err != nil {
+			return err
+		}
+	}
+
+	// a full history has a row every run, a change-only one every heartbeat,
+	// and a run can see its rows up to an interval late
+	sampleDuration := cfg.ScheduleInterval
+	if cfg.Heartbeat > 0 {
+		sampleDuration = cfg.Heartbeat
+	}
+	maxSampleDuration := sampleDuration + cfg.ScheduleInterval
+	// the statuses before the period give the first snapshots and the stations stored as active
+	statusesFrom := from.Add(-maxSampleDuration - cfg.InactiveGracePeriod)
+
+	var statuses []scrapper.HeatingStationStatus
+	var missingDays []string
+	if cfg.Source == config.RepairSourceHistory {
+		statuses, err = repair.HistoryStatuses(ctx, stations, history, statusesFrom, to)
+	} else {
+		statuses, missingDays, err = repair.ArchiveStatuses(ctx, archive, statusesFrom, to)
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := repair.RepairCounts(ctx, countsRepository, statuses, repair.Options{
+		From:     from,
+		To:       to,
+		Interval: cfg.ScheduleInterval,
+		Reconstruction: scrapper.CountsReconstructionOptions{
+			MaxSampleDuration:   maxSampleDuration,
+			InactiveGracePeriod: cfg.InactiveGracePeriod,
+			Weights:             weights,
+		},
+		Write:       *write,
+		OnlyMissing: *onlyMissing,
+		MissingDays: missingDays,
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// parseRepairTime parses an RFC 3339 time or a day in location, a day is its
+// midnight, or its last second when end is set.
+func parseRepairTime(value string, location *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a YYYY-MM-DD day", value)
+	}
+	if end {
+		return day.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return day, nil
+}
+
+// runServe serves every API endpoint over HTTP until interrupted. The
+// endpoints which cannot be set up, such as the address station without an
+// address index, are left out with a warning.
+func runServe(ctx context.Context, args []string) error {
+	var sf sourceFlags
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	sf.register(flags)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	flags.Parse(args)
+
+	source, err := sf.source()
+	if err != nil {
+		return err
+	}
+
+	base, err := config.LoadAPI(source)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := tracing.Setup("api", base.TracingExporter, base.OTLPEndpoint); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	numServed := 0
+	for _, e := range endpoints {
+		cfg, err := config.LoadAPI(source, e.required...)
+		if err != nil {
+			slog.Warn("Endpoint not served, invalid configuration", "endpoint", e.name, "error_msg", err.Error())
+			continue
+		}
+		handle, err := e.new(ctx, cfg)
+		if err != nil {
+			slog.Warn("Endpoint not served, setup failed", "endpoint", e.name, "error_msg", err.Error())
+			continue
+		}
+		mux.Handle("/"+e.name, httpapi.NewHTTPHandler(tracing.WrapAPIHandler(e.name, handle)))
+		numServed++
+	}
+	if numServed == 0 {
+		return errors.New("no endpoint could be set up")
+	}
+
+	server := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Serving the API", "addr", *addr, "numEndpoints", numServed)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// runBuildAddressIndex builds the compact address lookup index the address
+// to station endpoint loads, from a CSV or GeoJSON address dataset:
+//
+//	termoficare build-address-index -in bucharest-addresses.geojson -out addresses.idx
+//	aws s3 cp addresses.idx s3://<env>-termoficare-backups/address_index/addresses.idx
+func runBuildAddressIndex(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("build-address-index", flag.ExitOnError)
+	inPath := flags.String("in", "", "path to the CSV or GeoJSON address dataset")
+	outPath := flags.String("out", "addresses.idx", "path of the index file to write")
+	flags.Parse(args)
+
+	if *inPath == "" {
+		return errors.New("missing -in dataset")
+	}
+
+	in, err := os.Open(*inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	builder := addressindex.NewBuilder()
+
+	var stats addressindex.ImportStats
+	switch strings.ToLower(filepath.Ext(*inPath)) {
+	case ".csv":
+		stats, err = addressindex.ImportCSV(in, builder)
+	case ".json", ".geojson":
+		stats, err = addressindex.ImportGeoJSON(in, builder)
+	default:
+		return fmt.Errorf("unsupported dataset extension: %s", filepath.Ext(*inPath))
+	}
+	if err != nil {
+		return err
+	}
+
+	idx := builder.Build()
+
+	out, err := os.Create(*outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	size, err := idx.WriteTo(out)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Address index written",
+		"path", *outPath,
+		"sizeBytes", size,
+		"numAddresses", idx.NumAddresses(),
+		"imported", stats.Imported,
+		"skipped", stats.Skipped,
+	)
+	return nil
+}
+
+// runMigrateCounts copies the rows of the day counts table keyed by Timestamp
+// only into the table partitioned by year, by default the
+// DYNAMODB_TABLE_DAY_COUNTS of the configuration. Rows are written with their
+// key, so the migration can be run again safely.
+func runMigrateCounts(ctx context.Context, args []string) error {
+	var sf sourceFlags
+	flags := flag.NewFlagSet("migrate-counts", flag.ExitOnError)
+	sf.register(flags)
+	fromTable := flags.String("from", "", "name of the counts table keyed by Timestamp")
+	toTable := flags.String("to", "", "name of the counts table partitioned by year, overriding DYNAMODB_TABLE_DAY_COUNTS")
+	dryRun := flags.Bool("dry-run", false, "only read the rows to migrate")
+	flags.Parse(args)
+
+	source, err := sf.source()
+	if err != nil {
+		return err
+	}
+	if *toTable == "" {
+		*toTable = source.Lookup("DYNAMODB_TABLE_DAY_COUNTS")
+	}
+	if *fromTable == "" || *toTable == "" {
+		return errors.New("missing -from or -to table")
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS SDK config: %w", err)
+	}
+	dbClient := dynamodb.NewFromConfig(awsCfg)
+
+	counts, err := storage.ScanLegacyDayCounts(ctx, dbClient, *fromTable)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", *fromTable, err)
+	}
+	if len(counts) == 0 {
+		slog.Info("No day counts to migrate", "table", *fromTable)
+		return nil
+	}
+
+	first := time.Unix(counts[0].Time, 0)
+	last := time.Unix(counts[len(counts)-1].Time, 0)
+	slog.Info("Day counts read",
+		"table", *fromTable,
+		"numRows", len(counts),
+		"first", first.UTC().Format(time.RFC3339),
+		"last", last.UTC().Format(time.RFC3339),
+	)
+	if *dryRun {
+		return nil
+	}
+
+	repository := storage.NewDynamoDayCountsRepository(dbClient, *toTable)
+	if err := repository.PutManyCounts(ctx, counts); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *toTable, err)
+	}
+
+	// the new table may already hold rows written by the ETL after the switch
+	migrated, err := repository.ListCounts(ctx, first, last)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", *toTable, err)
+	}
+	if len(migrated) < len(counts) {
+		return fmt.Errorf("%s holds %d rows in the migrated range, want at least %d", *toTable, len(migrated), len(counts))
+	}
+
+	slog.Info("Day counts migrated", "table", *toTable, "numRows", len(counts))
+	return nil
+}
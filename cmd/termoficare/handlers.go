@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/addressstation"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/aggregate"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/availability"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/counts"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/incidents"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/scrape"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/serviceareas"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/stationdetails"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/stations"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/stationsstats"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/metrics"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// lambdaHandler sets up a function from its configuration, and returns the
+// handler given to the lambda runtime.
+type lambdaHandler func(ctx context.Context, source *config.Source) (any, error)
+
+// endpoint is an API function, run by the api-<name> handler and served on
+// /<name> by the serve command.
+type endpoint struct {
+	name string
+	// required are the tables and buckets the function reads
+	required []string
+	new      func(ctx context.Context, cfg config.API) (httpapi.Handler, error)
+}
+
+var endpoints = []endpoint{
+	{name: "counts", required: counts.RequiredVariables, new: apiHandler(counts.New)},
+	{name: "stations", required: stations.RequiredVariables, new: apiHandler(stations.New)},
+	{name: "station-details", required: stationdetails.RequiredVariables, new: apiHandler(stationdetails.New)},
+	{name: "stations-stats", required: stationsstats.RequiredVariables, new: apiHandler(stationsstats.New)},
+	{name: "service-areas", required: serviceareas.RequiredVariables, new: apiHandler(serviceareas.New)},
+	{name: "address-station", required: addressstation.RequiredVariables, new: apiHandler(addressstation.New)},
+	{name: "availability", required: availability.RequiredVariables, new: apiHandler(availability.New)},
+	{name: "incidents", required: incidents.RequiredVariables, new: apiHandler(incidents.New)},
+}
+
+// lambdaHandlers returns the functions of the binary by the value of the
+// HANDLER variable.
+func lambdaHandlers() map[string]lambdaHandler {
+	handlers := map[string]lambdaHandler{
+		"etl":       startETL,
+		"aggregate": startAggregate,
+	}
+	for _, e := range endpoints {
+		handlers["api-"+e.name] = e.start
+	}
+	return handlers
+}
+
+// apiHandler adapts the constructor of an API handler.
+func apiHandler[H interface {
+	Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}](newHandler func(ctx context.Context, cfg config.API) (H, error)) func(ctx context.Context, cfg config.API) (httpapi.Handler, error) {
+	return func(ctx context.Context, cfg config.API) (httpapi.Handler, error) {
+		h, err := newHandler(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return h.Handle, nil
+	}
+}
+
+// observe sets up the tracing of a function, and returns the emitter of its
+// metrics.
+func observe(service string, cfg config.Observability) (*metrics.Emitter, error) {
+	// log lines carry the trace and span ids, the spans are exported at the end of every invocation
+	if err := tracing.Setup(service, cfg.TracingExporter, cfg.OTLPEndpoint); err != nil {
+		return nil, err
+	}
+	return metrics.New(os.Stdout, cfg.MetricsNamespace, metrics.Dimension{Name: "Service", Value: service}), nil
+}
+
+func startETL(ctx context.Context, source *config.Source) (any, error) {
+	cfg, err := config.LoadETL(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	emitter, err := observe("etl", cfg.Observability)
+	if err != nil {
+		return nil, err
+	}
+	h, err := scrape.New(ctx, cfg, emitter)
+	if err != nil {
+		return nil, err
+	}
+	return h.Handle, nil
+}
+
+func startAggregate(ctx context.Context, source *config.Source) (any, error) {
+	cfg, err := config.LoadAggregator(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	emitter, err := observe("aggregator", cfg.Observability)
+	if err != nil {
+		return nil, err
+	}
+	a, err := aggregate.New(ctx, cfg, emitter)
+	if err != nil {
+		return nil, err
+	}
+	return a.Handle, nil
+}
+
+func (e endpoint) start(ctx context.Context, source *config.Source) (any, error) {
+	cfg, err := config.LoadAPI(source, e.required...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	emitter, err := observe("api", cfg.Observability)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := e.new(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return tracing.WrapAPIHandler(e.name, metrics.WrapAPIHandler(emitter, e.name, handle)), nil
+}
@@ -0,0 +1,123 @@
+// Command termoficare is the single binary of every function and of their
+// local runs. Without arguments it runs the lambda function named by the
+// HANDLER variable:
+//
+//	HANDLER=etl|aggregate|api-counts|api-stations|... termoficare
+//
+// The subcommands run the same code locally, usually against a storage
+// directory:
+//
+//	termoficare scrape -storage-dir ./data [-dry-run] [-force-accept]
+//	termoficare aggregate -storage-dir ./data
+//	termoficare rollups -from 2025-01-01 [-to 2025-01-31]
+//	termoficare repair-counts -from 2025-01-01 [-to 2025-01-31] [-source history|archive] [-write [-only-missing]]
+//	termoficare serve -storage-dir ./data -addr localhost:8080
+//	termoficare collect -storage-dir ./data [-raw-dir ./data/raw] [-dry-run] [-force-accept]
+//
+// and so do the maintenance tools:
+//
+//	termoficare build-address-index -in addresses.geojson -out addresses.idx
+//	termoficare migrate-counts -from <env>-day-counts [-to <env>-day-counts-by-year] [-dry-run]
+//
+// Every run reads the same configuration as the functions, from the
+// environment and the JSON file of -config.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// HandlerVariable names the function run by the lambda runtime.
+const HandlerVariable = "HANDLER"
+
+// commands are the subcommands, by name.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"scrape":        runScrape,
+	"aggregate":     runAggregate,
+	"rollups":       runRollups,
+	"repair-counts": runRepairCounts,
+	"serve":         runServe,
+	"collect":       runCollect,
+
+	"build-address-index": runBuildAddressIndex,
+	"migrate-counts":      runMigrateCounts,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		startLambda()
+		return
+	}
+
+	command, found := commands[os.Args[1]]
+	if !found {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := command(ctx, os.Args[2:]); err != nil {
+		slog.Error("Command failed", "command", os.Args[1], "error_msg", err.Error())
+		os.Exit(1)
+	}
+}
+
+// startLambda hands the function of the HANDLER variable to the lambda
+// runtime.
+func startLambda() {
+	handlers := lambdaHandlers()
+	name := os.Getenv(HandlerVariable)
+	start, found := handlers[name]
+	if !found {
+		slog.Error("Unknown or missing HANDLER environment variable", "handler", name, "handlers", strings.Join(handlerNames(handlers), ","))
+		usage()
+		os.Exit(2)
+	}
+
+	source, err := config.FromEnvironment()
+	if err != nil {
+		slog.Error("Failed to read the configuration", "handler", name, "error_msg", err.Error())
+		os.Exit(1)
+	}
+	handler, err := start(context.Background(), source)
+	if err != nil {
+		slog.Error("Failed to start the function", "handler", name, "error_msg", err.Error())
+		os.Exit(1)
+	}
+	lambda.Start(handler)
+}
+
+func handlerNames(handlers map[string]lambdaHandler) []string {
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s=<handler> termoficare    run a lambda function, one of %s\n", HandlerVariable, strings.Join(handlerNames(lambdaHandlers()), ", "))
+	fmt.Fprintf(os.Stderr, "  termoficare scrape [flags]     run the ETL once\n")
+	fmt.Fprintf(os.Stderr, "  termoficare aggregate [flags]  run the nightly aggregation once\n")
+	fmt.Fprintf(os.Stderr, "  termoficare rollups [flags]    recompute the daily rollups of a range of days\n")
+	fmt.Fprintf(os.Stderr, "  termoficare repair-counts [flags]  rebuild the day counts of a period and report or fix the differences\n")
+	fmt.Fprintf(os.Stderr, "  termoficare serve [flags]      serve the API over HTTP\n")
+	fmt.Fprintf(os.Stderr, "  termoficare collect [flags]    run the ETL on its own schedule until interrupted\n")
+	fmt.Fprintf(os.Stderr, "  termoficare build-address-index [flags]  build the address index from an address dataset\n")
+	fmt.Fprintf(os.Stderr, "  termoficare migrate-counts [flags]  copy the day counts keyed by Timestamp into the table partitioned by year\n")
+	fmt.Fprintf(os.Stderr, "Run a subcommand with -h for its flags.\n")
+}
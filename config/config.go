@@ -0,0 +1,305 @@
+package config
+
+import (
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/cloudevents"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/etl"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/metrics"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+)
+
+// Observability configures the metrics and the spans of a component.
+type Observability struct {
+	MetricsNamespace string
+	TracingExporter  string
+	OTLPEndpoint     string
+}
+
+func loadObservability(l *loader) Observability {
+	o := Observability{
+		MetricsNamespace: l.string("METRICS_NAMESPACE", metrics.DefaultNamespace),
+		TracingExporter:  l.string("TRACING_EXPORTER", tracing.ExporterNone),
+		OTLPEndpoint:     l.string("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+	}
+	switch o.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		l.check(o.OTLPEndpoint != "", "OTEL_EXPORTER_OTLP_ENDPOINT is required by the %s exporter", tracing.ExporterOTLP)
+	default:
+		l.check(false, "TRACING_EXPORTER: unknown exporter %q", o.TracingExporter)
+	}
+	return o
+}
+
+// ETL is the configuration of the scrape and persist function. With a
+// storage directory, the tables and the bucket are replaced by local files.
+type ETL struct {
+	Observability
+	StorageDir string
+
+	DayCountsTable string
+	StationsTable  string
+	StatusesTable  string
+	EtlRunsTable   string
+	IncidentsTable string
+	// Bucket keeps the quarantined snapshots and the current state documents
+	Bucket string
+	// EventOutboxTable keeps the domain events until they are published
+	EventOutboxTable string
+	// RunSlotsTable keys the scheduled runs by their slot of ScheduleInterval,
+	// the interval of the schedule rule, zero leaves the runs unkeyed
+	RunSlotsTable    string
+	ScheduleInterval time.Duration
+	// EventsSink is the kind of sink the domain events are published to, none
+	// by default, and EventsTarget the file, URL, topic ARN or bus name of it
+	EventsSink   string
+	EventsTarget string
+
+	ChangeOnly bool
+	Heartbeat  time.Duration
+	// InactiveGracePeriod is how long a station can be missing before it is marked inactive
+	InactiveGracePeriod time.Duration
+	// StatusRetention is the TTL of the status rows, zero keeps them forever
+	StatusRetention time.Duration
+	// DryRun and ForceAccept apply to every run, whatever the invocation payload
+	DryRun               bool
+	ForceAccept          bool
+	DefaultStationWeight float64
+	// StationWeightsKey is the key of the station weights table in the bucket,
+	// or its path in the storage directory, empty for the built-in table
+	StationWeightsKey string
+	Rules             etl.PlausibilityRules
+}
+
+func LoadETL(source *Source) (ETL, error) {
+	l := &loader{source: source}
+	c := ETL{
+		Observability: loadObservability(l),
+		StorageDir:    l.string("STORAGE_DIR", ""),
+	}
+	local := c.StorageDir != ""
+
+	c.DayCountsTable = l.required("DYNAMODB_TABLE_DAY_COUNTS", local)
+	c.StationsTable = l.required("DYNAMODB_TABLE_STATIONS", local)
+	c.StatusesTable = l.required("DYNAMODB_TABLE_STATUSES", local)
+	c.EtlRunsTable = l.required("DYNAMODB_TABLE_ETL_RUNS", local)
+	c.IncidentsTable = l.required("DYNAMODB_TABLE_INCIDENTS", local)
+	c.Bucket = l.required("S3_BUCKET", local)
+	c.EventsSink = l.string("EVENTS_SINK", cloudevents.SinkNone)
+	c.EventsTarget = l.string("EVENTS_TARGET", "")
+	c.EventOutboxTable = l.required("DYNAMODB_TABLE_EVENT_OUTBOX", local || c.EventsSink == cloudevents.SinkNone)
+	c.ScheduleInterval = l.duration("SCHEDULE_INTERVAL", 30*time.Minute)
+	c.RunSlotsTable = l.required("DYNAMODB_TABLE_RUN_SLOTS", local || c.ScheduleInterval == 0)
+
+	c.ChangeOnly = l.bool("CHANGE_ONLY_PERSISTENCE", false)
+	c.Heartbeat = l.duration("STATUS_HEARTBEAT_INTERVAL", 6*time.Hour)
+	c.InactiveGracePeriod = l.duration("STATION_INACTIVE_GRACE_PERIOD", 24*time.Hour)
+	c.StatusRetention = l.duration("STATUS_RETENTION", 0)
+	c.DryRun = l.bool("DRY_RUN", false)
+	c.ForceAccept = l.bool("FORCE_ACCEPT", false)
+	c.DefaultStationWeight = l.float("DEFAULT_STATION_WEIGHT", scrapper.DefaultStationWeight)
+	c.StationWeightsKey = l.string("STATION_WEIGHTS_KEY", "")
+
+	defaults := etl.DefaultPlausibilityRules()
+	c.Rules = etl.PlausibilityRules{
+		MaxStationDrop:       l.float("PLAUSIBILITY_MAX_STATION_DROP", defaults.MaxStationDrop),
+		MaxNewStations:       l.float("PLAUSIBILITY_MAX_NEW_STATIONS", defaults.MaxNewStations),
+		RejectSingleCategory: l.bool("PLAUSIBILITY_REJECT_SINGLE_CATEGORY", defaults.RejectSingleCategory),
+	}
+
+	l.check(!c.ChangeOnly || c.Heartbeat > 0, "STATUS_HEARTBEAT_INTERVAL must be set with CHANGE_ONLY_PERSISTENCE")
+	// a row expiring before the next heartbeat would leave the station without history
+	l.check(c.StatusRetention == 0 || c.StatusRetention > c.Heartbeat, "STATUS_RETENTION must be longer than STATUS_HEARTBEAT_INTERVAL")
+	l.check(c.DefaultStationWeight >= 0, "DEFAULT_STATION_WEIGHT must not be negative")
+	l.check(c.ScheduleInterval >= 0, "SCHEDULE_INTERVAL must not be negative")
+	l.check(c.Rules.MaxStationDrop >= 0 && c.Rules.MaxStationDrop <= 1, "PLAUSIBILITY_MAX_STATION_DROP must be a ratio between 0 and 1")
+	l.check(c.Rules.MaxNewStations >= 0 && c.Rules.MaxNewStations <= 1, "PLAUSIBILITY_MAX_NEW_STATIONS must be a ratio between 0 and 1")
+	switch c.EventsSink {
+	case cloudevents.SinkNone, cloudevents.SinkStdout:
+	case cloudevents.SinkFile, cloudevents.SinkHTTP, cloudevents.SinkSNS, cloudevents.SinkEventBridge:
+		l.check(c.EventsTarget != "", "EVENTS_TARGET is required by the %s sink", c.EventsSink)
+	default:
+		l.check(false, "EVENTS_SINK: unknown sink %q", c.EventsSink)
+	}
+	return c, l.err()
+}
+
+// Aggregator is the configuration of the nightly statistics function.
+type Aggregator struct {
+	Observability
+	StorageDir string
+
+	// StatsTable is the station incident stats table, read from DYNAMODB_TABLE_STATIONS
+	StatsTable              string
+	Bucket                  string
+	EtlRunsTable            string
+	IncidentsTable          string
+	FixDateReliabilityTable string
+	DayRollupsTable         string
+	// DayCountsTable is only needed with a heartbeat, to find the last snapshot
+	DayCountsTable string
+	// StationsTable and StatusesTable are only needed with a status retention
+	StationsTable string
+	StatusesTable string
+
+	// Heartbeat is the heartbeat of a change-only history, zero for a full one
+	Heartbeat               time.Duration
+	StatusRetention         time.Duration
+	RollupDays              int
+	ObservationGapThreshold time.Duration
+	DefaultStationWeight    float64
+	// StationWeightsKey is the key of the station weights table in the bucket,
+	// or its path in the storage directory, empty for the built-in table
+	StationWeightsKey string
+}
+
+func LoadAggregator(source *Source) (Aggregator, error) {
+	l := &loader{source: source}
+	c := Aggregator{
+		Observability: loadObservability(l),
+		StorageDir:    l.string("STORAGE_DIR", ""),
+	}
+	local := c.StorageDir != ""
+
+	c.Heartbeat = l.duration("STATUS_HEARTBEAT_INTERVAL", 0)
+	c.StatusRetention = l.duration("STATUS_RETENTION", 0)
+	// the complete days recomputed every night, a larger value backfills the rollups
+	c.RollupDays = l.int("ROLLUP_DAYS", 3)
+	// successful runs further apart than this leave a gap in the observations,
+	// the default tolerates one missed run of the half-hourly schedule
+	c.ObservationGapThreshold = l.duration("OBSERVATION_GAP_THRESHOLD", 90*time.Minute)
+	c.DefaultStationWeight = l.float("DEFAULT_STATION_WEIGHT", scrapper.DefaultStationWeight)
+	c.StationWeightsKey = l.string("STATION_WEIGHTS_KEY", "")
+
+	c.StatsTable = l.required("DYNAMODB_TABLE_STATIONS", local)
+	c.Bucket = l.required("S3_BUCKET", local)
+	c.EtlRunsTable = l.required("DYNAMODB_TABLE_ETL_RUNS", local)
+	c.IncidentsTable = l.required("DYNAMODB_TABLE_INCIDENTS", local)
+	c.FixDateReliabilityTable = l.required("DYNAMODB_TABLE_FIX_DATE_RELIABILITY", local)
+	c.DayRollupsTable = l.required("DYNAMODB_TABLE_DAY_ROLLUPS", local)
+	c.DayCountsTable = l.required("DYNAMODB_TABLE_DAY_COUNTS", local || c.Heartbeat == 0)
+	c.StationsTable = l.required("DYNAMODB_TABLE_HEATING_STATIONS", local || c.StatusRetention == 0)
+	c.StatusesTable = l.required("DYNAMODB_TABLE_STATUSES", local || c.StatusRetention == 0)
+
+	l.check(c.RollupDays >= 0, "ROLLUP_DAYS must not be negative")
+	l.check(c.ObservationGapThreshold > 0, "OBSERVATION_GAP_THRESHOLD must be positive")
+	l.check(c.DefaultStationWeight >= 0, "DEFAULT_STATION_WEIGHT must not be negative")
+	return c, l.err()
+}
+
+// Sources of the statuses the day counts are rebuilt from.
+const (
+	RepairSourceHistory = "history"
+	RepairSourceArchive = "archive"
+)
+
+// Repair is the configuration of the day counts repair, run against the
+// tables of the ETL.
+type Repair struct {
+	Observability
+	StorageDir string
+	// Source is the status history table or its S3 archive
+	Source         string
+	DayCountsTable string
+	// StationsTable and StatusesTable are only needed by the history source
+	StationsTable string
+	StatusesTable string
+	// Bucket is only needed by the archive source or a station weights key
+	Bucket string
+
+	// Heartbeat is the heartbeat of a change-only history, zero for a full one
+	Heartbeat           time.Duration
+	InactiveGracePeriod time.Duration
+	// ScheduleInterval is the interval of the ETL runs, the slots without a row are rebuilt
+	ScheduleInterval     time.Duration
+	DefaultStationWeight float64
+	// StationWeightsKey is the key of the station weights table in the bucket,
+	// or its path in the storage directory, empty for the built-in table
+	StationWeightsKey string
+}
+
+func LoadRepair(source *Source) (Repair, error) {
+	l := &loader{source: source}
+	c := Repair{
+		Observability: loadObservability(l),
+		StorageDir:    l.string("STORAGE_DIR", ""),
+		Source:        l.string("REPAIR_SOURCE", RepairSourceHistory),
+	}
+	local := c.StorageDir != ""
+	c.StationWeightsKey = l.string("STATION_WEIGHTS_KEY", "")
+
+	c.DayCountsTable = l.required("DYNAMODB_TABLE_DAY_COUNTS", local)
+	c.StationsTable = l.required("DYNAMODB_TABLE_STATIONS", local || c.Source != RepairSourceHistory)
+	c.StatusesTable = l.required("DYNAMODB_TABLE_STATUSES", local || c.Source != RepairSourceHistory)
+	c.Bucket = l.required("S3_BUCKET", local || (c.Source != RepairSourceArchive && c.StationWeightsKey == ""))
+
+	c.Heartbeat = l.duration("STATUS_HEARTBEAT_INTERVAL", 0)
+	c.InactiveGracePeriod = l.duration("STATION_INACTIVE_GRACE_PERIOD", 24*time.Hour)
+	c.ScheduleInterval = l.duration("SCHEDULE_INTERVAL", 30*time.Minute)
+	c.DefaultStationWeight = l.float("DEFAULT_STATION_WEIGHT", scrapper.DefaultStationWeight)
+
+	l.check(c.Source == RepairSourceHistory || c.Source == RepairSourceArchive, "REPAIR_SOURCE: unknown source %q", c.Source)
+	l.check(c.ScheduleInterval > 0, "SCHEDULE_INTERVAL must be positive")
+	l.check(c.DefaultStationWeight >= 0, "DEFAULT_STATION_WEIGHT must not be negative")
+	return c, l.err()
+}
+
+// API is the configuration of the API functions. Every function reads its own
+// tables, the others are left empty.
+type API struct {
+	Observability
+	StorageDir string
+	// AllowOrigin is the Access-Control-Allow-Origin header of every response
+	AllowOrigin string
+
+	StationsTable           string
+	DayCountsTable          string
+	StatusHistoryTable      string
+	DayRollupsTable         string
+	StationsStatsTable      string
+	FixDateReliabilityTable string
+	EtlRunsTable            string
+	IncidentsTable          string
+	Bucket                  string
+	// AddressIndexKey is the key of the address index in the bucket, or its
+	// path in the storage directory
+	AddressIndexKey string
+}
+
+// LoadAPI loads the configuration of an API function, required are the
+// variables of the tables and bucket the function reads.
+func LoadAPI(source *Source, required ...string) (API, error) {
+	l := &loader{source: source}
+	c := API{
+		Observability:   loadObservability(l),
+		StorageDir:      l.string("STORAGE_DIR", ""),
+		AllowOrigin:     l.string("ACCESS_CONTROL_ALLOW_ORIGIN", "*"),
+		AddressIndexKey: l.string("ADDRESS_INDEX_KEY", "address_index/addresses.idx"),
+	}
+
+	variables := map[string]*string{
+		"DYNAMODB_TABLE_STATIONS":             &c.StationsTable,
+		"DYNAMODB_TABLE_DAY_COUNTS":           &c.DayCountsTable,
+		"DYNAMODB_TABLE_STATUS_HISTORY":       &c.StatusHistoryTable,
+		"DYNAMODB_TABLE_DAY_ROLLUPS":          &c.DayRollupsTable,
+		"DYNAMODB_TABLE_STATIONS_STATS":       &c.StationsStatsTable,
+		"DYNAMODB_TABLE_FIX_DATE_RELIABILITY": &c.FixDateReliabilityTable,
+		"DYNAMODB_TABLE_ETL_RUNS":             &c.EtlRunsTable,
+		"DYNAMODB_TABLE_INCIDENTS":            &c.IncidentsTable,
+		"S3_BUCKET":                           &c.Bucket,
+	}
+	for name, field := range variables {
+		*field = l.string(name, "")
+	}
+	for _, name := range required {
+		field, known := variables[name]
+		if !known {
+			l.check(false, "%s is not a table or bucket variable", name)
+			continue
+		}
+		*field = l.required(name, c.StorageDir != "")
+	}
+	return c, l.err()
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapSource returns a source reading the environment from a map.
+func mapSource(t *testing.T, env map[string]string) *Source {
+	t.Helper()
+	source, err := NewSource(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	return source
+}
+
+func TestLoadETL(t *testing.T) {
+	tables := map[string]string{
+		"DYNAMODB_TABLE_DAY_COUNTS": "counts",
+		"DYNAMODB_TABLE_STATIONS":   "stations",
+		"DYNAMODB_TABLE_STATUSES":   "statuses",
+		"DYNAMODB_TABLE_ETL_RUNS":   "runs",
+		"DYNAMODB_TABLE_INCIDENTS":  "incidents",
+		"S3_BUCKET":                 "bucket",
+		"DYNAMODB_TABLE_RUN_SLOTS":  "slots",
+	}
+	withTables := func(env map[string]string) map[string]string {
+		merged := make(map[string]string)
+		for name, value := range tables {
+			merged[name] = value
+		}
+		for name, value := range env {
+			merged[name] = value
+		}
+		return merged
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		// wantErrs are substrings of the error, every one must be reported
+		wantErrs []string
+		check    func(t *testing.T, c ETL)
+	}{
+		{
+			name: "defaults",
+			env:  withTables(nil),
+			check: func(t *testing.T, c ETL) {
+				if c.Heartbeat != 6*time.Hour || c.InactiveGracePeriod != 24*time.Hour || c.ChangeOnly || c.DryRun || c.ScheduleInterval != 30*time.Minute {
+					t.Errorf("LoadETL() = %+v", c)
+				}
+				if c.Rules.MaxStationDrop != 0.2 || !c.Rules.RejectSingleCategory || c.MetricsNamespace != "Termoficare" {
+					t.Errorf("LoadETL() = %+v", c)
+				}
+			},
+		},
+		{
+			name: "storage directory needs no table",
+			env:  map[string]string{"STORAGE_DIR": "/tmp/data", "CHANGE_ONLY_PERSISTENCE": "true", "STATUS_RETENTION": "2160h"},
+			check: func(t *testing.T, c ETL) {
+				if !c.ChangeOnly || c.StatusRetention != 2160*time.Hour || c.StorageDir != "/tmp/data" {
+					t.Errorf("LoadETL() = %+v", c)
+				}
+			},
+		},
+		{
+			name:     "every problem is reported",
+			env:      map[string]string{"STATUS_HEARTBEAT_INTERVAL": "often", "DRY_RUN": "maybe", "PLAUSIBILITY_MAX_STATION_DROP": "2", "TRACING_EXPORTER": "otlp"},
+			wantErrs: []string{"DYNAMODB_TABLE_DAY_COUNTS is required", "S3_BUCKET is required", "DYNAMODB_TABLE_RUN_SLOTS is required", "STATUS_HEARTBEAT_INTERVAL: invalid duration", "DRY_RUN: invalid boolean", "PLAUSIBILITY_MAX_STATION_DROP must be a ratio", "OTEL_EXPORTER_OTLP_ENDPOINT is required"},
+		},
+		{
+			name:     "retention shorter than the heartbeat",
+			env:      withTables(map[string]string{"STATUS_RETENTION": "1h"}),
+			wantErrs: []string{"STATUS_RETENTION must be longer than STATUS_HEARTBEAT_INTERVAL"},
+		},
+		{
+			name: "events published to a topic",
+			env:  withTables(map[string]string{"EVENTS_SINK": "sns", "EVENTS_TARGET": "arn:aws:sns:eu-central-1:123456789012:events", "DYNAMODB_TABLE_EVENT_OUTBOX": "outbox"}),
+			check: func(t *testing.T, c ETL) {
+				if c.EventsSink != "sns" || c.EventOutboxTable != "outbox" {
+					t.Errorf("LoadETL() = %+v", c)
+				}
+			},
+		},
+		{
+			name: "runs without slots",
+			env:  map[string]string{"STORAGE_DIR": "/tmp/data", "SCHEDULE_INTERVAL": "0s"},
+			check: func(t *testing.T, c ETL) {
+				if c.ScheduleInterval != 0 {
+					t.Errorf("LoadETL() = %+v", c)
+				}
+			},
+		},
+		{
+			name:     "events sink without outbox or target",
+			env:      withTables(map[string]string{"EVENTS_SINK": "http"}),
+			wantErrs: []string{"DYNAMODB_TABLE_EVENT_OUTBOX is required", "EVENTS_TARGET is required by the http sink"},
+		},
+		{
+			name:     "unknown events sink",
+			env:      map[string]string{"STORAGE_DIR": "/tmp/data", "EVENTS_SINK": "kafka"},
+			wantErrs: []string{`EVENTS_SINK: unknown sink "kafka"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := LoadETL(mapSource(t, tt.env))
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("LoadETL() error = %v", err)
+				}
+				tt.check(t, c)
+				return
+			}
+			if err == nil {
+				t.Fatal("LoadETL() succeeded, want an error")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadETL() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadAggregatorConditionalTables(t *testing.T) {
+	env := map[string]string{
+		"DYNAMODB_TABLE_STATIONS":             "stats",
+		"S3_BUCKET":                           "bucket",
+		"DYNAMODB_TABLE_ETL_RUNS":             "runs",
+		"DYNAMODB_TABLE_INCIDENTS":            "incidents",
+		"DYNAMODB_TABLE_FIX_DATE_RELIABILITY": "reliability",
+		"DYNAMODB_TABLE_DAY_ROLLUPS":          "rollups",
+	}
+	c, err := LoadAggregator(mapSource(t, env))
+	if err != nil {
+		t.Fatalf("LoadAggregator() without heartbeat nor retention error = %v", err)
+	}
+	if c.RollupDays != 3 || c.ObservationGapThreshold != 90*time.Minute || c.Heartbeat != 0 {
+		t.Errorf("LoadAggregator() = %+v", c)
+	}
+
+	env["STATUS_HEARTBEAT_INTERVAL"] = "6h"
+	env["STATUS_RETENTION"] = "2160h"
+	_, err = LoadAggregator(mapSource(t, env))
+	for _, want := range []string{"DYNAMODB_TABLE_DAY_COUNTS", "DYNAMODB_TABLE_HEATING_STATIONS", "DYNAMODB_TABLE_STATUSES"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadAggregator() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadRepair(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantErrs []string
+	}{
+		{
+			name: "history source",
+			env:  map[string]string{"DYNAMODB_TABLE_DAY_COUNTS": "counts", "DYNAMODB_TABLE_STATIONS": "stations", "DYNAMODB_TABLE_STATUSES": "statuses"},
+		},
+		{
+			name: "archive source needs the bucket only",
+			env:  map[string]string{"REPAIR_SOURCE": "archive", "DYNAMODB_TABLE_DAY_COUNTS": "counts", "S3_BUCKET": "bucket"},
+		},
+		{
+			name:     "station weights key needs the bucket",
+			env:      map[string]string{"DYNAMODB_TABLE_DAY_COUNTS": "counts", "DYNAMODB_TABLE_STATIONS": "stations", "DYNAMODB_TABLE_STATUSES": "statuses", "STATION_WEIGHTS_KEY": "station_weights/station_weights.csv"},
+			wantErrs: []string{"S3_BUCKET is required"},
+		},
+		{
+			name:     "history source needs its tables",
+			env:      map[string]string{"DYNAMODB_TABLE_DAY_COUNTS": "counts", "SCHEDULE_INTERVAL": "0s"},
+			wantErrs: []string{"DYNAMODB_TABLE_STATIONS is required", "DYNAMODB_TABLE_STATUSES is required", "SCHEDULE_INTERVAL must be positive"},
+		},
+		{
+			name:     "unknown source",
+			env:      map[string]string{"STORAGE_DIR": "/tmp/data", "REPAIR_SOURCE": "tape"},
+			wantErrs: []string{`REPAIR_SOURCE: unknown source "tape"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := LoadRepair(mapSource(t, tt.env))
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("LoadRepair() error = %v", err)
+				}
+				if c.ScheduleInterval != 30*time.Minute || c.InactiveGracePeriod != 24*time.Hour || c.Heartbeat != 0 {
+					t.Errorf("LoadRepair() = %+v", c)
+				}
+				return
+			}
+			for _, want := range tt.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadRepair() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadAPI(t *testing.T) {
+	c, err := LoadAPI(mapSource(t, map[string]string{"DYNAMODB_TABLE_DAY_COUNTS": "counts"}), "DYNAMODB_TABLE_DAY_COUNTS")
+	if err != nil {
+		t.Fatalf("LoadAPI() error = %v", err)
+	}
+	if c.DayCountsTable != "counts" || c.AllowOrigin != "*" {
+		t.Errorf("LoadAPI() = %+v", c)
+	}
+
+	c, err = LoadAPI(mapSource(t, map[string]string{"ACCESS_CONTROL_ALLOW_ORIGIN": "https://example.org"}), "DYNAMODB_TABLE_STATIONS", "S3_BUCKET")
+	if err == nil || !strings.Contains(err.Error(), "DYNAMODB_TABLE_STATIONS") || !strings.Contains(err.Error(), "S3_BUCKET") {
+		t.Errorf("LoadAPI() error = %v, want both missing variables", err)
+	}
+	if c.AllowOrigin != "https://example.org" {
+		t.Errorf("LoadAPI() AllowOrigin = %q", c.AllowOrigin)
+	}
+
+	if _, err := LoadAPI(mapSource(t, nil), "DYNAMODB_TABLE_NOPE"); err == nil {
+		t.Error("LoadAPI() with an unknown variable succeeded")
+	}
+}
+
+func TestSourceOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"ROLLUP_DAYS": 30, "DRY_RUN": true, "S3_BUCKET": "file-bucket"}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := mapSource(t, map[string]string{
+		"S3_BUCKET":         "env-bucket",
+		"METRICS_NAMESPACE": "env-namespace",
+		"ROLLUP_DAYS":       "3",
+		ConfigFileVariable:  path,
+		// the payload wins over the file
+		ConfigJSONVariable: `{"S3_BUCKET": "payload-bucket", "METRICS_NAMESPACE": null}`,
+	})
+
+	tests := map[string]string{
+		"S3_BUCKET":         "payload-bucket",
+		"ROLLUP_DAYS":       "30",
+		"DRY_RUN":           "true",
+		"METRICS_NAMESPACE": "",
+	}
+	for name, want := range tests {
+		if got := source.Lookup(name); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	// a command line flag wins over everything
+	source.Set("S3_BUCKET", "flag-bucket")
+	if got := source.Lookup("S3_BUCKET"); got != "flag-bucket" {
+		t.Errorf("Lookup(S3_BUCKET) after Set = %q, want %q", got, "flag-bucket")
+	}
+
+	_, err = NewSource(func(name string) (string, bool) {
+		if name == ConfigJSONVariable {
+			return `{"ROLLUP_DAYS": [1]}`, true
+		}
+		return "", false
+	})
+	if err == nil {
+		t.Error("NewSource() with an array value succeeded")
+	}
+}
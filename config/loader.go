@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// loader reads typed variables from a source, and keeps every error instead
+// of stopping at the first one.
+type loader struct {
+	source *Source
+	errs   []error
+}
+
+func (l *loader) string(name, fallback string) string {
+	if value := l.source.Lookup(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// required reads a variable which must be set, unless the configuration
+// stores everything in a local directory.
+func (l *loader) required(name string, local bool) string {
+	value := l.source.Lookup(name)
+	if value == "" && !local {
+		l.errs = append(l.errs, fmt.Errorf("%s is required", name))
+	}
+	return value
+}
+
+func (l *loader) duration(name string, fallback time.Duration) time.Duration {
+	value := l.source.Lookup(name)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: invalid duration %q", name, value))
+		return fallback
+	}
+	if duration < 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s: negative duration %q", name, value))
+	}
+	return duration
+}
+
+func (l *loader) bool(name string, fallback bool) bool {
+	value := l.source.Lookup(name)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: invalid boolean %q", name, value))
+		return fallback
+	}
+	return b
+}
+
+func (l *loader) float(name string, fallback float64) float64 {
+	value := l.source.Lookup(name)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: invalid number %q", name, value))
+		return fallback
+	}
+	return f
+}
+
+func (l *loader) int(name string, fallback int) int {
+	value := l.source.Lookup(name)
+	if value == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: invalid integer %q", name, value))
+		return fallback
+	}
+	return i
+}
+
+// check records an error when a rule between settings is broken.
+func (l *loader) check(ok bool, format string, args ...any) {
+	if !ok {
+		l.errs = append(l.errs, fmt.Errorf(format, args...))
+	}
+}
+
+func (l *loader) err() error {
+	return errors.Join(l.errs...)
+}
@@ -0,0 +1,93 @@
+// Package config loads the typed configuration of the ETL, the aggregator and
+// the API functions. Settings are read from the environment, and can be
+// overridden by a JSON file or a JSON payload. A configuration is validated as
+// a whole, so every problem is reported at once.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Variables pointing to the overrides of the environment.
+const (
+	// ConfigFileVariable is the path of a JSON object of variables
+	ConfigFileVariable = "CONFIG_FILE"
+	// ConfigJSONVariable is a JSON object of variables, applied after the file
+	ConfigJSONVariable = "CONFIG_JSON"
+)
+
+// Source gives the value of a variable: the last override setting it, or the
+// environment.
+type Source struct {
+	lookupEnv func(string) (string, bool)
+	overrides map[string]string
+}
+
+// NewSource reads the environment with lookupEnv, and applies the overrides
+// of the file and payload the environment points to.
+func NewSource(lookupEnv func(string) (string, bool)) (*Source, error) {
+	source := &Source{lookupEnv: lookupEnv, overrides: make(map[string]string)}
+
+	if path, _ := lookupEnv(ConfigFileVariable); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", ConfigFileVariable, err)
+		}
+		if err := source.Override(data); err != nil {
+			return nil, fmt.Errorf("invalid %s %s: %w", ConfigFileVariable, path, err)
+		}
+	}
+	if payload, _ := lookupEnv(ConfigJSONVariable); payload != "" {
+		if err := source.Override([]byte(payload)); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", ConfigJSONVariable, err)
+		}
+	}
+	return source, nil
+}
+
+// FromEnvironment returns the source of the process environment.
+func FromEnvironment() (*Source, error) {
+	return NewSource(os.LookupEnv)
+}
+
+// Override sets the variables of a JSON object, whose values are strings,
+// numbers or booleans. A null value unsets the variable.
+func (s *Source) Override(data []byte) error {
+	values := make(map[string]any)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		switch v := value.(type) {
+		case string:
+			s.overrides[name] = v
+		case float64:
+			s.overrides[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			s.overrides[name] = strconv.FormatBool(v)
+		case nil:
+			s.overrides[name] = ""
+		default:
+			return fmt.Errorf("%s is not a string, number or boolean", name)
+		}
+	}
+	return nil
+}
+
+// Set overrides a single variable, an empty value unsets it.
+func (s *Source) Set(name, value string) {
+	s.overrides[name] = value
+}
+
+// Lookup returns the value of a variable, empty when unset.
+func (s *Source) Lookup(name string) string {
+	if value, overridden := s.overrides[name]; overridden {
+		return value
+	}
+	value, _ := s.lookupEnv(name)
+	return value
+}
@@ -0,0 +1,36 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+)
+
+// publishCurrentState publishes the document of the stations as stored after
+// the snapshot. A document superseded by the one of a later run, such as when
+// a retry of an old slot ends after the next slot, is not an error.
+func (p *Pipeline) publishCurrentState(ctx context.Context, snap *snapshot) error {
+	ctx, span := tracing.Start(ctx, "etl.current_state")
+	defer span.End()
+
+	state := scrapper.NewCurrentState(snap.previous, snap.changes.Stations, snap.result.Counts.Time)
+	span.SetAttribute("etl.current_state_version", state.Version)
+
+	err := p.CurrentState.PublishCurrentState(ctx, state)
+	if errors.Is(err, storage.ErrCurrentStateSuperseded) {
+		slog.Warn("Current state superseded by a more recent snapshot, not published", "version", state.Version)
+		return nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("unable to publish current state %s: %w", state.Version, err)
+	}
+	snap.result.CurrentStateVersion = state.Version
+	slog.Info("Current state published", "version", state.Version, "numStations", len(state.Stations))
+	return nil
+}
@@ -0,0 +1,49 @@
+package etl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+)
+
+func TestPublishCurrentState(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	p := &Pipeline{CurrentState: store}
+
+	newSnapshot := func(fetchTime int64) *snapshot {
+		snap := &snapshot{previous: map[int64]scrapper.HeatingStation{
+			1: {GeoId: 1, LastStatus: "working", Active: true},
+		}}
+		snap.changes.Stations = []scrapper.HeatingStation{{GeoId: 2, LastStatus: "broken", Active: true}}
+		snap.result.Counts.Time = fetchTime
+		return snap
+	}
+
+	later := newSnapshot(2000)
+	if err := p.publishCurrentState(ctx, later); err != nil {
+		t.Fatalf("publishCurrentState() error = %v", err)
+	}
+	if later.result.CurrentStateVersion == "" {
+		t.Fatalf("CurrentStateVersion is empty, want the published version")
+	}
+	state, err := store.GetCurrentState(ctx, later.result.CurrentStateVersion)
+	if err != nil || state.SnapshotTime != 2000 || len(state.Stations) != 2 {
+		t.Errorf("GetCurrentState() = %+v, %v, want both stations at 2000", state, err)
+	}
+
+	// a retry of an earlier slot ending last leaves the pointer on the later snapshot
+	earlier := newSnapshot(1000)
+	if err := p.publishCurrentState(ctx, earlier); err != nil {
+		t.Fatalf("publishCurrentState() of a superseded snapshot error = %v", err)
+	}
+	if earlier.result.CurrentStateVersion != "" {
+		t.Errorf("CurrentStateVersion = %q, want empty for a superseded snapshot", earlier.result.CurrentStateVersion)
+	}
+	pointer, _, _ := store.CurrentStatePointer(ctx)
+	if pointer.Version != later.result.CurrentStateVersion {
+		t.Errorf("pointer = %+v, want the later version %s", pointer, later.result.CurrentStateVersion)
+	}
+}
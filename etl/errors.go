@@ -0,0 +1,68 @@
+package etl
+
+import (
+	"context"
+	"errors"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+// Error classes recorded in the run ledger, they tell at which step a run failed.
+const (
+	ErrorClassFetch        = "fetch"
+	ErrorClassHTTPStatus   = "http_status"
+	ErrorClassParse        = "parse"
+	ErrorClassStorageRead  = "storage_read"
+	ErrorClassStorageWrite = "storage_write"
+	ErrorClassTimeout      = "timeout"
+	ErrorClassImplausible  = "implausible"
+	ErrorClassUnknown      = "unknown"
+)
+
+// classifiedError is an error tagged with the step it happened at.
+type classifiedError struct {
+	class string
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func classify(class string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// pullErrorClass tells a site that could not be reached from a page that could not be parsed.
+func pullErrorClass(err error, info scrapper.FetchInfo) string {
+	switch {
+	case errors.Is(err, scrapper.ErrUnexpectedHTTPStatus):
+		return ErrorClassHTTPStatus
+	case info.HTTPStatus == 0 || info.PageHash == "":
+		return ErrorClassFetch
+	default:
+		return ErrorClassParse
+	}
+}
+
+// ErrorClass returns the class of an error returned by a run, an empty string for a nil error.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+	return ErrorClassUnknown
+}
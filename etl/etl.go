@@ -0,0 +1,509 @@
+// Package etl implements one scrape and persist run, shared by the ETL lambda
+// and the local collector.
+package etl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/cloudevents"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/metrics"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+)
+
+// Pipeline pulls the map page and persists the snapshot to the repositories.
+type Pipeline struct {
+	Scrapper *scrapper.TermoficareScrapper
+	Weights  *scrapper.StationWeights
+	Counts   storage.DayCountsRepository
+	Stations storage.StationRepository
+	Statuses storage.StatusHistoryRepository
+	// Runs records every run in the ledger, runs are not recorded when nil
+	Runs storage.RunLedgerRepository
+	// Incidents are opened, updated and closed from every snapshot, they are not tracked when nil
+	Incidents storage.IncidentRepository
+	// ChangeOnly persists only the changed stations and the due heartbeats
+	ChangeOnly bool
+	Heartbeat  time.Duration
+	// InactiveGracePeriod is how long a station can be missing before it is marked inactive
+	InactiveGracePeriod time.Duration
+	// StatusRetention is the TTL of the status rows, zero keeps them forever
+	StatusRetention time.Duration
+	// Rules are checked before any write, a snapshot breaking one of them is
+	// quarantined unless ForceAccept is set
+	Rules       PlausibilityRules
+	Quarantine  storage.SnapshotQuarantine
+	ForceAccept bool
+	// Outbox keeps the domain events of the snapshots, queued before the
+	// state they announce, until Publisher delivers them. No event is built
+	// when either is nil
+	Outbox    storage.EventOutbox
+	Publisher cloudevents.Publisher
+	// CurrentState publishes the document of every station after each run, no
+	// document is published when nil
+	CurrentState storage.CurrentStateStore
+	// Slots keys the scheduled runs by the slot of their event time, rounded
+	// down to SlotInterval, runs are not keyed when nil
+	Slots        storage.RunSlotRepository
+	SlotInterval time.Duration
+	// Metrics receives the metrics of every run, none are written when nil
+	Metrics *metrics.Emitter
+}
+
+// RunResult describes what a run fetched and wrote.
+type RunResult struct {
+	RunId               string
+	FetchTime           time.Time
+	FetchInfo           scrapper.FetchInfo
+	Counts              scrapper.StationStatesCount
+	NumStatuses         int
+	NumStationsWritten  int
+	NumStatusesWritten  int
+	NumUnchanged        int
+	NumDeactivated      int
+	NumReactivated      int
+	NumIssues           int
+	NumIncidentsOpened  int
+	NumIncidentsClosed  int
+	NumIncidentsUpdated int
+	// NumWriteFailures counts the repositories a write failed on
+	NumWriteFailures int
+	// NumEventsQueued counts the events of the run written to the outbox,
+	// the published and pending ones include those of earlier runs
+	NumEventsQueued    int
+	NumEventsPublished int
+	NumEventsPending   int
+	// CurrentStateVersion is the version of the current state document the
+	// run published, empty when it published none
+	CurrentStateVersion string
+	// Slot is the start of the schedule slot of the run in Unix seconds, 0 for
+	// a run without slot
+	Slot int64
+	// Duplicate is set when the slot of the run was already taken, nothing was
+	// done. The run fails with ErrRunSlotHeld unless the slot was completed.
+	Duplicate bool
+}
+
+// OpenIncidents is the number of stations with an issue or without hot water.
+func (r RunResult) OpenIncidents() int {
+	return r.Counts.NumYellow + r.Counts.NumRed
+}
+
+// snapshot is a converted page compared with the stored state, ready to be written.
+type snapshot struct {
+	result   RunResult
+	statuses []scrapper.HeatingStationStatus
+	previous map[int64]scrapper.HeatingStation
+	changes  scrapper.SnapshotChanges
+	// incidents are the incidents opened, updated or closed by the snapshot
+	incidents scrapper.IncidentChanges
+	issues    []ValidationIssue
+	// violations are the plausibility rules the snapshot breaks
+	violations []PlausibilityViolation
+}
+
+// Run pulls the page, persists the snapshot and records the run in the ledger.
+func (p *Pipeline) Run(ctx context.Context) (RunResult, error) {
+	return p.RunScheduled(ctx, time.Time{})
+}
+
+// RunScheduled runs for the schedule event of scheduledAt. With a slot
+// repository the run claims the slot of scheduledAt, and records its snapshot
+// at the slot time: a duplicate delivery of the event does nothing, and a
+// retry after a failed attempt skips the writes already done and rewrites the
+// other rows under the same keys. A zero scheduledAt, such as the one of a
+// manual invocation, runs like Run.
+func (p *Pipeline) RunScheduled(ctx context.Context, scheduledAt time.Time) (RunResult, error) {
+	start := time.Now()
+	runId := newRunId(start)
+	ctx, span := tracing.Start(ctx, "etl.run")
+	span.SetAttribute("etl.run_id", runId)
+	defer span.End()
+
+	var slot *storage.RunSlot
+	snapshotTime := start
+	if p.Slots != nil && p.SlotInterval > 0 && !scheduledAt.IsZero() {
+		var err error
+		slot, err = p.claimSlot(ctx, runId, scheduledAt, start)
+		if (err == nil && slot == nil) || errors.Is(err, ErrRunSlotHeld) {
+			p.Metrics.NewEntry().Count("DuplicateRuns", 1).Property("runId", runId).Emit()
+			return RunResult{RunId: runId, Duplicate: true}, err
+		}
+		if err != nil {
+			span.RecordError(err)
+			result := RunResult{RunId: runId, Slot: SlotTime(scheduledAt, p.SlotInterval).Unix()}
+			p.finishRun(ctx, start, result, err)
+			return result, err
+		}
+		snapshotTime = time.Unix(slot.Slot, 0)
+		span.SetAttribute("etl.slot", slot.Slot)
+	}
+
+	result, err := p.run(ctx, runId, snapshotTime, slot)
+	result.RunId = runId
+	if slot != nil {
+		result.Slot = slot.Slot
+	}
+	span.RecordError(err)
+
+	if slot != nil {
+		p.releaseSlot(ctx, slot, err)
+	}
+	if p.Outbox != nil && p.Publisher != nil {
+		result.NumEventsPublished, result.NumEventsPending = p.deliverEvents(ctx)
+	}
+	p.finishRun(ctx, start, result, err)
+	return result, err
+}
+
+// finishRun records the run in the ledger and writes its metrics.
+func (p *Pipeline) finishRun(ctx context.Context, start time.Time, result RunResult, err error) {
+	if p.Runs != nil {
+		p.recordRun(ctx, start, result, err)
+	}
+	p.emitRunMetrics(start, result, err)
+}
+
+// run writes the snapshot taken at snapshotTime, skipping the steps of the
+// slot an earlier attempt did.
+func (p *Pipeline) run(ctx context.Context, runId string, snapshotTime time.Time, slot *storage.RunSlot) (RunResult, error) {
+	snap, err := p.prepare(ctx, snapshotTime)
+	if err != nil {
+		return snap.result, err
+	}
+	if len(snap.issues) > 0 {
+		logValidationIssues(snap.issues)
+	}
+
+	if len(snap.violations) > 0 {
+		if !p.ForceAccept {
+			return snap.result, classify(ErrorClassImplausible, p.quarantine(ctx, runId, snap))
+		}
+		slog.Warn("Implausible snapshot accepted by override", "runId", runId, "violations", snap.violations)
+	}
+
+	ctx, span := tracing.Start(ctx, "etl.persist")
+	defer span.End()
+	span.SetAttribute("etl.num_stations", len(snap.changes.Stations))
+	span.SetAttribute("etl.num_statuses", len(snap.changes.Statuses))
+	span.SetAttribute("etl.num_incidents", len(snap.incidents.Incidents))
+
+	done := func(step string) bool {
+		return slot != nil && slot.HasStep(step)
+	}
+	// write runs a step unless it is done, and records it in the slot when it succeeds
+	write := func(step string, put func() error) (bool, error) {
+		if done(step) {
+			return false, nil
+		}
+		if err := put(); err != nil {
+			return false, err
+		}
+		if slot != nil {
+			p.recordStep(ctx, slot, step)
+		}
+		return true, nil
+	}
+
+	_, err = write(stepCounts, func() error {
+		return p.Counts.PutCounts(ctx, snap.result.Counts)
+	})
+	if err != nil {
+		span.RecordError(err)
+		snap.result.NumWriteFailures = 1
+		return snap.result, classify(ErrorClassStorageWrite, fmt.Errorf("unable to write day count items: %w", err))
+	}
+
+	if p.Outbox != nil && p.Publisher != nil {
+		snap.result.NumEventsQueued, err = p.enqueueEvents(ctx, snap)
+		if err != nil {
+			span.RecordError(err)
+			snap.result.NumWriteFailures = 1
+			return snap.result, classify(ErrorClassStorageWrite, err)
+		}
+	}
+
+	// every write is attempted even if one of them fails
+	stationsWritten, stationsErr := write(stepStations, func() error {
+		return p.Stations.PutStations(ctx, snap.changes.Stations)
+	})
+	statusesWritten, statusesErr := write(stepStatuses, func() error {
+		return p.Statuses.PutStatuses(ctx, snap.changes.Statuses)
+	})
+	var incidentsErr error
+	if p.Incidents != nil && len(snap.incidents.Incidents) > 0 {
+		_, incidentsErr = write(stepIncidents, func() error {
+			return p.Incidents.PutIncidents(ctx, snap.incidents.Incidents)
+		})
+	}
+	// the document is the table after the snapshot, it is only published once the stations are written
+	var stateErr error
+	if p.CurrentState != nil && stationsErr == nil {
+		_, stateErr = write(stepCurrentState, func() error {
+			return p.publishCurrentState(ctx, &snap)
+		})
+	}
+	if !stationsWritten {
+		snap.result.NumStationsWritten = 0
+	}
+	if !statusesWritten {
+		snap.result.NumStatusesWritten = 0
+	}
+
+	for _, err := range []error{stationsErr, statusesErr, incidentsErr, stateErr} {
+		if err != nil {
+			snap.result.NumWriteFailures++
+		}
+	}
+
+	err = errors.Join(stationsErr, statusesErr, incidentsErr, stateErr)
+	span.RecordError(err)
+	return snap.result, classify(ErrorClassStorageWrite, err)
+}
+
+// quarantine keeps the refused snapshot with its raw page, and returns the
+// error the run fails with.
+func (p *Pipeline) quarantine(ctx context.Context, runId string, snap snapshot) error {
+	// the quarantine alarm is a metric filter on this message
+	slog.Error("Snapshot quarantined", "runId", runId, "violations", snap.violations)
+	implausibleErr := fmt.Errorf("%w: %d plausibility rules broken", ErrImplausibleSnapshot, len(snap.violations))
+
+	if p.Quarantine == nil {
+		return implausibleErr
+	}
+	report := newReport(snap, false)
+	report.DryRun = false
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return errors.Join(implausibleErr, fmt.Errorf("unable to marshal quarantine report: %w", err))
+	}
+	err = p.Quarantine.QuarantineSnapshot(ctx, storage.QuarantinedSnapshot{
+		RunId:     runId,
+		FetchTime: snap.result.FetchTime,
+		Page:      p.Scrapper.RawPage(),
+		Report:    reportJSON,
+	})
+	if err != nil {
+		return errors.Join(implausibleErr, fmt.Errorf("unable to quarantine snapshot: %w", err))
+	}
+	return implausibleErr
+}
+
+// recordRun writes the ledger row of a run. A ledger failure is logged and does
+// not fail the run, whose data is already written.
+func (p *Pipeline) recordRun(ctx context.Context, start time.Time, result RunResult, runErr error) {
+	const recordTimeout = 10 * time.Second
+
+	run := scrapper.EtlRun{
+		RunId:               result.RunId,
+		Slot:                result.Slot,
+		StartTime:           start.Unix(),
+		EndTime:             time.Now().Unix(),
+		FetchLatencyMs:      result.FetchInfo.Latency.Milliseconds(),
+		HttpStatus:          result.FetchInfo.HTTPStatus,
+		PageHash:            result.FetchInfo.PageHash,
+		PageSize:            result.FetchInfo.PageSize,
+		NumParsed:           result.NumStatuses,
+		NumStationsWritten:  result.NumStationsWritten,
+		NumStatusesWritten:  result.NumStatusesWritten,
+		NumValidationIssues: result.NumIssues,
+		Success:             runErr == nil,
+		ErrorClass:          ErrorClass(runErr),
+	}
+	if runErr != nil {
+		// the writes of a failed run are missing or partial
+		run.NumStationsWritten = 0
+		run.NumStatusesWritten = 0
+		run.ErrorMessage = runErr.Error()
+	}
+
+	// the ledger is written even when the run ran out of time
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := p.Runs.PutRun(recordCtx, run); err != nil {
+		slog.Error("Failed to record run in the ledger", "runId", run.RunId, "error_msg", err.Error())
+	}
+}
+
+// emitRunMetrics writes the data health metrics of a run. The metrics of a run
+// failing before the page was parsed are zero, the Success metric tells them apart.
+func (p *Pipeline) emitRunMetrics(start time.Time, result RunResult, runErr error) {
+	success := 0
+	if runErr == nil {
+		success = 1
+	}
+	p.Metrics.NewEntry().
+		Count("StationsParsed", result.NumStatuses).
+		Count("StationsRed", result.Counts.NumRed).
+		Count("StationsYellow", result.Counts.NumYellow).
+		Duration("FetchLatency", result.FetchInfo.Latency).
+		Count("WriteFailures", result.NumWriteFailures).
+		Count("ValidationIssues", result.NumIssues).
+		Count("StatusesWritten", result.NumStatusesWritten).
+		Count("EventsPublished", result.NumEventsPublished).
+		Count("EventsPending", result.NumEventsPending).
+		Count("Success", success).
+		Duration("RunDuration", time.Since(start)).
+		Property("runId", result.RunId).
+		Property("errorClass", ErrorClass(runErr)).
+		Emit()
+}
+
+// newRunId returns an id sorting like the run start time.
+func newRunId(start time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return start.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// DryRun pulls, converts and validates the page and compares it with the
+// stored state like Run does, but reports what would be written instead of
+// writing it.
+func (p *Pipeline) DryRun(ctx context.Context) (Report, error) {
+	snap, err := p.prepare(ctx, time.Now())
+	if err != nil {
+		return Report{DryRun: true, FetchTime: snap.result.FetchTime}, err
+	}
+
+	report := newReport(snap, p.ForceAccept)
+	report.Log()
+	return report, nil
+}
+
+// prepare runs every step of a run up to the writes, for a snapshot recorded
+// at snapshotTime.
+func (p *Pipeline) prepare(ctx context.Context, snapshotTime time.Time) (snapshot, error) {
+	snap := snapshot{}
+
+	_, fetchSpan := tracing.Start(ctx, "etl.fetch")
+	err := p.Scrapper.PullDataAt(snapshotTime)
+	snap.result.FetchTime = p.Scrapper.FetchTime()
+	snap.result.FetchInfo = p.Scrapper.FetchInfo()
+	fetchSpan.SetAttribute("http.status_code", snap.result.FetchInfo.HTTPStatus)
+	fetchSpan.SetAttribute("etl.page_size", snap.result.FetchInfo.PageSize)
+	fetchSpan.RecordError(err)
+	fetchSpan.End()
+	if err != nil {
+		return snap, classify(pullErrorClass(err, snap.result.FetchInfo), fmt.Errorf("unable to pull data: %w", err))
+	}
+
+	_, parseSpan := tracing.Start(ctx, "etl.parse")
+	counts, err := p.Scrapper.GetStatesCounts()
+	if err != nil {
+		parseSpan.RecordError(err)
+		parseSpan.End()
+		return snap, classify(ErrorClassParse, fmt.Errorf("unable to get states counts: %w", err))
+	}
+
+	statuses, err := p.Scrapper.GetHeatingStationsStatuses()
+	parseSpan.SetAttribute("etl.num_statuses", len(statuses))
+	parseSpan.RecordError(err)
+	parseSpan.End()
+	if err != nil {
+		return snap, classify(ErrorClassParse, fmt.Errorf("unable to get heating stations statuses: %w", err))
+	}
+	snap.statuses = statuses
+	snap.result.NumStatuses = len(statuses)
+
+	impact := scrapper.ComputeImpactCounts(statuses, p.Weights)
+	counts.ResidentsWithoutHotWater = impact.ResidentsWithoutHotWater
+	counts.ResidentsWithIssues = impact.ResidentsWithIssues
+	counts.UnweightedStations = len(impact.UnweightedStations)
+	if len(impact.UnweightedStations) > 0 {
+		logUnweightedStations(impact.UnweightedStations)
+	}
+
+	// the stored state is needed in both modes to track the stations lifecycle,
+	// and is the previous snapshot the churn counts compare with
+	compareCtx, compareSpan := tracing.Start(ctx, "etl.compare")
+	defer compareSpan.End()
+	snap.previous, err = p.storedStations(compareCtx)
+	if err != nil {
+		compareSpan.RecordError(err)
+		return snap, classify(ErrorClassStorageRead, fmt.Errorf("unable to get stored stations: %w", err))
+	}
+
+	scrapper.ApplySnapshotCounts(&counts, snap.previous, statuses)
+	snap.result.Counts = counts
+	snap.issues = Validate(counts, statuses)
+	snap.result.NumIssues = len(snap.issues)
+	snap.violations = CheckPlausibility(p.Rules, snap.previous, counts, statuses)
+
+	opts := scrapper.SnapshotOptions{
+		FetchTime:           counts.Time,
+		InactiveGracePeriod: p.InactiveGracePeriod,
+	}
+	// a zero heartbeat persists every status
+	if p.ChangeOnly {
+		opts.Heartbeat = p.Heartbeat
+	}
+	changes := scrapper.DiffSnapshot(snap.previous, statuses, opts)
+	scrapper.ApplyStatusRetention(changes.Statuses, p.StatusRetention)
+	snap.changes = changes
+	snap.result.NumStationsWritten = len(changes.Stations)
+	snap.result.NumStatusesWritten = len(changes.Statuses)
+	snap.result.NumUnchanged = changes.NumUnchanged
+	snap.result.NumDeactivated = changes.NumDeactivated
+	snap.result.NumReactivated = changes.NumReactivated
+	if p.Incidents != nil {
+		open, err := p.Incidents.ListOpenIncidents(compareCtx)
+		if err != nil {
+			compareSpan.RecordError(err)
+			return snap, classify(ErrorClassStorageRead, fmt.Errorf("unable to get open incidents: %w", err))
+		}
+		snap.incidents = scrapper.UpdateIncidents(open, statuses, counts.Time)
+		snap.result.NumIncidentsOpened = snap.incidents.NumOpened
+		snap.result.NumIncidentsClosed = snap.incidents.NumClosed
+		snap.result.NumIncidentsUpdated = snap.incidents.NumUpdated
+	}
+
+	slog.InfoContext(ctx, "Snapshot compared with stored state",
+		"changeOnly", p.ChangeOnly,
+		"numStatuses", len(statuses),
+		"numStationsToWrite", len(changes.Stations),
+		"numStatusesToWrite", len(changes.Statuses),
+		"numUnchanged", changes.NumUnchanged,
+		"numDeactivated", changes.NumDeactivated,
+		"numReactivated", changes.NumReactivated,
+		"numIncidentsOpened", snap.result.NumIncidentsOpened,
+		"numIncidentsClosed", snap.result.NumIncidentsClosed,
+		"numIncidentsUpdated", snap.result.NumIncidentsUpdated,
+	)
+
+	return snap, nil
+}
+
+// storedStations returns the last persisted state of every station.
+func (p *Pipeline) storedStations(ctx context.Context) (map[int64]scrapper.HeatingStation, error) {
+	stations, err := p.Stations.ListStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	storedStations := make(map[int64]scrapper.HeatingStation, len(stations))
+	for _, station := range stations {
+		storedStations[station.GeoId] = station
+	}
+	return storedStations, nil
+}
+
+func logUnweightedStations(stations []scrapper.HeatingStationStatus) {
+	const maxLoggedNames = 20
+
+	names := make([]string, 0, maxLoggedNames)
+	for _, station := range stations[:min(len(stations), maxLoggedNames)] {
+		names = append(names, station.Name)
+	}
+	slog.Warn("Stations missing from the weights table, default weight used",
+		"numStations", len(stations),
+		"names", names,
+	)
+}
@@ -0,0 +1,185 @@
+package etl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/cloudevents"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+)
+
+// EventSource is the source of the domain events published by the ETL.
+const EventSource = "/termoficare/etl"
+
+// Domain event types, the data of an incident event is the incident and the
+// data of a station event is the station.
+const (
+	EventIncidentOpened   = "ro.termoficare.incident.opened"
+	EventIncidentUpdated  = "ro.termoficare.incident.updated"
+	EventIncidentResolved = "ro.termoficare.incident.resolved"
+	EventStationAdded     = "ro.termoficare.station.added"
+	EventStationRemoved   = "ro.termoficare.station.removed"
+)
+
+const (
+	// outboxRetention drops the events no run could deliver for that long
+	outboxRetention = 7 * 24 * time.Hour
+	// maxPendingEvents is the number of outbox events a run tries to deliver
+	maxPendingEvents = 1000
+	// eventsPerPublish is the number of events handed to the publisher at once,
+	// a refused batch stops the delivery until the next run
+	eventsPerPublish = 100
+	deliveryTimeout  = 20 * time.Second
+)
+
+// incidentEvents returns an event per incident opened, updated or resolved by
+// the snapshot taken at fetchTime.
+func incidentEvents(incidents []scrapper.Incident, fetchTime time.Time) ([]cloudevents.Event, error) {
+	events := make([]cloudevents.Event, 0, len(incidents))
+	for _, incident := range incidents {
+		eventType := EventIncidentUpdated
+		switch {
+		case !incident.IsOpen():
+			eventType = EventIncidentResolved
+		case incident.StartTime == fetchTime.Unix():
+			eventType = EventIncidentOpened
+		}
+
+		event, err := cloudevents.New(EventSource, eventType, "incidents/"+incident.IncidentId, fetchTime, incident)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// stationEvents returns an event per station appearing on the map, new or
+// back after being deactivated, and per station deactivated by the snapshot.
+func stationEvents(previous map[int64]scrapper.HeatingStation, stations []scrapper.HeatingStation, fetchTime time.Time) ([]cloudevents.Event, error) {
+	events := make([]cloudevents.Event, 0)
+	for _, station := range stations {
+		last, exists := previous[station.GeoId]
+		lastActive := exists && (last.Active || last.FirstSeen == 0)
+
+		var eventType string
+		switch {
+		case station.Active && !lastActive:
+			eventType = EventStationAdded
+		case !station.Active && lastActive:
+			eventType = EventStationRemoved
+		default:
+			continue
+		}
+
+		event, err := cloudevents.New(EventSource, eventType, "stations/"+strconv.FormatInt(station.GeoId, 10), fetchTime, station)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// enqueueEvents writes the events of the snapshot to the outbox, and returns
+// the number of events written. It runs before the state they announce is
+// written: once it is, the next run diffs against it and cannot rebuild them.
+// The event ids are deterministic, so a retry of the slot overwrites the
+// events of the failed attempt instead of adding others.
+func (p *Pipeline) enqueueEvents(ctx context.Context, snap snapshot) (int, error) {
+	events, err := incidentEvents(snap.incidents.Incidents, snap.result.FetchTime)
+	if err != nil {
+		return 0, err
+	}
+	stationEvents, err := stationEvents(snap.previous, snap.changes.Stations, snap.result.FetchTime)
+	if err != nil {
+		return 0, err
+	}
+	events = append(events, stationEvents...)
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	expiresAt := snap.result.FetchTime.Add(outboxRetention).Unix()
+	pending := make([]storage.OutboxEvent, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal event %s: %w", event.Id, err)
+		}
+		pending = append(pending, storage.OutboxEvent{
+			EventId:   event.Id,
+			Time:      event.Time.Unix(),
+			Payload:   string(payload),
+			ExpiresAt: expiresAt,
+		})
+	}
+	if err := p.Outbox.PutOutboxEvents(ctx, pending); err != nil {
+		return 0, fmt.Errorf("unable to write events to the outbox: %w", err)
+	}
+	return len(pending), nil
+}
+
+// deliverEvents publishes the pending events of the outbox, oldest first, and
+// removes the delivered ones. It runs after every run, failed or not, so the
+// events left by an earlier run are delivered by the next one. A delivery
+// failure is logged and does not fail the run, whose data is already written.
+func (p *Pipeline) deliverEvents(ctx context.Context) (delivered, pending int) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deliveryTimeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "etl.publish")
+	defer span.End()
+
+	outboxEvents, err := p.Outbox.ListOutboxEvents(ctx, maxPendingEvents)
+	if err != nil {
+		span.RecordError(err)
+		slog.Error("Failed to list the outbox events", "error_msg", err.Error())
+		return 0, 0
+	}
+	span.SetAttribute("etl.num_pending_events", len(outboxEvents))
+
+	for start := 0; start < len(outboxEvents); start += eventsPerPublish {
+		batch := outboxEvents[start:min(start+eventsPerPublish, len(outboxEvents))]
+		if err := p.publishOutboxEvents(ctx, batch); err != nil {
+			span.RecordError(err)
+			slog.Error("Failed to deliver the outbox events, they are retried on the next run",
+				"numDelivered", delivered,
+				"numPending", len(outboxEvents)-delivered,
+				"error_msg", err.Error(),
+			)
+			break
+		}
+		delivered += len(batch)
+	}
+	return delivered, len(outboxEvents) - delivered
+}
+
+func (p *Pipeline) publishOutboxEvents(ctx context.Context, outboxEvents []storage.OutboxEvent) error {
+	events := make([]cloudevents.Event, 0, len(outboxEvents))
+	eventIds := make([]string, 0, len(outboxEvents))
+	for _, outboxEvent := range outboxEvents {
+		var event cloudevents.Event
+		if err := json.Unmarshal([]byte(outboxEvent.Payload), &event); err != nil {
+			// an unreadable event would block the outbox until it expires
+			slog.Error("Dropping unreadable outbox event", "eventId", outboxEvent.EventId, "error_msg", err.Error())
+		} else {
+			events = append(events, event)
+		}
+		eventIds = append(eventIds, outboxEvent.EventId)
+	}
+
+	if err := p.Publisher.Publish(ctx, events); err != nil {
+		return err
+	}
+	// an event delivered but not deleted is delivered again, consumers dedupe on the event id
+	if err := p.Outbox.DeleteOutboxEvents(ctx, eventIds); err != nil {
+		return fmt.Errorf("unable to remove delivered events from the outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/cloudevents"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+)
+
+func TestIncidentEvents(t *testing.T) {
+	fetchTime := time.Unix(1000, 0)
+	incidents := []scrapper.Incident{
+		{IncidentId: "1-1000", GeoId: 1, StartTime: 1000},
+		{IncidentId: "2-500", GeoId: 2, StartTime: 500},
+		{IncidentId: "3-500", GeoId: 3, StartTime: 500, EndTime: 1000},
+	}
+
+	events, err := incidentEvents(incidents, fetchTime)
+	if err != nil {
+		t.Fatalf("incidentEvents() error = %v", err)
+	}
+	want := []struct {
+		eventType string
+		subject   string
+	}{
+		{EventIncidentOpened, "incidents/1-1000"},
+		{EventIncidentUpdated, "incidents/2-500"},
+		{EventIncidentResolved, "incidents/3-500"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("incidentEvents() returned %d events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		if events[i].Type != w.eventType || events[i].Subject != w.subject || events[i].Source != EventSource {
+			t.Errorf("event %d = %s %s from %s, want %s %s", i, events[i].Type, events[i].Subject, events[i].Source, w.eventType, w.subject)
+		}
+	}
+}
+
+func TestStationEvents(t *testing.T) {
+	previous := map[int64]scrapper.HeatingStation{
+		2: {GeoId: 2, FirstSeen: 100, Active: false},
+		3: {GeoId: 3, FirstSeen: 100, Active: true},
+		4: {GeoId: 4, FirstSeen: 100, Active: true},
+		// stored before the lifecycle was tracked, so on the map
+		5: {GeoId: 5},
+	}
+
+	tests := []struct {
+		name     string
+		station  scrapper.HeatingStation
+		wantType string
+	}{
+		{name: "new station", station: scrapper.HeatingStation{GeoId: 1, Active: true}, wantType: EventStationAdded},
+		{name: "reactivated station", station: scrapper.HeatingStation{GeoId: 2, Active: true}, wantType: EventStationAdded},
+		{name: "deactivated station", station: scrapper.HeatingStation{GeoId: 3, Active: false}, wantType: EventStationRemoved},
+		{name: "updated station", station: scrapper.HeatingStation{GeoId: 4, Active: true, LastStatus: "broken"}},
+		{name: "station without lifecycle", station: scrapper.HeatingStation{GeoId: 5, Active: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := stationEvents(previous, []scrapper.HeatingStation{tt.station}, time.Unix(1000, 0))
+			if err != nil {
+				t.Fatalf("stationEvents() error = %v", err)
+			}
+			if tt.wantType == "" {
+				if len(events) != 0 {
+					t.Errorf("stationEvents() = %+v, want no event", events)
+				}
+				return
+			}
+			if len(events) != 1 || events[0].Type != tt.wantType {
+				t.Fatalf("stationEvents() = %+v, want a single %s", events, tt.wantType)
+			}
+			if want := fmt.Sprintf("stations/%d", tt.station.GeoId); events[0].Subject != want {
+				t.Errorf("subject = %s, want %s", events[0].Subject, want)
+			}
+		})
+	}
+}
+
+// failingPublisher accepts a number of batches then refuses the others.
+type failingPublisher struct {
+	numAccepted int
+	published   []cloudevents.Event
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, events []cloudevents.Event) error {
+	if p.numAccepted == 0 {
+		return errors.New("sink unavailable")
+	}
+	p.numAccepted--
+	p.published = append(p.published, events...)
+	return nil
+}
+
+func TestDeliverEvents(t *testing.T) {
+	ctx := context.Background()
+	outbox := storage.NewMemoryStore()
+	publisher := &failingPublisher{numAccepted: 1}
+	p := &Pipeline{Outbox: outbox, Publisher: publisher}
+
+	incidents := make([]scrapper.Incident, eventsPerPublish+5)
+	for i := range incidents {
+		incidents[i] = scrapper.Incident{IncidentId: fmt.Sprintf("%d-1000", i), GeoId: int64(i), StartTime: 1000}
+	}
+	snap := snapshot{incidents: scrapper.IncidentChanges{Incidents: incidents}}
+	snap.result.FetchTime = time.Unix(1000, 0)
+
+	// a retry of the slot queues the same events again, which replace the first ones
+	for attempt := 0; attempt < 2; attempt++ {
+		numQueued, err := p.enqueueEvents(ctx, snap)
+		if err != nil || numQueued != len(incidents) {
+			t.Fatalf("enqueueEvents() = %d, %v, want %d", numQueued, err, len(incidents))
+		}
+	}
+
+	delivered, pending := p.deliverEvents(ctx)
+	if delivered != eventsPerPublish || pending != 5 {
+		t.Errorf("deliverEvents() = %d delivered %d pending, want %d and 5", delivered, pending, eventsPerPublish)
+	}
+	if len(publisher.published) != eventsPerPublish || publisher.published[0].Type != EventIncidentOpened {
+		t.Errorf("published %d events, want %d incident openings", len(publisher.published), eventsPerPublish)
+	}
+
+	// the next run delivers what is left
+	publisher.numAccepted = 1
+	delivered, pending = p.deliverEvents(ctx)
+	if delivered != 5 || pending != 0 {
+		t.Errorf("second deliverEvents() = %d delivered %d pending, want 5 and 0", delivered, pending)
+	}
+	if left, _ := outbox.ListOutboxEvents(ctx, maxPendingEvents); len(left) != 0 {
+		t.Errorf("outbox still holds %d events", len(left))
+	}
+}
@@ -0,0 +1,100 @@
+package etl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+// ErrImplausibleSnapshot is returned by a run whose snapshot broke a plausibility
+// rule, the snapshot is quarantined instead of being written.
+var ErrImplausibleSnapshot = errors.New("implausible snapshot")
+
+// minBaselineStations is the number of stored active stations below which the
+// ratio rules are skipped, so a new store can be filled.
+const minBaselineStations = 10
+
+// PlausibilityRules are checked against the stored state before a snapshot is
+// written. A zero ratio disables its rule.
+type PlausibilityRules struct {
+	// MaxStationDrop is the largest accepted drop of the station count, as a
+	// ratio of the stored active stations
+	MaxStationDrop float64
+	// MaxNewStations is the largest accepted ratio of stations never seen before
+	MaxNewStations float64
+	// RejectSingleCategory rejects a map with every station with an issue or
+	// every station without hot water. A map with every station working is
+	// plausible out of the heating season.
+	RejectSingleCategory bool
+}
+
+func DefaultPlausibilityRules() PlausibilityRules {
+	return PlausibilityRules{
+		MaxStationDrop:       0.2,
+		MaxNewStations:       0.3,
+		RejectSingleCategory: true,
+	}
+}
+
+// PlausibilityViolation is a rule broken by a snapshot.
+type PlausibilityViolation struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+// CheckPlausibility returns the rules the snapshot breaks compared with the stored stations.
+func CheckPlausibility(rules PlausibilityRules, previous map[int64]scrapper.HeatingStation, counts scrapper.StationStatesCount, statuses []scrapper.HeatingStationStatus) []PlausibilityViolation {
+	violations := make([]PlausibilityViolation, 0)
+
+	current := make(map[int64]bool, len(statuses))
+	for _, status := range statuses {
+		current[status.GeoId] = true
+	}
+
+	numActive := 0
+	for _, station := range previous {
+		if station.Active {
+			numActive++
+		}
+	}
+
+	if numActive >= minBaselineStations {
+		// an empty map is a full drop, not a map without stations to compare
+		if rules.MaxStationDrop > 0 && len(current) < numActive {
+			drop := float64(numActive-len(current)) / float64(numActive)
+			if drop > rules.MaxStationDrop {
+				violations = append(violations, PlausibilityViolation{
+					Rule:   "station_drop",
+					Detail: fmt.Sprintf("%d stations on the map for %d active stations, a %.0f%% drop above %.0f%%", len(current), numActive, drop*100, rules.MaxStationDrop*100),
+				})
+			}
+		}
+
+		if rules.MaxNewStations > 0 && len(current) > 0 {
+			numNew := 0
+			for geoId := range current {
+				if _, exists := previous[geoId]; !exists {
+					numNew++
+				}
+			}
+			newRatio := float64(numNew) / float64(len(current))
+			if newRatio > rules.MaxNewStations {
+				violations = append(violations, PlausibilityViolation{
+					Rule:   "new_stations",
+					Detail: fmt.Sprintf("%d of %d stations never seen before, %.0f%% above %.0f%%", numNew, len(current), newRatio*100, rules.MaxNewStations*100),
+				})
+			}
+		}
+	}
+
+	total := counts.NumGreen + counts.NumYellow + counts.NumRed
+	if rules.RejectSingleCategory && total > 1 && (counts.NumYellow == total || counts.NumRed == total) {
+		violations = append(violations, PlausibilityViolation{
+			Rule:   "single_category",
+			Detail: fmt.Sprintf("all %d stations are in the same incident category", total),
+		})
+	}
+
+	return violations
+}
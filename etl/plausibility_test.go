@@ -0,0 +1,92 @@
+package etl
+
+import (
+	"testing"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+func TestCheckPlausibility(t *testing.T) {
+	previous := make(map[int64]scrapper.HeatingStation, 20)
+	for geoId := int64(1); geoId <= 20; geoId++ {
+		previous[geoId] = scrapper.HeatingStation{GeoId: geoId, Active: true}
+	}
+
+	statusesOf := func(geoIds []int64, status string) []scrapper.HeatingStationStatus {
+		statuses := make([]scrapper.HeatingStationStatus, 0, len(geoIds))
+		for _, geoId := range geoIds {
+			statuses = append(statuses, scrapper.HeatingStationStatus{GeoId: geoId, Status: status})
+		}
+		return statuses
+	}
+	geoIdRange := func(from, to int64) []int64 {
+		geoIds := make([]int64, 0, to-from+1)
+		for geoId := from; geoId <= to; geoId++ {
+			geoIds = append(geoIds, geoId)
+		}
+		return geoIds
+	}
+
+	tests := []struct {
+		name      string
+		previous  map[int64]scrapper.HeatingStation
+		counts    scrapper.StationStatesCount
+		statuses  []scrapper.HeatingStationStatus
+		wantRules []string
+	}{
+		{
+			name:     "same stations",
+			previous: previous,
+			counts:   scrapper.StationStatesCount{NumGreen: 20},
+			statuses: statusesOf(geoIdRange(1, 20), "working"),
+		},
+		{
+			name:      "half empty map",
+			previous:  previous,
+			counts:    scrapper.StationStatesCount{NumGreen: 10},
+			statuses:  statusesOf(geoIdRange(1, 10), "working"),
+			wantRules: []string{"station_drop"},
+		},
+		{
+			name:      "empty map",
+			previous:  previous,
+			counts:    scrapper.StationStatesCount{},
+			statuses:  []scrapper.HeatingStationStatus{},
+			wantRules: []string{"station_drop"},
+		},
+		{
+			name:      "stations renumbered",
+			previous:  previous,
+			counts:    scrapper.StationStatesCount{NumGreen: 20},
+			statuses:  statusesOf(geoIdRange(11, 30), "working"),
+			wantRules: []string{"new_stations"},
+		},
+		{
+			name:      "every station broken",
+			previous:  previous,
+			counts:    scrapper.StationStatesCount{NumRed: 20},
+			statuses:  statusesOf(geoIdRange(1, 20), "broken"),
+			wantRules: []string{"single_category"},
+		},
+		{
+			name:     "empty store accepts a new map",
+			previous: map[int64]scrapper.HeatingStation{},
+			counts:   scrapper.StationStatesCount{NumGreen: 20},
+			statuses: statusesOf(geoIdRange(1, 20), "working"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckPlausibility(DefaultPlausibilityRules(), tt.previous, tt.counts, tt.statuses)
+			if len(got) != len(tt.wantRules) {
+				t.Fatalf("CheckPlausibility() = %+v, want rules %v", got, tt.wantRules)
+			}
+			for i, violation := range got {
+				if violation.Rule != tt.wantRules[i] {
+					t.Errorf("CheckPlausibility() rule %d = %q, want %q", i, violation.Rule, tt.wantRules[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package etl
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+// maxReportedStations caps the station lists of a report, totals are always exact
+const maxReportedStations = 50
+
+// StationChange is a station a run would create or update.
+type StationChange struct {
+	GeoId int64  `json:"geoId"`
+	Name  string `json:"name"`
+	// Fields lists the changed fields of an updated station
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Report describes what a run would write. It is returned by dry runs and
+// kept with quarantined snapshots, which are not written because they break
+// plausibility rules.
+type Report struct {
+	DryRun                 bool                        `json:"dryRun"`
+	FetchTime              time.Time                   `json:"fetchTime"`
+	Counts                 scrapper.StationStatesCount `json:"counts"`
+	NumStatuses            int                         `json:"numStatuses"`
+	NumStationsCreated     int                         `json:"numStationsCreated"`
+	NumStationsUpdated     int                         `json:"numStationsUpdated"`
+	StationsCreated        []StationChange             `json:"stationsCreated"`
+	StationsUpdated        []StationChange             `json:"stationsUpdated"`
+	NumStatusesWritten     int                         `json:"numStatusesWritten"`
+	NumUnchanged           int                         `json:"numUnchanged"`
+	NumDeactivated         int                         `json:"numDeactivated"`
+	NumReactivated         int                         `json:"numReactivated"`
+	NumIncidentsOpened     int                         `json:"numIncidentsOpened"`
+	NumIncidentsClosed     int                         `json:"numIncidentsClosed"`
+	NumIncidentsUpdated    int                         `json:"numIncidentsUpdated"`
+	ValidationIssues       []ValidationIssue           `json:"validationIssues"`
+	PlausibilityViolations []PlausibilityViolation     `json:"plausibilityViolations"`
+	WouldQuarantine        bool                        `json:"wouldQuarantine"`
+}
+
+func newReport(snap snapshot, forceAccept bool) Report {
+	report := Report{
+		DryRun:                 true,
+		FetchTime:              snap.result.FetchTime,
+		Counts:                 snap.result.Counts,
+		NumStatuses:            snap.result.NumStatuses,
+		StationsCreated:        make([]StationChange, 0),
+		StationsUpdated:        make([]StationChange, 0),
+		NumStatusesWritten:     snap.result.NumStatusesWritten,
+		NumUnchanged:           snap.result.NumUnchanged,
+		NumDeactivated:         snap.result.NumDeactivated,
+		NumReactivated:         snap.result.NumReactivated,
+		NumIncidentsOpened:     snap.result.NumIncidentsOpened,
+		NumIncidentsClosed:     snap.result.NumIncidentsClosed,
+		NumIncidentsUpdated:    snap.result.NumIncidentsUpdated,
+		ValidationIssues:       snap.issues,
+		PlausibilityViolations: snap.violations,
+		WouldQuarantine:        len(snap.violations) > 0 && !forceAccept,
+	}
+
+	for _, station := range snap.changes.Stations {
+		stored, exists := snap.previous[station.GeoId]
+		if !exists {
+			report.NumStationsCreated++
+			if len(report.StationsCreated) < maxReportedStations {
+				report.StationsCreated = append(report.StationsCreated, StationChange{GeoId: station.GeoId, Name: station.Name})
+			}
+			continue
+		}
+
+		fields := changedFields(stored, station)
+		// heartbeats only move the lifecycle times, they are not changes
+		if len(fields) == 0 {
+			continue
+		}
+		report.NumStationsUpdated++
+		if len(report.StationsUpdated) < maxReportedStations {
+			report.StationsUpdated = append(report.StationsUpdated, StationChange{GeoId: station.GeoId, Name: station.Name, Fields: fields})
+		}
+	}
+
+	return report
+}
+
+// changedFields lists the fields of a station that differ from its stored
+// state, leaving out the times that move on every write.
+func changedFields(stored, station scrapper.HeatingStation) []string {
+	fields := make([]string, 0)
+	if stored.Name != station.Name {
+		fields = append(fields, "name")
+	}
+	if stored.Latitude != station.Latitude || stored.Longitude != station.Longitude {
+		fields = append(fields, "coordinates")
+	}
+	if stored.LastStatus != station.LastStatus {
+		fields = append(fields, "status")
+	}
+	if stored.LastIncidentType != station.LastIncidentType {
+		fields = append(fields, "incidentType")
+	}
+	if stored.LastIncidentText != station.LastIncidentText {
+		fields = append(fields, "incidentText")
+	}
+	if stored.LastEstimatedFixDate != station.LastEstimatedFixDate {
+		fields = append(fields, "estimatedFixDate")
+	}
+	if stored.Active != station.Active {
+		fields = append(fields, "active")
+	}
+	return fields
+}
+
+// Log writes the report summary and its validation issues.
+func (r Report) Log() {
+	slog.Info("Dry run report",
+		"fetchTime", r.FetchTime.UTC().Format(time.RFC3339),
+		"numStatuses", r.NumStatuses,
+		"numGreen", r.Counts.NumGreen,
+		"numYellow", r.Counts.NumYellow,
+		"numRed", r.Counts.NumRed,
+		"numStationsCreated", r.NumStationsCreated,
+		"numStationsUpdated", r.NumStationsUpdated,
+		"numStatusesWritten", r.NumStatusesWritten,
+		"numDeactivated", r.NumDeactivated,
+		"numReactivated", r.NumReactivated,
+		"numIncidentsOpened", r.NumIncidentsOpened,
+		"numIncidentsClosed", r.NumIncidentsClosed,
+		"numIncidentsUpdated", r.NumIncidentsUpdated,
+		"numValidationIssues", len(r.ValidationIssues),
+		"numPlausibilityViolations", len(r.PlausibilityViolations),
+		"wouldQuarantine", r.WouldQuarantine,
+	)
+	if len(r.ValidationIssues) > 0 {
+		logValidationIssues(r.ValidationIssues)
+	}
+	if len(r.PlausibilityViolations) > 0 {
+		slog.Warn("Snapshot breaks plausibility rules", "violations", r.PlausibilityViolations)
+	}
+}
@@ -0,0 +1,110 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+)
+
+// Steps of a run recorded in its slot, an attempt resuming the slot skips the
+// ones an earlier attempt did.
+const (
+	stepCounts       = "counts"
+	stepStations     = "stations"
+	stepStatuses     = "statuses"
+	stepIncidents    = "incidents"
+	stepCurrentState = "current_state"
+)
+
+// ErrRunSlotHeld is returned by a run whose slot is held by another attempt
+// that has not completed it. Failing the invocation has it retried once the
+// lease of that attempt expired, in case it died.
+var ErrRunSlotHeld = errors.New("run slot held by another attempt")
+
+const (
+	// slotLease is how long a slot stays held by an attempt without deadline,
+	// such as a local run, that died without releasing it
+	slotLease = 15 * time.Minute
+	// slotLeaseMargin holds the slot past the deadline of the attempt, for its release
+	slotLeaseMargin = 30 * time.Second
+	// slotRetention keeps the slots long enough to catch the late deliveries and retries
+	slotRetention = 7 * 24 * time.Hour
+	slotTimeout   = 10 * time.Second
+)
+
+// SlotTime is the start of the schedule slot of an event time, the event
+// time rounded down to the schedule interval.
+func SlotTime(scheduledAt time.Time, interval time.Duration) time.Time {
+	return scheduledAt.UTC().Truncate(interval)
+}
+
+// leaseExpiry is when the slot of an attempt can be taken over if the attempt
+// died, shortly after its deadline: the retries of a timed out invocation can
+// then resume the slot.
+func leaseExpiry(ctx context.Context, now time.Time) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline.Add(slotLeaseMargin)
+	}
+	return now.Add(slotLease)
+}
+
+// claimSlot takes the slot of scheduledAt for the run. It returns nil when
+// the slot is completed, and ErrRunSlotHeld when another attempt holds it.
+func (p *Pipeline) claimSlot(ctx context.Context, runId string, scheduledAt, now time.Time) (*storage.RunSlot, error) {
+	slotTime := SlotTime(scheduledAt, p.SlotInterval)
+	slot, claimed, err := p.Slots.ClaimRunSlot(ctx, storage.RunSlot{
+		Slot:           slotTime.Unix(),
+		RunId:          runId,
+		LeaseExpiresAt: leaseExpiry(ctx, now).Unix(),
+		ExpiresAt:      slotTime.Add(slotRetention).Unix(),
+	}, now.Unix())
+	if err != nil {
+		return nil, classify(ErrorClassStorageWrite, fmt.Errorf("unable to claim run slot: %w", err))
+	}
+	if !claimed && slot.Completed {
+		slog.InfoContext(ctx, "Run slot already completed, duplicate run skipped", "runId", runId, "slot", slotTime, "slotRunId", slot.RunId)
+		return nil, nil
+	}
+	if !claimed {
+		slog.WarnContext(ctx, "Run slot held by another attempt, run postponed",
+			"runId", runId,
+			"slot", slotTime,
+			"slotRunId", slot.RunId,
+			"leaseExpiresAt", time.Unix(slot.LeaseExpiresAt, 0),
+		)
+		return nil, fmt.Errorf("%w: slot %s held by %s", ErrRunSlotHeld, slotTime, slot.RunId)
+	}
+	if slot.Attempts > 1 {
+		slog.InfoContext(ctx, "Resuming run slot", "runId", runId, "slot", slotTime, "attempt", slot.Attempts, "doneSteps", slot.Steps)
+	}
+	return &slot, nil
+}
+
+// recordStep records a step of the slot as soon as it is done. A failure is
+// only logged: the release records the steps again, and a retry redoes the
+// step under the same keys.
+func (p *Pipeline) recordStep(ctx context.Context, slot *storage.RunSlot, step string) {
+	slot.Steps = append(slot.Steps, step)
+	if err := p.Slots.AddRunSlotStep(ctx, *slot, step); err != nil {
+		slog.WarnContext(ctx, "Failed to record run slot step", "runId", slot.RunId, "slot", slot.Slot, "step", step, "error_msg", err.Error())
+	}
+}
+
+// releaseSlot records the steps of the attempt and releases its slot, which
+// is completed unless the run failed. A quarantined snapshot completes the
+// slot, as a retry would refuse it again.
+func (p *Pipeline) releaseSlot(ctx context.Context, slot *storage.RunSlot, runErr error) {
+	slot.Completed = runErr == nil || errors.Is(runErr, ErrImplausibleSnapshot)
+	slot.LeaseExpiresAt = 0
+
+	// the slot is released even when the run ran out of time
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), slotTimeout)
+	defer cancel()
+	if err := p.Slots.UpdateRunSlot(releaseCtx, *slot); err != nil {
+		slog.Error("Failed to release run slot", "runId", slot.RunId, "slot", slot.Slot, "error_msg", err.Error())
+	}
+}
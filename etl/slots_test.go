@@ -0,0 +1,110 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+)
+
+func TestSlotTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		scheduledAt time.Time
+		want        time.Time
+	}{
+		{
+			name:        "on time",
+			scheduledAt: time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC),
+			want:        time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "late delivery",
+			scheduledAt: time.Date(2025, 1, 10, 12, 59, 59, 0, time.UTC),
+			want:        time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "local time zone",
+			scheduledAt: time.Date(2025, 1, 10, 14, 45, 0, 0, time.FixedZone("EET", 2*3600)),
+			want:        time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SlotTime(tt.scheduledAt, 30*time.Minute); !got.Equal(tt.want) {
+				t.Errorf("SlotTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunScheduledSkipsTakenSlot(t *testing.T) {
+	ctx := context.Background()
+	scheduledAt := time.Date(2025, 1, 10, 12, 30, 5, 0, time.UTC)
+	slotStart := SlotTime(scheduledAt, 30*time.Minute).Unix()
+
+	tests := []struct {
+		name    string
+		slot    storage.RunSlot
+		wantErr error
+	}{
+		{name: "completed slot", slot: storage.RunSlot{Slot: slotStart, RunId: "earlier", Completed: true}},
+		{
+			name:    "slot held by a running attempt is retried later",
+			slot:    storage.RunSlot{Slot: slotStart, RunId: "earlier", LeaseExpiresAt: time.Now().Add(time.Minute).Unix()},
+			wantErr: ErrRunSlotHeld,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			if _, _, err := store.ClaimRunSlot(ctx, tt.slot, 0); err != nil {
+				t.Fatalf("ClaimRunSlot() error = %v", err)
+			}
+			if err := store.UpdateRunSlot(ctx, tt.slot); err != nil {
+				t.Fatalf("UpdateRunSlot() error = %v", err)
+			}
+
+			// a pipeline without scrapper nor repositories fails if it runs at all
+			p := &Pipeline{Slots: store, SlotInterval: 30 * time.Minute}
+			result, err := p.RunScheduled(ctx, scheduledAt)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) || !result.Duplicate {
+				t.Errorf("RunScheduled() = %+v, %v, want a duplicate run with error %v", result, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC)
+	if got := leaseExpiry(context.Background(), now); !got.Equal(now.Add(slotLease)) {
+		t.Errorf("leaseExpiry() without deadline = %v, want %v", got, now.Add(slotLease))
+	}
+
+	deadline := now.Add(5 * time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if got := leaseExpiry(ctx, now); !got.Equal(deadline.Add(slotLeaseMargin)) {
+		t.Errorf("leaseExpiry() = %v, want the deadline with its margin %v", got, deadline.Add(slotLeaseMargin))
+	}
+}
+
+func TestRecordStepSurvivesKilledAttempt(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	p := &Pipeline{Slots: store, SlotInterval: 30 * time.Minute}
+
+	slot, claimed, err := store.ClaimRunSlot(ctx, storage.RunSlot{Slot: 1800, RunId: "killed", LeaseExpiresAt: 2000}, 1800)
+	if err != nil || !claimed {
+		t.Fatalf("ClaimRunSlot() = %v, %v", claimed, err)
+	}
+	// the attempt is killed after its first step, without releasing the slot
+	p.recordStep(ctx, &slot, stepCounts)
+
+	retry, claimed, err := store.ClaimRunSlot(ctx, storage.RunSlot{Slot: 1800, RunId: "retry", LeaseExpiresAt: 3000}, 2100)
+	if err != nil || !claimed || !retry.HasStep(stepCounts) {
+		t.Errorf("retry claim = %+v, %v, %v, want the slot with the counts step", retry, claimed, err)
+	}
+}
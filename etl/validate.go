@@ -0,0 +1,80 @@
+package etl
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+// rough bounding box of Bucharest, stations outside of it have broken coordinates
+const (
+	minLatitude  = 44.3
+	maxLatitude  = 44.6
+	minLongitude = 25.9
+	maxLongitude = 26.3
+)
+
+// ValidationIssue is a problem found in a converted snapshot. GeoId and Name
+// are empty for the issues about the whole snapshot.
+type ValidationIssue struct {
+	GeoId int64  `json:"geoId,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Issue string `json:"issue"`
+}
+
+// Validate checks the converted statuses and counts of a snapshot. Issues are
+// reported, they do not prevent the snapshot from being written.
+func Validate(counts scrapper.StationStatesCount, statuses []scrapper.HeatingStationStatus) []ValidationIssue {
+	issues := make([]ValidationIssue, 0)
+	seen := make(map[int64]bool, len(statuses))
+
+	for _, status := range statuses {
+		report := func(format string, args ...any) {
+			issues = append(issues, ValidationIssue{
+				GeoId: status.GeoId,
+				Name:  status.Name,
+				Issue: fmt.Sprintf(format, args...),
+			})
+		}
+
+		if seen[status.GeoId] {
+			report("duplicated station")
+		}
+		seen[status.GeoId] = true
+
+		switch status.Status {
+		case "working":
+		case "issue", "broken":
+			if status.IncidentType == "" || status.IncidentType == "-" {
+				report("%s station without incident type", status.Status)
+			}
+		default:
+			report("unknown status %q", status.Status)
+		}
+		if status.Name == "" {
+			report("empty name")
+		}
+		if status.Latitude < minLatitude || status.Latitude > maxLatitude ||
+			status.Longitude < minLongitude || status.Longitude > maxLongitude {
+			report("coordinates %.6f,%.6f outside of Bucharest", status.Latitude, status.Longitude)
+		}
+	}
+
+	if total := counts.NumGreen + counts.NumYellow + counts.NumRed; total != len(statuses) {
+		issues = append(issues, ValidationIssue{
+			Issue: fmt.Sprintf("counts total %d does not match the %d statuses", total, len(statuses)),
+		})
+	}
+
+	return issues
+}
+
+func logValidationIssues(issues []ValidationIssue) {
+	const maxLoggedIssues = 20
+
+	slog.Warn("Snapshot validation found issues",
+		"numIssues", len(issues),
+		"issues", issues[:min(len(issues), maxLoggedIssues)],
+	)
+}
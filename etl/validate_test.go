@@ -0,0 +1,103 @@
+package etl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+func TestValidate(t *testing.T) {
+	valid := scrapper.HeatingStationStatus{
+		GeoId:        1,
+		Name:         "PT 1",
+		Status:       "broken",
+		IncidentType: "Oprire ACC",
+		Latitude:     44.43,
+		Longitude:    26.10,
+	}
+
+	tests := []struct {
+		name       string
+		counts     scrapper.StationStatesCount
+		statuses   []scrapper.HeatingStationStatus
+		wantIssues int
+	}{
+		{
+			name:     "valid snapshot",
+			counts:   scrapper.StationStatesCount{NumRed: 1},
+			statuses: []scrapper.HeatingStationStatus{valid},
+		},
+		{
+			name:       "unknown status and empty name",
+			counts:     scrapper.StationStatesCount{NumRed: 1},
+			statuses:   []scrapper.HeatingStationStatus{{GeoId: 1, Status: "unknown", Latitude: 44.43, Longitude: 26.10}},
+			wantIssues: 2,
+		},
+		{
+			name:       "coordinates outside of Bucharest",
+			counts:     scrapper.StationStatesCount{NumRed: 1},
+			statuses:   []scrapper.HeatingStationStatus{{GeoId: 1, Name: "PT 1", Status: "working"}},
+			wantIssues: 1,
+		},
+		{
+			name:   "incident without type",
+			counts: scrapper.StationStatesCount{NumYellow: 1},
+			statuses: []scrapper.HeatingStationStatus{
+				{GeoId: 1, Name: "PT 1", Status: "issue", IncidentType: "-", Latitude: 44.43, Longitude: 26.10},
+			},
+			wantIssues: 1,
+		},
+		{
+			name:       "duplicated station and counts mismatch",
+			counts:     scrapper.StationStatesCount{NumRed: 1},
+			statuses:   []scrapper.HeatingStationStatus{valid, valid},
+			wantIssues: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Validate(tt.counts, tt.statuses)
+			if len(got) != tt.wantIssues {
+				t.Errorf("Validate() returned %d issues, want %d: %+v", len(got), tt.wantIssues, got)
+			}
+		})
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	stored := scrapper.HeatingStation{
+		GeoId:          1,
+		Name:           "PT 1",
+		LastStatus:     "working",
+		LastStatusTime: 100,
+		LastSeen:       100,
+		Active:         true,
+	}
+
+	heartbeat := stored
+	heartbeat.LastStatusTime = 200
+	heartbeat.LastSeen = 200
+
+	incident := heartbeat
+	incident.LastStatus = "broken"
+	incident.LastIncidentType = "Oprire ACC"
+
+	tests := []struct {
+		name    string
+		station scrapper.HeatingStation
+		want    []string
+	}{
+		{name: "heartbeat", station: heartbeat, want: []string{}},
+		{name: "new incident", station: incident, want: []string{"status", "incidentType"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changedFields(stored, tt.station); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package geometry
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"slices"
+)
+
+// BucharestBoundary is a coarse outline of the Bucharest municipality used to
+// clip the outer voronoi cells, which would otherwise be unbounded.
+var BucharestBoundary = Polygon{
+	{Longitude: 26.0440, Latitude: 44.5405},
+	{Longitude: 26.0980, Latitude: 44.5370},
+	{Longitude: 26.1350, Latitude: 44.5150},
+	{Longitude: 26.1700, Latitude: 44.4950},
+	{Longitude: 26.2050, Latitude: 44.4700},
+	{Longitude: 26.2250, Latitude: 44.4400},
+	{Longitude: 26.2050, Latitude: 44.4050},
+	{Longitude: 26.1800, Latitude: 44.3750},
+	{Longitude: 26.1400, Latitude: 44.3450},
+	{Longitude: 26.0980, Latitude: 44.3340},
+	{Longitude: 26.0500, Latitude: 44.3450},
+	{Longitude: 26.0050, Latitude: 44.3800},
+	{Longitude: 25.9700, Latitude: 44.4150},
+	{Longitude: 25.9670, Latitude: 44.4500},
+	{Longitude: 25.9900, Latitude: 44.4850},
+	{Longitude: 26.0200, Latitude: 44.5200},
+}
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string          `json:"type"`
+	Geometry   PolygonGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type PolygonGeometry struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// NewPolygonGeometry converts the polygon to a GeoJSON polygon with a closed
+// ring in counterclockwise order, as required by RFC 7946.
+func NewPolygonGeometry(poly Polygon) PolygonGeometry {
+	ring := make([][2]float64, 0, len(poly)+1)
+	for _, p := range poly {
+		ring = append(ring, [2]float64{p.Longitude, p.Latitude})
+	}
+	if signedArea(poly) < 0 {
+		slices.Reverse(ring)
+	}
+	if len(ring) > 0 {
+		ring = append(ring, ring[0])
+	}
+	return PolygonGeometry{
+		Type:        "Polygon",
+		Coordinates: [][][2]float64{ring},
+	}
+}
+
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+}
+
+func signedArea(poly Polygon) float64 {
+	var area float64
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		area += a.Longitude*b.Latitude - b.Longitude*a.Latitude
+	}
+	return area / 2
+}
+
+// SitesFingerprint identifies a set of sites, it changes only when a site
+// appears, disappears or moves, so it can be used to cache a tessellation.
+func SitesFingerprint(sites []Site) string {
+	sorted := slices.Clone(sites)
+	slices.SortFunc(sorted, func(a, b Site) int {
+		switch {
+		case a.GeoId < b.GeoId:
+			return -1
+		case a.GeoId > b.GeoId:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	h := sha256.New()
+	buf := make([]byte, 8)
+	for _, s := range sorted {
+		binary.BigEndian.PutUint64(buf, uint64(s.GeoId))
+		h.Write(buf)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,231 @@
+package geometry
+
+import (
+	"math"
+	"slices"
+)
+
+// Point is a WGS84 coordinate.
+type Point struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// Polygon is a ring of points, the last point is not repeated.
+type Polygon []Point
+
+// Site is a point that owns a voronoi cell, identified by the station GeoId.
+type Site struct {
+	GeoId int64
+	Point Point
+}
+
+// Cell is the area closer to its site than to any other site, clipped to the boundary.
+type Cell struct {
+	GeoId   int64
+	Polygon Polygon
+}
+
+// planar coordinates, in degrees of latitude, of a local equirectangular projection
+type vec struct {
+	x, y float64
+}
+
+type projection struct {
+	originLon float64
+	originLat float64
+	lonScale  float64
+}
+
+func newProjection(boundary Polygon) projection {
+	var sumLon, sumLat float64
+	for _, p := range boundary {
+		sumLon += p.Longitude
+		sumLat += p.Latitude
+	}
+	n := float64(len(boundary))
+	originLat := sumLat / n
+	return projection{
+		originLon: sumLon / n,
+		originLat: originLat,
+		lonScale:  math.Cos(originLat * math.Pi / 180.0),
+	}
+}
+
+func (p projection) forward(pt Point) vec {
+	return vec{
+		x: (pt.Longitude - p.originLon) * p.lonScale,
+		y: pt.Latitude - p.originLat,
+	}
+}
+
+func (p projection) inverse(v vec) Point {
+	return Point{
+		Longitude: math.Round((v.x/p.lonScale+p.originLon)*1e6) / 1e6,
+		Latitude:  math.Round((v.y+p.originLat)*1e6) / 1e6,
+	}
+}
+
+// VoronoiCells computes the voronoi tessellation of the sites clipped to the boundary.
+// Distances are measured on a local equirectangular projection, which is accurate
+// enough at the scale of a city. Sites whose cell does not intersect the boundary
+// are omitted from the result.
+func VoronoiCells(sites []Site, boundary Polygon) []Cell {
+	if len(boundary) < 3 {
+		return nil
+	}
+
+	proj := newProjection(boundary)
+
+	projectedBoundary := make([]vec, len(boundary))
+	for i, p := range boundary {
+		projectedBoundary[i] = proj.forward(p)
+	}
+
+	projectedSites := make([]vec, len(sites))
+	for i, s := range sites {
+		projectedSites[i] = proj.forward(s.Point)
+	}
+
+	cells := make([]Cell, 0, len(sites))
+	neighbours := make([]int, 0, len(sites))
+
+	for i, site := range projectedSites {
+
+		// visiting the other sites from the closest one lets us stop as soon as
+		// a bisector is further away than the farthest vertex of the cell
+		neighbours = neighbours[:0]
+		for j := range projectedSites {
+			if j != i {
+				neighbours = append(neighbours, j)
+			}
+		}
+		slices.SortFunc(neighbours, func(a, b int) int {
+			da := squaredDistance(site, projectedSites[a])
+			db := squaredDistance(site, projectedSites[b])
+			switch {
+			case da < db:
+				return -1
+			case da > db:
+				return 1
+			default:
+				return 0
+			}
+		})
+
+		cell := slices.Clone(projectedBoundary)
+		for _, j := range neighbours {
+			other := projectedSites[j]
+			if squaredDistance(site, other) == 0 {
+				continue
+			}
+			if squaredDistance(site, other)/4 > maxSquaredDistance(site, cell) {
+				break
+			}
+			cell = clipToBisector(cell, site, other)
+			if len(cell) == 0 {
+				break
+			}
+		}
+
+		if len(cell) < 3 {
+			continue
+		}
+
+		polygon := make(Polygon, len(cell))
+		for k, v := range cell {
+			polygon[k] = proj.inverse(v)
+		}
+		cells = append(cells, Cell{
+			GeoId:   sites[i].GeoId,
+			Polygon: polygon,
+		})
+	}
+
+	return cells
+}
+
+// clipToBisector keeps the part of the polygon that is closer to site than to other
+// (Sutherland-Hodgman against a single half-plane).
+func clipToBisector(polygon []vec, site, other vec) []vec {
+	normal := vec{x: other.x - site.x, y: other.y - site.y}
+	mid := vec{x: (site.x + other.x) / 2, y: (site.y + other.y) / 2}
+
+	side := func(p vec) float64 {
+		return (p.x-mid.x)*normal.x + (p.y-mid.y)*normal.y
+	}
+
+	clipped := make([]vec, 0, len(polygon)+1)
+	for k := range polygon {
+		current := polygon[k]
+		next := polygon[(k+1)%len(polygon)]
+		sc, sn := side(current), side(next)
+
+		if sc <= 0 {
+			clipped = append(clipped, current)
+		}
+		if (sc < 0 && sn > 0) || (sc > 0 && sn < 0) {
+			t := sc / (sc - sn)
+			clipped = append(clipped, vec{
+				x: current.x + t*(next.x-current.x),
+				y: current.y + t*(next.y-current.y),
+			})
+		}
+	}
+
+	return clipped
+}
+
+func squaredDistance(a, b vec) float64 {
+	dx, dy := a.x-b.x, a.y-b.y
+	return dx*dx + dy*dy
+}
+
+func maxSquaredDistance(from vec, polygon []vec) float64 {
+	var maxDist float64
+	for _, p := range polygon {
+		maxDist = max(maxDist, squaredDistance(from, p))
+	}
+	return maxDist
+}
+
+// Contains tells if the point is inside the polygon (ray casting).
+func (poly Polygon) Contains(p Point) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) {
+			crossLon := (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude) + a.Longitude
+			if p.Longitude < crossLon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// Distance returns the great-circle distance in meters between two points.
+func Distance(a, b Point) float64 {
+	const earthRadiusMeters = 6371000.0
+
+	lat1 := a.Latitude * math.Pi / 180.0
+	lat2 := b.Latitude * math.Pi / 180.0
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180.0
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// NearestSite returns the site closest to the point, which is also the site
+// whose voronoi cell contains it, and its distance in meters.
+func NearestSite(sites []Site, p Point) (Site, float64, bool) {
+	var nearest Site
+	nearestDist := math.Inf(1)
+	for _, s := range sites {
+		if d := Distance(s.Point, p); d < nearestDist {
+			nearest, nearestDist = s, d
+		}
+	}
+	return nearest, nearestDist, len(sites) > 0
+}
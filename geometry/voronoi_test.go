@@ -0,0 +1,165 @@
+package geometry
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVoronoiCells(t *testing.T) {
+	square := Polygon{
+		{Longitude: 26.0, Latitude: 44.0},
+		{Longitude: 26.1, Latitude: 44.0},
+		{Longitude: 26.1, Latitude: 44.1},
+		{Longitude: 26.0, Latitude: 44.1},
+	}
+
+	tests := []struct {
+		name      string
+		sites     []Site
+		wantCells int
+	}{
+		{
+			name:      "no sites",
+			sites:     nil,
+			wantCells: 0,
+		},
+		{
+			name: "single site owns the whole boundary",
+			sites: []Site{
+				{GeoId: 1, Point: Point{Longitude: 26.05, Latitude: 44.05}},
+			},
+			wantCells: 1,
+		},
+		{
+			name: "two sites split the boundary",
+			sites: []Site{
+				{GeoId: 1, Point: Point{Longitude: 26.02, Latitude: 44.05}},
+				{GeoId: 2, Point: Point{Longitude: 26.08, Latitude: 44.05}},
+			},
+			wantCells: 2,
+		},
+		{
+			name: "site outside of the boundary is dropped",
+			sites: []Site{
+				{GeoId: 1, Point: Point{Longitude: 26.05, Latitude: 44.05}},
+				{GeoId: 2, Point: Point{Longitude: 27.5, Latitude: 44.05}},
+			},
+			wantCells: 1,
+		},
+		{
+			name: "grid of sites",
+			sites: []Site{
+				{GeoId: 1, Point: Point{Longitude: 26.025, Latitude: 44.025}},
+				{GeoId: 2, Point: Point{Longitude: 26.075, Latitude: 44.025}},
+				{GeoId: 3, Point: Point{Longitude: 26.025, Latitude: 44.075}},
+				{GeoId: 4, Point: Point{Longitude: 26.075, Latitude: 44.075}},
+			},
+			wantCells: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cells := VoronoiCells(tt.sites, square)
+			if len(cells) != tt.wantCells {
+				t.Fatalf("VoronoiCells() = %d cells, want %d", len(cells), tt.wantCells)
+			}
+
+			// cells must tile the boundary
+			var total float64
+			for _, c := range cells {
+				total += math.Abs(signedArea(c.Polygon))
+			}
+			if tt.wantCells > 0 && len(cells) == len(tt.sites) {
+				if diff := math.Abs(total - math.Abs(signedArea(square))); diff > 1e-6 {
+					t.Errorf("cells area = %f, want %f", total, math.Abs(signedArea(square)))
+				}
+			}
+
+			// each site must be inside its own cell
+			for _, c := range cells {
+				for _, s := range tt.sites {
+					if s.GeoId == c.GeoId && !c.Polygon.Contains(s.Point) {
+						t.Errorf("site %d is not inside its cell %v", s.GeoId, c.Polygon)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVoronoiCellsNearestSite(t *testing.T) {
+	sites := []Site{
+		{GeoId: 1, Point: Point{Longitude: 26.05, Latitude: 44.40}},
+		{GeoId: 2, Point: Point{Longitude: 26.15, Latitude: 44.42}},
+		{GeoId: 3, Point: Point{Longitude: 26.10, Latitude: 44.48}},
+	}
+	cells := VoronoiCells(sites, BucharestBoundary)
+	if len(cells) != len(sites) {
+		t.Fatalf("VoronoiCells() = %d cells, want %d", len(cells), len(sites))
+	}
+
+	probe := Point{Longitude: 26.14, Latitude: 44.43}
+	for _, c := range cells {
+		if c.Polygon.Contains(probe) && c.GeoId != 2 {
+			t.Errorf("probe point is in cell %d, want 2", c.GeoId)
+		}
+	}
+}
+
+func TestNewPolygonGeometry(t *testing.T) {
+	clockwise := Polygon{
+		{Longitude: 0, Latitude: 0},
+		{Longitude: 0, Latitude: 1},
+		{Longitude: 1, Latitude: 1},
+		{Longitude: 1, Latitude: 0},
+	}
+
+	geom := NewPolygonGeometry(clockwise)
+	if geom.Type != "Polygon" {
+		t.Errorf("Type = %s, want Polygon", geom.Type)
+	}
+	ring := geom.Coordinates[0]
+	if len(ring) != 5 {
+		t.Fatalf("ring has %d points, want 5", len(ring))
+	}
+	if ring[0] != ring[4] {
+		t.Errorf("ring is not closed: %v", ring)
+	}
+	if ring[0] != [2]float64{1, 0} || ring[1] != [2]float64{1, 1} {
+		t.Errorf("ring is not counterclockwise: %v", ring)
+	}
+}
+
+func TestSitesFingerprint(t *testing.T) {
+	a := []Site{{GeoId: 1}, {GeoId: 2}}
+	b := []Site{{GeoId: 2}, {GeoId: 1}}
+	c := []Site{{GeoId: 1}, {GeoId: 3}}
+
+	if SitesFingerprint(a) != SitesFingerprint(b) {
+		t.Errorf("fingerprint depends on sites order")
+	}
+	if SitesFingerprint(a) == SitesFingerprint(c) {
+		t.Errorf("fingerprint did not change when a site changed")
+	}
+}
+
+func TestNearestSite(t *testing.T) {
+	sites := []Site{
+		{GeoId: 1, Point: Point{Longitude: 26.05, Latitude: 44.40}},
+		{GeoId: 2, Point: Point{Longitude: 26.15, Latitude: 44.42}},
+	}
+
+	if _, _, found := NearestSite(nil, Point{}); found {
+		t.Errorf("NearestSite() found a site in an empty list")
+	}
+
+	site, dist, found := NearestSite(sites, Point{Longitude: 26.15, Latitude: 44.43})
+	if !found || site.GeoId != 2 {
+		t.Fatalf("NearestSite() = %d, want 2", site.GeoId)
+	}
+	// one hundredth of a degree of latitude is about 1112 meters
+	if dist < 1100 || dist > 1125 {
+		t.Errorf("NearestSite() distance = %f, want about 1112", dist)
+	}
+}
@@ -24,30 +24,11 @@ fi
 # Login to ECR
 aws ecr get-login-password --region $(aws configure get region) | podman login --username AWS --password-stdin $REPO_URI
 
-# Build and push ETL Lambda
-podman build -f ../etl_lambda.Dockerfile -t $REPO_URI:etl-$VERSION_TAG ..
-podman push $REPO_URI:etl-$VERSION_TAG
-podman build -f ../etl_lambda.Dockerfile -t $REPO_URI:etl-latest ..
-podman push $REPO_URI:etl-latest
-
-# Build and push API Lambdas
-podman build -f ../get_counts_lambda.Dockerfile -t $REPO_URI:api-getcounts-$VERSION_TAG ..
-podman push $REPO_URI:api-getcounts-$VERSION_TAG
-podman build -f ../get_counts_lambda.Dockerfile -t $REPO_URI:api-getcounts-latest ..
-podman push $REPO_URI:api-getcounts-latest
-
-podman build -f ../get_stations_lambda.Dockerfile -t $REPO_URI:api-getstations-$VERSION_TAG ..
-podman push $REPO_URI:api-getstations-$VERSION_TAG
-podman build -f ../get_stations_lambda.Dockerfile -t $REPO_URI:api-getstations-latest ..
-podman push $REPO_URI:api-getstations-latest
-
-podman build -f ../get_station_details_lambda.Dockerfile -t $REPO_URI:api-getstationdetails-$VERSION_TAG ..
-podman push $REPO_URI:api-getstationdetails-$VERSION_TAG
-podman build -f ../get_station_details_lambda.Dockerfile -t $REPO_URI:api-getstationdetails-latest ..
-podman push $REPO_URI:api-getstationdetails-latest
+# Build and push the image of every lambda, the HANDLER variable of each function picks its handler
+podman build -f ../Dockerfile -t $REPO_URI:lambda-$VERSION_TAG ..
+podman push $REPO_URI:lambda-$VERSION_TAG
+podman tag $REPO_URI:lambda-$VERSION_TAG $REPO_URI:lambda-latest
+podman push $REPO_URI:lambda-latest
 
 echo "Images pushed to $REPO_URI:"
-echo "ETL: etl-$VERSION_TAG and etl-latest"
-echo "API GetCounts: api-getcounts-$VERSION_TAG and api-getcounts-latest"
-echo "API GetStations: api-getstations-$VERSION_TAG and api-getstations-latest"
-echo "API GetStationDetails: api-getstationdetails-$VERSION_TAG and api-getstationdetails-latest"
\ No newline at end of file
+echo "Lambdas: lambda-$VERSION_TAG and lambda-latest"
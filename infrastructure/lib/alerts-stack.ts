@@ -4,6 +4,7 @@ import * as cloudwatchActions from "aws-cdk-lib/aws-cloudwatch-actions";
 import * as sns from "aws-cdk-lib/aws-sns";
 import * as snsSubscriptions from "aws-cdk-lib/aws-sns-subscriptions";
 import * as lambda from "aws-cdk-lib/aws-lambda";
+import * as logs from "aws-cdk-lib/aws-logs";
 import { Construct } from "constructs";
 
 interface AlertsStackProps extends cdk.StackProps {
@@ -56,6 +57,41 @@ export class AlertsStack extends cdk.Stack {
       new cloudwatchActions.SnsAction(topic)
     );
 
+    // the ETL logs this message when a snapshot breaks a plausibility rule
+    const etlLogGroup = logs.LogGroup.fromLogGroupName(
+      this,
+      "ETLLogGroup",
+      `${props.envPrefix}-TermoficareETL`
+    );
+    const quarantineMetricFilter = new logs.MetricFilter(
+      this,
+      "QuarantinedSnapshotsMetricFilter",
+      {
+        logGroup: etlLogGroup,
+        metricNamespace: "Termoficare",
+        metricName: `${props.envPrefix}-QuarantinedSnapshots`,
+        filterPattern: logs.FilterPattern.literal('"Snapshot quarantined"'),
+        metricValue: "1",
+        defaultValue: 0,
+      }
+    );
+    const quarantineAlarm = new cloudwatch.Alarm(
+      this,
+      "QuarantinedSnapshotAlarm",
+      {
+        alarmName: `${props.envPrefix}-etl-quarantined-snapshot`,
+        metric: quarantineMetricFilter.metric({
+          statistic: "Sum",
+          period: cdk.Duration.minutes(30),
+        }),
+        threshold: 1,
+        evaluationPeriods: 1,
+        datapointsToAlarm: 1,
+        treatMissingData: cloudwatch.TreatMissingData.NOT_BREACHING,
+      }
+    );
+    quarantineAlarm.addAlarmAction(new cloudwatchActions.SnsAction(topic));
+
     // Stream processor alerts
     const streamErrorAlarm = new cloudwatch.Alarm(
       this,
@@ -107,5 +143,138 @@ export class AlertsStack extends cdk.Stack {
     aggregateMissingExecutionAlarm.addAlarmAction(
       new cloudwatchActions.SnsAction(topic)
     );
+
+    // the aggregator logs this message when status rows about to expire are
+    // missing from the archive, their expiry is extended until they are archived
+    const aggregatorLogGroup = logs.LogGroup.fromLogGroupName(
+      this,
+      "AggregatorLogGroup",
+      `${props.envPrefix}-TermoficareAggregator`
+    );
+    const unarchivedMetricFilter = new logs.MetricFilter(
+      this,
+      "UnarchivedStatusesMetricFilter",
+      {
+        logGroup: aggregatorLogGroup,
+        metricNamespace: "Termoficare",
+        metricName: `${props.envPrefix}-UnarchivedExpiringStatuses`,
+        filterPattern: logs.FilterPattern.literal(
+          '"Status rows missing from the archive"'
+        ),
+        metricValue: "1",
+        defaultValue: 0,
+      }
+    );
+    const unarchivedAlarm = new cloudwatch.Alarm(
+      this,
+      "UnarchivedStatusesAlarm",
+      {
+        alarmName: `${props.envPrefix}-aggregate-unarchived-statuses`,
+        metric: unarchivedMetricFilter.metric({
+          statistic: "Sum",
+          period: cdk.Duration.days(1),
+        }),
+        threshold: 1,
+        evaluationPeriods: 1,
+        datapointsToAlarm: 1,
+        treatMissingData: cloudwatch.TreatMissingData.NOT_BREACHING,
+      }
+    );
+    unarchivedAlarm.addAlarmAction(new cloudwatchActions.SnsAction(topic));
+
+    // metrics the lambdas write in embedded metric format
+    const metricsNamespace = `Termoficare/${props.envPrefix}`;
+
+    const missingBackupDaysAlarm = new cloudwatch.Alarm(
+      this,
+      "MissingBackupDaysAlarm",
+      {
+        alarmName: `${props.envPrefix}-aggregate-missing-backup-days`,
+        metric: new cloudwatch.Metric({
+          namespace: metricsNamespace,
+          metricName: "MissingBackupDays",
+          dimensionsMap: { Service: "aggregator" },
+          statistic: "Maximum",
+          period: cdk.Duration.days(1),
+        }),
+        threshold: 1,
+        evaluationPeriods: 1,
+        comparisonOperator:
+          cloudwatch.ComparisonOperator.GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+        treatMissingData: cloudwatch.TreatMissingData.NOT_BREACHING,
+      }
+    );
+    missingBackupDaysAlarm.addAlarmAction(
+      new cloudwatchActions.SnsAction(topic)
+    );
+
+    // the archive check runs before the statistics, a failure of either one
+    // fails the invocation but only this alarm tells the check did not run
+    const archiveCheckFailuresAlarm = new cloudwatch.Alarm(
+      this,
+      "ArchiveCheckFailuresAlarm",
+      {
+        alarmName: `${props.envPrefix}-aggregate-archive-check-failures`,
+        metric: new cloudwatch.Metric({
+          namespace: metricsNamespace,
+          metricName: "ArchiveCheckFailures",
+          dimensionsMap: { Service: "aggregator" },
+          statistic: "Sum",
+          period: cdk.Duration.days(1),
+        }),
+        threshold: 1,
+        evaluationPeriods: 1,
+        comparisonOperator:
+          cloudwatch.ComparisonOperator.GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+        treatMissingData: cloudwatch.TreatMissingData.NOT_BREACHING,
+      }
+    );
+    archiveCheckFailuresAlarm.addAlarmAction(
+      new cloudwatchActions.SnsAction(topic)
+    );
+
+    const etlWriteFailuresAlarm = new cloudwatch.Alarm(
+      this,
+      "EtlWriteFailuresAlarm",
+      {
+        alarmName: `${props.envPrefix}-etl-write-failures`,
+        metric: new cloudwatch.Metric({
+          namespace: metricsNamespace,
+          metricName: "WriteFailures",
+          dimensionsMap: { Service: "etl" },
+          statistic: "Sum",
+          period: cdk.Duration.hours(1),
+        }),
+        threshold: 1,
+        evaluationPeriods: 1,
+        comparisonOperator:
+          cloudwatch.ComparisonOperator.GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+        treatMissingData: cloudwatch.TreatMissingData.NOT_BREACHING,
+      }
+    );
+    etlWriteFailuresAlarm.addAlarmAction(new cloudwatchActions.SnsAction(topic));
+
+    // events refused by the sink stay in the outbox, events pending after
+    // every run of two hours mean the consumers stopped hearing from the ETL
+    const pendingEventsAlarm = new cloudwatch.Alarm(
+      this,
+      "EtlPendingEventsAlarm",
+      {
+        alarmName: `${props.envPrefix}-etl-pending-events`,
+        metric: new cloudwatch.Metric({
+          namespace: metricsNamespace,
+          metricName: "EventsPending",
+          dimensionsMap: { Service: "etl" },
+          statistic: "Minimum",
+          period: cdk.Duration.hours(2),
+        }),
+        threshold: 1,
+        evaluationPeriods: 1,
+        comparisonOperator:
+          cloudwatch.ComparisonOperator.GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+        treatMissingData: cloudwatch.TreatMissingData.NOT_BREACHING,
+      }
+    );
+    pendingEventsAlarm.addAlarmAction(new cloudwatchActions.SnsAction(topic));
   }
 }
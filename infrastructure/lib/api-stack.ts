@@ -4,6 +4,7 @@ import * as apigateway from "aws-cdk-lib/aws-apigateway";
 import * as dynamodb from "aws-cdk-lib/aws-dynamodb";
 import * as ecr from "aws-cdk-lib/aws-ecr";
 import * as logs from "aws-cdk-lib/aws-logs";
+import * as s3 from "aws-cdk-lib/aws-s3";
 import { Construct } from "constructs";
 
 interface ApiStackProps extends cdk.StackProps {
@@ -14,6 +15,11 @@ interface ApiStackProps extends cdk.StackProps {
   stationsTable: dynamodb.Table;
   statusHistoryTable: dynamodb.Table;
   stationsIncidentsStatsTable: dynamodb.Table;
+  etlRunsTable: dynamodb.Table;
+  incidentsTable: dynamodb.Table;
+  fixDateReliabilityTable: dynamodb.Table;
+  dayRollupsTable: dynamodb.Table;
+  backupBucket: s3.Bucket;
 }
 
 export class ApiStack extends cdk.Stack {
@@ -22,6 +28,10 @@ export class ApiStack extends cdk.Stack {
   public readonly getStationsLambda: lambda.Function;
   public readonly getStationDetailsLambda: lambda.Function;
   public readonly getStationsStatsLambda: lambda.Function;
+  public readonly getServiceAreasLambda: lambda.Function;
+  public readonly getAddressStationLambda: lambda.Function;
+  public readonly getAvailabilityLambda: lambda.Function;
+  public readonly getIncidentsLambda: lambda.Function;
 
   constructor(scope: Construct, id: string, props: ApiStackProps) {
     super(scope, id, props);
@@ -32,9 +42,12 @@ export class ApiStack extends cdk.Stack {
       `${props.envPrefix}-TermoficareWebsiteBackend`
     );
 
+    // the lambdas write their metrics to their logs, in embedded metric format
+    const metricsNamespace = `Termoficare/${props.envPrefix}`;
+
     this.getCountsLambda = new lambda.Function(this, "GetCountsLambda", {
       code: lambda.Code.fromEcrImage(props.ecrRepository, {
-        tagOrDigest: `api-getcounts-${props.version}`,
+        tagOrDigest: `lambda-${props.version}`,
       }),
       handler: lambda.Handler.FROM_IMAGE,
       runtime: lambda.Runtime.FROM_IMAGE,
@@ -42,8 +55,10 @@ export class ApiStack extends cdk.Stack {
       memorySize: 128,
       logGroup,
       environment: {
+        HANDLER: "api-counts",
         DYNAMODB_TABLE_DAY_COUNTS: props.dayCountsTable.tableName,
         ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+        METRICS_NAMESPACE: metricsNamespace,
       },
     });
 
@@ -51,7 +66,7 @@ export class ApiStack extends cdk.Stack {
 
     this.getStationsLambda = new lambda.Function(this, "GetStationsLambda", {
       code: lambda.Code.fromEcrImage(props.ecrRepository, {
-        tagOrDigest: `api-getstations-${props.version}`,
+        tagOrDigest: `lambda-${props.version}`,
       }),
       handler: lambda.Handler.FROM_IMAGE,
       runtime: lambda.Runtime.FROM_IMAGE,
@@ -59,19 +74,23 @@ export class ApiStack extends cdk.Stack {
       memorySize: 128,
       logGroup,
       environment: {
+        HANDLER: "api-stations",
         DYNAMODB_TABLE_STATIONS: props.stationsTable.tableName,
+        S3_BUCKET: props.backupBucket.bucketName,
         ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+        METRICS_NAMESPACE: metricsNamespace,
       },
     });
 
     props.stationsTable.grantReadData(this.getStationsLambda);
+    props.backupBucket.grantRead(this.getStationsLambda, "current_state/*");
 
     this.getStationDetailsLambda = new lambda.Function(
       this,
       "GetStationDetailsLambda",
       {
         code: lambda.Code.fromEcrImage(props.ecrRepository, {
-          tagOrDigest: `api-getstationdetails-${props.version}`,
+          tagOrDigest: `lambda-${props.version}`,
         }),
         handler: lambda.Handler.FROM_IMAGE,
         runtime: lambda.Runtime.FROM_IMAGE,
@@ -79,20 +98,24 @@ export class ApiStack extends cdk.Stack {
         memorySize: 128,
         logGroup,
         environment: {
+          HANDLER: "api-station-details",
           DYNAMODB_TABLE_STATUS_HISTORY: props.statusHistoryTable.tableName,
+          DYNAMODB_TABLE_DAY_ROLLUPS: props.dayRollupsTable.tableName,
           ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+          METRICS_NAMESPACE: metricsNamespace,
         },
       }
     );
 
     props.statusHistoryTable.grantReadData(this.getStationDetailsLambda);
+    props.dayRollupsTable.grantReadData(this.getStationDetailsLambda);
 
     this.getStationsStatsLambda = new lambda.Function(
       this,
       "GetStationsStatsLambda",
       {
         code: lambda.Code.fromEcrImage(props.ecrRepository, {
-          tagOrDigest: `api-getstationsstats-${props.version}`,
+          tagOrDigest: `lambda-${props.version}`,
         }),
         handler: lambda.Handler.FROM_IMAGE,
         runtime: lambda.Runtime.FROM_IMAGE,
@@ -100,9 +123,13 @@ export class ApiStack extends cdk.Stack {
         memorySize: 128,
         logGroup,
         environment: {
+          HANDLER: "api-stations-stats",
           DYNAMODB_TABLE_STATIONS_STATS:
             props.stationsIncidentsStatsTable.tableName,
+          DYNAMODB_TABLE_FIX_DATE_RELIABILITY:
+            props.fixDateReliabilityTable.tableName,
           ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+          METRICS_NAMESPACE: metricsNamespace,
         },
       }
     );
@@ -110,6 +137,101 @@ export class ApiStack extends cdk.Stack {
     props.stationsIncidentsStatsTable.grantReadData(
       this.getStationsStatsLambda
     );
+    props.fixDateReliabilityTable.grantReadData(this.getStationsStatsLambda);
+
+    this.getServiceAreasLambda = new lambda.Function(
+      this,
+      "GetServiceAreasLambda",
+      {
+        code: lambda.Code.fromEcrImage(props.ecrRepository, {
+          tagOrDigest: `lambda-${props.version}`,
+        }),
+        handler: lambda.Handler.FROM_IMAGE,
+        runtime: lambda.Runtime.FROM_IMAGE,
+        timeout: cdk.Duration.seconds(30),
+        memorySize: 256,
+        logGroup,
+        environment: {
+          HANDLER: "api-service-areas",
+          DYNAMODB_TABLE_STATIONS: props.stationsTable.tableName,
+          ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+          METRICS_NAMESPACE: metricsNamespace,
+        },
+      }
+    );
+
+    props.stationsTable.grantReadData(this.getServiceAreasLambda);
+
+    this.getAddressStationLambda = new lambda.Function(
+      this,
+      "GetAddressStationLambda",
+      {
+        code: lambda.Code.fromEcrImage(props.ecrRepository, {
+          tagOrDigest: `lambda-${props.version}`,
+        }),
+        handler: lambda.Handler.FROM_IMAGE,
+        runtime: lambda.Runtime.FROM_IMAGE,
+        timeout: cdk.Duration.seconds(30),
+        memorySize: 512,
+        logGroup,
+        environment: {
+          HANDLER: "api-address-station",
+          DYNAMODB_TABLE_STATIONS: props.stationsTable.tableName,
+          S3_BUCKET: props.backupBucket.bucketName,
+          ADDRESS_INDEX_KEY: "address_index/addresses.idx",
+          ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+          METRICS_NAMESPACE: metricsNamespace,
+        },
+      }
+    );
+
+    props.stationsTable.grantReadData(this.getAddressStationLambda);
+    props.backupBucket.grantRead(
+      this.getAddressStationLambda,
+      "address_index/*"
+    );
+
+    this.getAvailabilityLambda = new lambda.Function(
+      this,
+      "GetAvailabilityLambda",
+      {
+        code: lambda.Code.fromEcrImage(props.ecrRepository, {
+          tagOrDigest: `lambda-${props.version}`,
+        }),
+        handler: lambda.Handler.FROM_IMAGE,
+        runtime: lambda.Runtime.FROM_IMAGE,
+        timeout: cdk.Duration.seconds(30),
+        memorySize: 128,
+        logGroup,
+        environment: {
+          HANDLER: "api-availability",
+          DYNAMODB_TABLE_ETL_RUNS: props.etlRunsTable.tableName,
+          ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+          METRICS_NAMESPACE: metricsNamespace,
+        },
+      }
+    );
+
+    props.etlRunsTable.grantReadData(this.getAvailabilityLambda);
+
+    this.getIncidentsLambda = new lambda.Function(this, "GetIncidentsLambda", {
+      code: lambda.Code.fromEcrImage(props.ecrRepository, {
+        tagOrDigest: `lambda-${props.version}`,
+      }),
+      handler: lambda.Handler.FROM_IMAGE,
+      runtime: lambda.Runtime.FROM_IMAGE,
+      timeout: cdk.Duration.seconds(30),
+      memorySize: 128,
+      logGroup,
+      environment: {
+        HANDLER: "api-incidents",
+        DYNAMODB_TABLE_INCIDENTS: props.incidentsTable.tableName,
+        ACCESS_CONTROL_ALLOW_ORIGIN: "*",
+        METRICS_NAMESPACE: metricsNamespace,
+      },
+    });
+
+    props.incidentsTable.grantReadData(this.getIncidentsLambda);
 
     this.apiGateway = new apigateway.RestApi(this, "TermoficareApi", {
       restApiName: `${props.envPrefix}-termoficare-api`,
@@ -151,6 +273,33 @@ export class ApiStack extends cdk.Stack {
       new apigateway.LambdaIntegration(this.getStationsStatsLambda)
     );
 
+    const serviceAreasResource =
+      this.apiGateway.root.addResource("service-areas");
+    serviceAreasResource.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(this.getServiceAreasLambda)
+    );
+
+    const addressStationResource =
+      this.apiGateway.root.addResource("address-station");
+    addressStationResource.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(this.getAddressStationLambda)
+    );
+
+    const availabilityResource =
+      this.apiGateway.root.addResource("availability");
+    availabilityResource.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(this.getAvailabilityLambda)
+    );
+
+    const incidentsResource = this.apiGateway.root.addResource("incidents");
+    incidentsResource.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(this.getIncidentsLambda)
+    );
+
     new cdk.CfnOutput(this, "ApiUrl", {
       value: this.apiGateway.url,
       description: "API Gateway URL",
@@ -175,5 +324,25 @@ export class ApiStack extends cdk.Stack {
       value: `${this.apiGateway.url}stations-stats`,
       description: "Stations statistics API endpoint",
     });
+
+    new cdk.CfnOutput(this, "ServiceAreasEndpoint", {
+      value: `${this.apiGateway.url}service-areas`,
+      description: "Stations service areas GeoJSON endpoint",
+    });
+
+    new cdk.CfnOutput(this, "AddressStationEndpoint", {
+      value: `${this.apiGateway.url}address-station?street=Ion%20Creanga&number=10`,
+      description: "Address to serving station API endpoint",
+    });
+
+    new cdk.CfnOutput(this, "AvailabilityEndpoint", {
+      value: `${this.apiGateway.url}availability?bucket=day`,
+      description: "CMTEB site availability API endpoint",
+    });
+
+    new cdk.CfnOutput(this, "IncidentsEndpoint", {
+      value: `${this.apiGateway.url}incidents?geoId=123`,
+      description: "Incidents API endpoint (open incidents without geoId)",
+    });
   }
 }
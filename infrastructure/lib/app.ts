@@ -34,6 +34,10 @@ const ecrStack = new EcrStack(app, "BucharestTermoficareEcr", {
   envPrefix,
 });
 
+// the ETL runs are keyed by their slot of the schedule interval
+const etlScheduleExpression = "cron(0,30 * * * ? *)"; // every 30 minutes (UTC)
+const etlScheduleInterval = "30m";
+
 const lambdaStack = new LambdaStack(app, "BucharestTermoficareLambda", {
   env,
   envPrefix,
@@ -43,6 +47,13 @@ const lambdaStack = new LambdaStack(app, "BucharestTermoficareLambda", {
   dayCountsTable: databaseStack.dayCountsTable,
   statusHistoryTable: databaseStack.statusHistoryTable,
   stationsIncidentsStatsTable: databaseStack.stationsIncidentStatsTable,
+  etlRunsTable: databaseStack.etlRunsTable,
+  incidentsTable: databaseStack.incidentsTable,
+  fixDateReliabilityTable: databaseStack.fixDateReliabilityTable,
+  dayRollupsTable: databaseStack.dayRollupsTable,
+  eventOutboxTable: databaseStack.eventOutboxTable,
+  runSlotsTable: databaseStack.runSlotsTable,
+  scheduleInterval: etlScheduleInterval,
   backupBucket: databaseStack.backupBucket,
 });
 
@@ -51,7 +62,7 @@ new ScheduleStack(app, "BucharestTermoficareSchedule", {
   envPrefix,
   etlLambda: lambdaStack.etlLambda,
   aggregateLambda: lambdaStack.aggregateLambda,
-  scheduleExpression: "cron(0,30 * * * ? *)", // Every hour at minute 0 (UTC)
+  scheduleExpression: etlScheduleExpression,
 });
 
 new ApiStack(app, "BucharestTermoficareApi", {
@@ -63,6 +74,11 @@ new ApiStack(app, "BucharestTermoficareApi", {
   stationsTable: databaseStack.stationsTable,
   statusHistoryTable: databaseStack.statusHistoryTable,
   stationsIncidentsStatsTable: databaseStack.stationsIncidentStatsTable,
+  etlRunsTable: databaseStack.etlRunsTable,
+  incidentsTable: databaseStack.incidentsTable,
+  fixDateReliabilityTable: databaseStack.fixDateReliabilityTable,
+  dayRollupsTable: databaseStack.dayRollupsTable,
+  backupBucket: databaseStack.backupBucket,
 });
 
 if (envPrefix === "prod") {
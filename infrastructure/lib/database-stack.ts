@@ -14,8 +14,15 @@ interface DatabaseStackProps extends cdk.StackProps {
 export class DatabaseStack extends cdk.Stack {
   public readonly stationsTable: dynamodb.Table;
   public readonly dayCountsTable: dynamodb.Table;
+  public readonly legacyDayCountsTable: dynamodb.Table;
   public readonly statusHistoryTable: dynamodb.Table;
   public readonly stationsIncidentStatsTable: dynamodb.Table;
+  public readonly etlRunsTable: dynamodb.Table;
+  public readonly incidentsTable: dynamodb.Table;
+  public readonly fixDateReliabilityTable: dynamodb.Table;
+  public readonly dayRollupsTable: dynamodb.Table;
+  public readonly eventOutboxTable: dynamodb.Table;
+  public readonly runSlotsTable: dynamodb.Table;
   public readonly backupBucket: s3.Bucket;
   public readonly streamProcessor: lambda.Function;
 
@@ -32,8 +39,9 @@ export class DatabaseStack extends cdk.Stack {
       },
     });
 
-    // TODO: break this table into partitions by years
-    this.dayCountsTable = new dynamodb.Table(this, "DayCountsTable", {
+    // counts keyed by Timestamp only, kept until its rows are copied to the
+    // year partitioned table with termoficare migrate-counts
+    this.legacyDayCountsTable = new dynamodb.Table(this, "DayCountsTable", {
       tableName: `${props.envPrefix}-day-counts`,
       partitionKey: { name: "Timestamp", type: dynamodb.AttributeType.NUMBER },
       billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
@@ -43,6 +51,17 @@ export class DatabaseStack extends cdk.Stack {
       },
     });
 
+    this.dayCountsTable = new dynamodb.Table(this, "DayCountsByYearTable", {
+      tableName: `${props.envPrefix}-day-counts-by-year`,
+      partitionKey: { name: "Year", type: dynamodb.AttributeType.NUMBER },
+      sortKey: { name: "Timestamp", type: dynamodb.AttributeType.NUMBER },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+      pointInTimeRecoverySpecification: {
+        pointInTimeRecoveryEnabled: true,
+      },
+    });
+
     this.statusHistoryTable = new dynamodb.Table(this, "StatusHistoryTable", {
       tableName: `${props.envPrefix}-status-history`,
       partitionKey: { name: "GeoId", type: dynamodb.AttributeType.NUMBER },
@@ -50,6 +69,8 @@ export class DatabaseStack extends cdk.Stack {
       billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
       removalPolicy: cdk.RemovalPolicy.DESTROY,
       stream: dynamodb.StreamViewType.NEW_IMAGE,
+      // set by the ETL, the aggregator checks the rows are archived before they expire
+      timeToLiveAttribute: "ExpiresAt",
       pointInTimeRecoverySpecification: {
         pointInTimeRecoveryEnabled: true,
       },
@@ -70,10 +91,96 @@ export class DatabaseStack extends cdk.Stack {
       }
     );
 
+    // one row per ETL run, successful or not, sorted by start time then run id
+    this.etlRunsTable = new dynamodb.Table(this, "EtlRunsTable", {
+      tableName: `${props.envPrefix}-etl-runs`,
+      partitionKey: { name: "Year", type: dynamodb.AttributeType.NUMBER },
+      sortKey: { name: "RunKey", type: dynamodb.AttributeType.STRING },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+      pointInTimeRecoverySpecification: {
+        pointInTimeRecoveryEnabled: true,
+      },
+    });
+
+    // incidents maintained by the ETL, one row per station outage with its timeline
+    this.incidentsTable = new dynamodb.Table(this, "IncidentsTable", {
+      tableName: `${props.envPrefix}-incidents`,
+      partitionKey: { name: "GeoId", type: dynamodb.AttributeType.NUMBER },
+      sortKey: { name: "StartTime", type: dynamodb.AttributeType.NUMBER },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+      pointInTimeRecoverySpecification: {
+        pointInTimeRecoveryEnabled: true,
+      },
+    });
+
+    // sparse index, only open incidents have an OpenKey
+    this.incidentsTable.addGlobalSecondaryIndex({
+      indexName: "OpenIncidents",
+      partitionKey: { name: "OpenKey", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "StartTime", type: dynamodb.AttributeType.NUMBER },
+    });
+
+    // city-wide and per cause fix date slippage, rewritten by the aggregator
+    this.fixDateReliabilityTable = new dynamodb.Table(
+      this,
+      "FixDateReliabilityTable",
+      {
+        tableName: `${props.envPrefix}-fix-date-reliability`,
+        partitionKey: { name: "City", type: dynamodb.AttributeType.STRING },
+        sortKey: { name: "Scope", type: dynamodb.AttributeType.STRING },
+        billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+        removalPolicy: cdk.RemovalPolicy.DESTROY,
+      }
+    );
+
+    // daily summary of every station, kept after the status rows expired
+    this.dayRollupsTable = new dynamodb.Table(this, "DayRollupsTable", {
+      tableName: `${props.envPrefix}-station-day-rollups`,
+      partitionKey: { name: "GeoId", type: dynamodb.AttributeType.NUMBER },
+      sortKey: { name: "Date", type: dynamodb.AttributeType.STRING },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+      pointInTimeRecoverySpecification: {
+        pointInTimeRecoveryEnabled: true,
+      },
+    });
+
+    // domain events written by the ETL with the state they describe, removed
+    // once published, the TTL drops the ones that could never be delivered
+    this.eventOutboxTable = new dynamodb.Table(this, "EventOutboxTable", {
+      tableName: `${props.envPrefix}-event-outbox`,
+      partitionKey: { name: "EventId", type: dynamodb.AttributeType.STRING },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+      timeToLiveAttribute: "ExpiresAt",
+    });
+
+    // one row per schedule slot of the ETL, claimed with a conditional write so
+    // a duplicate schedule event or a retry never runs the slot twice
+    this.runSlotsTable = new dynamodb.Table(this, "RunSlotsTable", {
+      tableName: `${props.envPrefix}-etl-run-slots`,
+      partitionKey: { name: "Slot", type: dynamodb.AttributeType.NUMBER },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+      timeToLiveAttribute: "ExpiresAt",
+    });
+
     // S3 bucket for backups
     this.backupBucket = new s3.Bucket(this, "BackupBucket", {
       bucketName: `${props.envPrefix}-termoficare-backups`,
       removalPolicy: cdk.RemovalPolicy.RETAIN,
+      lifecycleRules: [
+        {
+          // the superseded current state documents, the pointer names the
+          // latest one. The API caches a pinned version for this long, see
+          // storage.CurrentStateRetention
+          id: "ExpireCurrentStateVersions",
+          prefix: "current_state/versions/",
+          expiration: cdk.Duration.days(30),
+        },
+      ],
     });
 
     const streamLogGroup = logs.LogGroup.fromLogGroupName(
@@ -3,6 +3,7 @@ import * as lambda from "aws-cdk-lib/aws-lambda";
 import * as dynamodb from "aws-cdk-lib/aws-dynamodb";
 import * as s3 from "aws-cdk-lib/aws-s3";
 import * as logs from "aws-cdk-lib/aws-logs";
+import * as sns from "aws-cdk-lib/aws-sns";
 import { Construct } from "constructs";
 
 interface LambdaStackProps extends cdk.StackProps {
@@ -13,12 +14,21 @@ interface LambdaStackProps extends cdk.StackProps {
   dayCountsTable: dynamodb.Table;
   statusHistoryTable: dynamodb.Table;
   stationsIncidentsStatsTable: dynamodb.Table;
+  etlRunsTable: dynamodb.Table;
+  incidentsTable: dynamodb.Table;
+  fixDateReliabilityTable: dynamodb.Table;
+  dayRollupsTable: dynamodb.Table;
+  eventOutboxTable: dynamodb.Table;
+  runSlotsTable: dynamodb.Table;
+  // scheduleInterval is the interval of the ETL schedule expression
+  scheduleInterval: string;
   backupBucket: s3.Bucket;
 }
 
 export class LambdaStack extends cdk.Stack {
   public readonly etlLambda: lambda.Function;
   public readonly aggregateLambda: lambda.Function;
+  public readonly stationEventsTopic: sns.Topic;
 
   constructor(scope: Construct, id: string, props: LambdaStackProps) {
     super(scope, id, props);
@@ -35,9 +45,28 @@ export class LambdaStack extends cdk.Stack {
       `${props.envPrefix}-TermoficareAggregator`
     );
 
+    // unchanged stations only get a status row at this interval, the
+    // aggregator needs the same value to rebuild the observation periods
+    const statusHeartbeatInterval = "6h";
+
+    // status rows expire after this, the aggregator checks they are archived first
+    const statusRetention = "2160h";
+
+    // the lambdas write their metrics to their logs, in embedded metric format
+    const metricsNamespace = `Termoficare/${props.envPrefix}`;
+
+    // residents served per station, uploaded to the bucket next to the data
+    const stationWeightsKey = "station_weights/station_weights.csv";
+
+    // station and incident changes as CloudEvents, subscriptions can filter
+    // on the "type" message attribute
+    this.stationEventsTopic = new sns.Topic(this, "StationEventsTopic", {
+      topicName: `${props.envPrefix}-termoficare-station-events`,
+    });
+
     this.etlLambda = new lambda.Function(this, "TermoficareLambda", {
       code: lambda.Code.fromEcrImage(props.ecrRepository, {
-        tagOrDigest: `etl-${props.version}`,
+        tagOrDigest: `lambda-${props.version}`,
       }),
       handler: lambda.Handler.FROM_IMAGE,
       runtime: lambda.Runtime.FROM_IMAGE,
@@ -45,18 +74,45 @@ export class LambdaStack extends cdk.Stack {
       memorySize: 128,
       logGroup: etlLogGroup,
       environment: {
+        HANDLER: "etl",
         DYNAMODB_TABLE_STATIONS: props.stationsTable.tableName,
         DYNAMODB_TABLE_DAY_COUNTS: props.dayCountsTable.tableName,
         DYNAMODB_TABLE_STATUSES: props.statusHistoryTable.tableName,
+        DYNAMODB_TABLE_ETL_RUNS: props.etlRunsTable.tableName,
+        DYNAMODB_TABLE_INCIDENTS: props.incidentsTable.tableName,
+        S3_BUCKET: props.backupBucket.bucketName,
+        CHANGE_ONLY_PERSISTENCE: "true",
+        STATUS_HEARTBEAT_INTERVAL: statusHeartbeatInterval,
+        STATION_INACTIVE_GRACE_PERIOD: "24h",
+        STATUS_RETENTION: statusRetention,
+        PLAUSIBILITY_MAX_STATION_DROP: "0.2",
+        PLAUSIBILITY_MAX_NEW_STATIONS: "0.3",
+        PLAUSIBILITY_REJECT_SINGLE_CATEGORY: "true",
+        STATION_WEIGHTS_KEY: stationWeightsKey,
+        DYNAMODB_TABLE_EVENT_OUTBOX: props.eventOutboxTable.tableName,
+        DYNAMODB_TABLE_RUN_SLOTS: props.runSlotsTable.tableName,
+        SCHEDULE_INTERVAL: props.scheduleInterval,
+        EVENTS_SINK: "sns",
+        EVENTS_TARGET: this.stationEventsTopic.topicArn,
+        METRICS_NAMESPACE: metricsNamespace,
       },
     });
     props.stationsTable.grantReadWriteData(this.etlLambda);
     props.dayCountsTable.grantReadWriteData(this.etlLambda);
     props.statusHistoryTable.grantReadWriteData(this.etlLambda);
+    props.etlRunsTable.grantWriteData(this.etlLambda);
+    props.incidentsTable.grantReadWriteData(this.etlLambda);
+    props.backupBucket.grantPut(this.etlLambda, "quarantine/*");
+    // the pointer is read before its conditional swap
+    props.backupBucket.grantReadWrite(this.etlLambda, "current_state/*");
+    props.backupBucket.grantRead(this.etlLambda, stationWeightsKey);
+    props.eventOutboxTable.grantReadWriteData(this.etlLambda);
+    props.runSlotsTable.grantReadWriteData(this.etlLambda);
+    this.stationEventsTopic.grantPublish(this.etlLambda);
 
     this.aggregateLambda = new lambda.Function(this, "AggregateLambda", {
       code: lambda.Code.fromEcrImage(props.ecrRepository, {
-        tagOrDigest: `aggregate-${props.version}`,
+        tagOrDigest: `lambda-${props.version}`,
       }),
       handler: lambda.Handler.FROM_IMAGE,
       runtime: lambda.Runtime.FROM_IMAGE,
@@ -64,11 +120,33 @@ export class LambdaStack extends cdk.Stack {
       memorySize: 3008,
       logGroup: aggregatorLogGroup,
       environment: {
+        HANDLER: "aggregate",
         DYNAMODB_TABLE_STATIONS: props.stationsIncidentsStatsTable.tableName,
         S3_BUCKET: props.backupBucket.bucketName,
+        DYNAMODB_TABLE_DAY_COUNTS: props.dayCountsTable.tableName,
+        STATUS_HEARTBEAT_INTERVAL: statusHeartbeatInterval,
+        DYNAMODB_TABLE_ETL_RUNS: props.etlRunsTable.tableName,
+        DYNAMODB_TABLE_INCIDENTS: props.incidentsTable.tableName,
+        DYNAMODB_TABLE_FIX_DATE_RELIABILITY:
+          props.fixDateReliabilityTable.tableName,
+        DYNAMODB_TABLE_DAY_ROLLUPS: props.dayRollupsTable.tableName,
+        ROLLUP_DAYS: "3",
+        STATUS_RETENTION: statusRetention,
+        DYNAMODB_TABLE_HEATING_STATIONS: props.stationsTable.tableName,
+        DYNAMODB_TABLE_STATUSES: props.statusHistoryTable.tableName,
+        STATION_WEIGHTS_KEY: stationWeightsKey,
+        METRICS_NAMESPACE: metricsNamespace,
       },
     });
     props.stationsIncidentsStatsTable.grantWriteData(this.aggregateLambda);
+    props.dayCountsTable.grantReadData(this.aggregateLambda);
+    props.etlRunsTable.grantReadData(this.aggregateLambda);
+    props.incidentsTable.grantReadData(this.aggregateLambda);
+    props.fixDateReliabilityTable.grantReadWriteData(this.aggregateLambda);
+    props.dayRollupsTable.grantWriteData(this.aggregateLambda);
+    props.stationsTable.grantReadData(this.aggregateLambda);
+    // unarchived rows are rewritten with a later expiry
+    props.statusHistoryTable.grantReadWriteData(this.aggregateLambda);
     props.backupBucket.grantRead(this.aggregateLambda);
   }
 }
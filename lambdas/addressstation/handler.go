@@ -0,0 +1,137 @@
+// Package addressstation serves the station serving an address.
+package addressstation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/addressindex"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/geometry"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the station of an address.
+type Handler struct {
+	stationRepository storage.StationRepository
+	addressIndex      *addressindex.Index
+	allowOrigin       string
+}
+
+type AddressAPI struct {
+	Street    string  `json:"street"`
+	Number    string  `json:"number"`
+	Exact     bool    `json:"exact"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type AddressStationAPI struct {
+	GeoId          string  `json:"geoId"`
+	Name           string  `json:"name"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	LastStatus     string  `json:"lastStatus"`
+	DistanceMeters float64 `json:"distanceMeters"`
+	HistoryLink    string  `json:"historyLink"`
+}
+
+type ApiResponseData struct {
+	Data struct {
+		Address AddressAPI        `json:"address"`
+		Station AddressStationAPI `json:"station"`
+	} `json:"data"`
+}
+
+// Get the active stations from the stations repository, a station gone from
+// the map no longer serves any area
+func (h *Handler) getStations(ctx context.Context) ([]scrapper.HeatingStation, error) {
+	stations, err := h.stationRepository.ListStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return scrapper.ActiveStations(stations), nil
+}
+
+// The serving station is the one whose service area contains the address,
+// which is by construction the nearest one.
+func findServingStation(stations []scrapper.HeatingStation, addr addressindex.Address) (scrapper.HeatingStation, float64, bool) {
+	sites := make([]geometry.Site, len(stations))
+	stationsById := make(map[int64]scrapper.HeatingStation, len(stations))
+	for i, station := range stations {
+		sites[i] = geometry.Site{
+			GeoId: station.GeoId,
+			Point: geometry.Point{
+				Longitude: station.Longitude,
+				Latitude:  station.Latitude,
+			},
+		}
+		stationsById[station.GeoId] = station
+	}
+
+	site, distance, found := geometry.NearestSite(sites, geometry.Point{
+		Longitude: addr.Longitude,
+		Latitude:  addr.Latitude,
+	})
+	return stationsById[site.GeoId], distance, found
+}
+
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	street := request.QueryStringParameters["street"]
+	number := request.QueryStringParameters["number"]
+	if street == "" || number == "" {
+		return httpapi.Error(headers, http.StatusBadRequest, "Missing street and/or number query parameter"), nil
+	}
+
+	match, err := h.addressIndex.Lookup(street, number)
+	if errors.Is(err, addressindex.ErrStreetNotFound) {
+		return httpapi.Error(headers, http.StatusNotFound, "Address not found"), nil
+	}
+	if err != nil {
+		slog.Error("Unable to lookup address", "error_msg", err.Error())
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	stations, err := h.getStations(ctx)
+	if err != nil {
+		slog.Error("Unable to get stations", "error_msg", err.Error())
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	station, distance, found := findServingStation(stations, match.Address)
+	if !found {
+		return httpapi.Error(headers, http.StatusNotFound, "No station found"), nil
+	}
+
+	var respData ApiResponseData
+	respData.Data.Address = AddressAPI{
+		Street:    match.Address.Street,
+		Number:    match.Address.Number,
+		Exact:     match.Exact,
+		Latitude:  match.Address.Latitude,
+		Longitude: match.Address.Longitude,
+	}
+	respData.Data.Station = AddressStationAPI{
+		GeoId:          fmt.Sprintf("%d", station.GeoId),
+		Name:           station.Name,
+		Latitude:       station.Latitude,
+		Longitude:      station.Longitude,
+		LastStatus:     station.LastStatus,
+		DistanceMeters: distance,
+		HistoryLink:    "/station-details?geoId=" + url.QueryEscape(fmt.Sprintf("%d", station.GeoId)),
+	}
+
+	return httpapi.JSON(headers, respData), nil
+}
@@ -0,0 +1,70 @@
+package addressstation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/addressindex"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// RequiredVariables are the table and the bucket of the address index the
+// handler reads.
+var RequiredVariables = []string{"DYNAMODB_TABLE_STATIONS", "S3_BUCKET"}
+
+// New sets up the handler of the station of an address, and loads the address
+// index.
+func New(ctx context.Context, cfg config.API) (*Handler, error) {
+	h := &Handler{allowOrigin: cfg.AllowOrigin}
+
+	var indexReader io.ReadCloser
+
+	// a storage directory serves the API from local files instead of DynamoDB and S3,
+	// the address index is then read from the same directory
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file store: %w", err)
+		}
+		h.stationRepository = fileStore
+
+		indexReader, err = os.Open(filepath.Join(cfg.StorageDir, filepath.FromSlash(cfg.AddressIndexKey)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open address index %s: %w", cfg.AddressIndexKey, err)
+		}
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		h.stationRepository = storage.NewDynamoStationRepository(dynamodb.NewFromConfig(awsCfg), cfg.StationsTable)
+
+		getResult, err := s3.NewFromConfig(awsCfg).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(cfg.Bucket),
+			Key:    aws.String(cfg.AddressIndexKey),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to download address index %s: %w", cfg.AddressIndexKey, err)
+		}
+		indexReader = getResult.Body
+	}
+	defer indexReader.Close()
+
+	index, err := addressindex.ReadIndex(indexReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load address index %s: %w", cfg.AddressIndexKey, err)
+	}
+	h.addressIndex = index
+	slog.Info("Address index loaded", "numAddresses", index.NumAddresses())
+
+	return h, nil
+}
@@ -0,0 +1,366 @@
+// Package aggregate is the nightly function computing the statistics, the
+// rollups and the archive checks of the archived history.
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/metrics"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/retention"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Aggregator computes the statistics of the archived history, every
+// repository is set up once per lambda instance.
+type Aggregator struct {
+	statusArchive           storage.StatusArchive
+	incidentStatsRepository storage.IncidentStatsRepository
+	countsRepository        storage.DayCountsRepository
+	runLedgerRepository     storage.RunLedgerRepository
+	incidentRepository      storage.IncidentRepository
+	reliabilityRepository   storage.FixDateReliabilityRepository
+	rollupRepository        storage.StationRollupRepository
+	// stationRepository and statusRetentionRepository are only set with a status retention
+	stationRepository         storage.StationRepository
+	statusRetentionRepository storage.StatusRetentionRepository
+
+	heartbeat               time.Duration
+	statusRetention         time.Duration
+	rollupDays              int
+	observationGapThreshold time.Duration
+	stationWeights          *scrapper.StationWeights
+	location                *time.Location
+	metrics                 *metrics.Emitter
+}
+
+// Handle processes EventBridge schedule rule events
+func (a *Aggregator) Handle(ctx context.Context, event events.CloudWatchEvent) (err error) {
+
+	ctx, span := tracing.StartInvocation(tracing.ContextFromEventID(ctx, event.ID), "aggregate.invoke")
+	slog.Info("Starting rank stations processing...")
+
+	start := time.Now()
+	result := aggregateResult{}
+	var verifyErr error
+	defer func() {
+		err = errors.Join(err, verifyErr)
+		result.emit(a.metrics, time.Since(start), err)
+		span.RecordError(err)
+		span.End()
+		tracing.Flush(ctx)
+	}()
+
+	cutoffTimestamp := time.Now().AddDate(-1, 0, 0)
+	dataset, err := a.statusArchive.ListStatusesSince(ctx, cutoffTimestamp)
+	if err != nil {
+		return err
+	}
+	result.numRowsProcessed = len(dataset)
+	if dayArchive, ok := a.statusArchive.(storage.DayArchive); ok {
+		result.numMissingBackupDays = len(dayArchive.MissingDays())
+	}
+
+	// the status rows expire whatever happens to the statistics, so the archive
+	// is checked first and its failure alarms on its own metric
+	verifyErr = a.verifyArchive(ctx, dataset)
+	if verifyErr != nil {
+		result.numArchiveCheckFailures = 1
+		slog.Error("Archive check of the expiring statuses failed", "error_msg", verifyErr.Error())
+	}
+
+	slog.Info("Filtered stations by date, proceeding with incident computations", "numRows", len(dataset))
+	computeCtx, computeSpan := tracing.Start(ctx, "aggregate.compute")
+	defer computeSpan.End()
+	opts := scrapper.IncidentStatisticsOptions{}
+	if a.heartbeat > 0 {
+		lastSnapshotTime, err := a.getLastSnapshotTime(computeCtx)
+		if err != nil {
+			return err
+		}
+		slog.Info("Computing statistics of change-only history", "heartbeat", a.heartbeat.String(), "lastSnapshotTime", lastSnapshotTime)
+		opts.Heartbeat = a.heartbeat
+		opts.ObservedUntil = lastSnapshotTime
+	}
+
+	opts.Gaps, err = a.getObservationGaps(computeCtx, cutoffTimestamp)
+	if err != nil {
+		return err
+	}
+	stationsIncidentStats := scrapper.ComputeIncidentStatisticsWithOptions(dataset, opts)
+
+	reliability, err := a.getFixDateReliability(computeCtx, cutoffTimestamp)
+	if err != nil {
+		return err
+	}
+	scrapper.ApplyFixDateReliability(stationsIncidentStats, reliability)
+
+	unweightedStations := scrapper.ApplyStationWeights(stationsIncidentStats, a.stationWeights)
+	if len(unweightedStations) > 0 {
+		slog.Warn("Stations missing from the weights table, default weight used", "numStations", len(unweightedStations))
+	}
+
+	computeSpan.End()
+
+	slog.Info("Incident statistics computed, writing to storage", "numRows", len(stationsIncidentStats))
+	writeCtx, writeSpan := tracing.Start(ctx, "aggregate.write_stats")
+	defer writeSpan.End()
+	err = a.incidentStatsRepository.PutIncidentStats(writeCtx, stationsIncidentStats)
+	if err != nil {
+		return fmt.Errorf("failed to write station stats: %w", err)
+	}
+	result.numStatsRowsWritten = len(stationsIncidentStats)
+
+	err = a.writeFixDateReliability(writeCtx, reliability.DbRows("Bucharest"))
+	if err != nil {
+		return err
+	}
+	writeSpan.End()
+
+	result.numRollupsWritten, err = a.writeRollups(ctx, dataset, opts.ObservedUntil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// aggregateResult counts what a run read and wrote, for its metrics.
+type aggregateResult struct {
+	numRowsProcessed     int
+	numMissingBackupDays int
+	numStatsRowsWritten  int
+	numRollupsWritten    int
+	// numArchiveCheckFailures is 1 when the expiring status rows could not be checked
+	numArchiveCheckFailures int
+}
+
+func (r aggregateResult) emit(emitter *metrics.Emitter, duration time.Duration, runErr error) {
+	success := 0
+	if runErr == nil {
+		success = 1
+	}
+	emitter.NewEntry().
+		Count("RowsProcessed", r.numRowsProcessed).
+		Count("MissingBackupDays", r.numMissingBackupDays).
+		Count("StatsRowsWritten", r.numStatsRowsWritten).
+		Count("RollupsWritten", r.numRollupsWritten).
+		Count("ArchiveCheckFailures", r.numArchiveCheckFailures).
+		Count("Success", success).
+		Duration("Duration", duration).
+		Emit()
+}
+
+// getLastSnapshotTime returns the time of the most recent snapshot, every ETL run
+// writes a day counts row even when no station changed.
+func (a *Aggregator) getLastSnapshotTime(ctx context.Context) (int64, error) {
+	counts, found, err := a.countsRepository.LatestCounts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest day counts: %w", err)
+	}
+	if !found {
+		return 0, errors.New("no snapshot found in day counts")
+	}
+	return counts.Time, nil
+}
+
+// getObservationGaps returns the periods the ledger has no successful run for,
+// during which nothing is known about the stations.
+func (a *Aggregator) getObservationGaps(ctx context.Context, since time.Time) (scrapper.ObservationGaps, error) {
+	now := time.Now()
+	runs, err := a.runLedgerRepository.ListRuns(ctx, since, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etl runs: %w", err)
+	}
+
+	gaps := scrapper.ComputeObservationGaps(runs, a.observationGapThreshold, now)
+	var unobservedSeconds int64
+	for _, gap := range gaps {
+		unobservedSeconds += gap.End - gap.Start
+	}
+	slog.Info("Observation gaps computed from the run ledger",
+		"numRuns", len(runs),
+		"numGaps", len(gaps),
+		"unobservedHours", float64(unobservedSeconds)/3600.0,
+	)
+	return gaps, nil
+}
+
+// getFixDateReliability sums how the estimated fix dates of the incidents of
+// the period held up.
+func (a *Aggregator) getFixDateReliability(ctx context.Context, since time.Time) (scrapper.FixDateReliability, error) {
+	incidents, err := a.incidentRepository.ListIncidentsSince(ctx, since)
+	if err != nil {
+		return scrapper.FixDateReliability{}, fmt.Errorf("failed to list incidents: %w", err)
+	}
+
+	reliability := scrapper.ComputeFixDateReliability(incidents)
+	slog.Info("Fix date reliability computed from the incidents",
+		"numIncidents", len(incidents),
+		"numPromisedIncidents", reliability.City.NumIncidents,
+		"numPostponements", reliability.City.NumPostponements,
+		"totalSlippageHours", reliability.City.TotalSlippageHours(),
+		"beatFirstPromiseRate", reliability.City.BeatFirstPromiseRate(),
+	)
+	return reliability, nil
+}
+
+// writeFixDateReliability replaces the reliability rows of the city: the
+// scopes missing from the new rows, such as a cause without incident left in
+// the window, are deleted instead of keeping their last values.
+func (a *Aggregator) writeFixDateReliability(ctx context.Context, rows []scrapper.FixDateReliabilityDbRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	city := rows[0].City
+	if err := a.reliabilityRepository.PutFixDateReliability(ctx, rows); err != nil {
+		return fmt.Errorf("failed to write fix date reliability: %w", err)
+	}
+
+	stored, err := a.reliabilityRepository.ListFixDateReliability(ctx, city)
+	if err != nil {
+		return fmt.Errorf("failed to list fix date reliability: %w", err)
+	}
+	scopes := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		scopes[row.Scope] = true
+	}
+	stale := make([]string, 0)
+	for _, row := range stored {
+		if !scopes[row.Scope] {
+			stale = append(stale, row.Scope)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	slog.Info("Deleting fix date reliability scopes left out of the window", "scopes", stale)
+	if err := a.reliabilityRepository.DeleteFixDateReliability(ctx, city, stale); err != nil {
+		return fmt.Errorf("failed to delete stale fix date reliability: %w", err)
+	}
+	return nil
+}
+
+// sampleDuration is how long a status of the archive holds without a newer row,
+// a full persistence history has a row per run.
+func (a *Aggregator) sampleDuration() time.Duration {
+	if a.heartbeat == 0 {
+		return time.Hour
+	}
+	return a.heartbeat
+}
+
+// writeRollups recomputes the daily rollups of the last complete days from the
+// archive, the statuses of the current day are still coming.
+func (a *Aggregator) writeRollups(ctx context.Context, dataset []scrapper.HeatingStationStatus, observedUntil int64) (int, error) {
+	return a.putRollups(ctx, dataset, observedUntil, scrapper.RollupDays(time.Now(), a.rollupDays, a.location))
+}
+
+// RecomputeRollups rewrites the daily rollups of the days from first to last
+// included from the archive, such as after a fix of the rollup computation or a
+// restored backup. Only complete days can be recomputed.
+func (a *Aggregator) RecomputeRollups(ctx context.Context, first, last string) (written int, err error) {
+	ctx, span := tracing.StartInvocation(ctx, "aggregate.recompute_rollups")
+	defer func() {
+		span.SetAttribute("rollups.num_rows", written)
+		span.RecordError(err)
+		span.End()
+		tracing.Flush(ctx)
+	}()
+
+	days, err := scrapper.RollupDateRange(first, last)
+	if err != nil {
+		return 0, err
+	}
+	if lastComplete := scrapper.RollupDays(time.Now(), 1, a.location)[0]; last > lastComplete {
+		return 0, fmt.Errorf("last date %s is not a complete day, the last one is %s", last, lastComplete)
+	}
+	firstMidnight, err := time.ParseInLocation(scrapper.RollupDateLayout, first, a.location)
+	if err != nil {
+		return 0, fmt.Errorf("invalid first date: %w", err)
+	}
+
+	// the status holding at the first midnight was written up to a sample before it
+	dataset, err := a.statusArchive.ListStatusesSince(ctx, firstMidnight.Add(-a.sampleDuration()))
+	if err != nil {
+		return 0, err
+	}
+
+	var observedUntil int64
+	if a.heartbeat > 0 {
+		observedUntil, err = a.getLastSnapshotTime(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return a.putRollups(ctx, dataset, observedUntil, days)
+}
+
+// putRollups writes the rollups of the given days, computed from the dataset.
+func (a *Aggregator) putRollups(ctx context.Context, dataset []scrapper.HeatingStationStatus, observedUntil int64, days []string) (int, error) {
+	ctx, span := tracing.Start(ctx, "aggregate.rollups")
+	defer span.End()
+
+	if len(days) == 0 {
+		return 0, nil
+	}
+	if observedUntil == 0 {
+		observedUntil = time.Now().Unix()
+	}
+	wantedDays := make(map[string]bool, len(days))
+	for _, day := range days {
+		wantedDays[day] = true
+	}
+
+	rollups := make([]scrapper.StationDayRollup, 0)
+	for _, rollup := range scrapper.ComputeStationDayRollups(dataset, scrapper.RollupOptions{
+		Location:          a.location,
+		MaxSampleDuration: a.sampleDuration(),
+		ObservedUntil:     observedUntil,
+	}) {
+		if wantedDays[rollup.Date] {
+			rollups = append(rollups, rollup)
+		}
+	}
+
+	slog.Info("Daily rollups computed, writing to storage", "numDays", len(days), "oldestDay", days[len(days)-1], "numRows", len(rollups))
+	if err := a.rollupRepository.PutRollups(ctx, rollups); err != nil {
+		return 0, fmt.Errorf("failed to write daily rollups: %w", err)
+	}
+	return len(rollups), nil
+}
+
+// verifyArchive checks the status rows about to expire are in the archive the
+// dataset was read from.
+func (a *Aggregator) verifyArchive(ctx context.Context, archived []scrapper.HeatingStationStatus) error {
+	if a.statusRetention == 0 {
+		return nil
+	}
+
+	stations, err := a.stationRepository.ListStations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list stations: %w", err)
+	}
+	geoIds := make([]int64, 0, len(stations))
+	for _, station := range stations {
+		geoIds = append(geoIds, station.GeoId)
+	}
+
+	result, err := retention.VerifyArchive(ctx, a.statusRetentionRepository, geoIds, archived, retention.DefaultPolicy(a.statusRetention), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to verify the archive of expiring statuses: %w", err)
+	}
+	slog.Info("Expiring status rows checked against the archive",
+		"numStations", result.NumStations,
+		"numExpiring", result.NumExpiring,
+		"numUnarchived", result.NumUnarchived,
+	)
+	return nil
+}
@@ -0,0 +1,88 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"time"
+	// the lambda image has no time zone database
+	_ "time/tzdata"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/metrics"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// New sets up the aggregator of the configuration, its metrics are written to
+// emitter.
+func New(ctx context.Context, cfg config.Aggregator, emitter *metrics.Emitter) (*Aggregator, error) {
+	a := &Aggregator{
+		heartbeat:               cfg.Heartbeat,
+		statusRetention:         cfg.StatusRetention,
+		rollupDays:              cfg.RollupDays,
+		observationGapThreshold: cfg.ObservationGapThreshold,
+		metrics:                 emitter,
+	}
+
+	// a storage directory runs the function against local files instead of S3 and DynamoDB
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file store: %w", err)
+		}
+		a.statusArchive = fileStore
+		a.incidentStatsRepository = fileStore
+		a.countsRepository = fileStore
+		a.runLedgerRepository = fileStore
+		a.incidentRepository = fileStore
+		a.reliabilityRepository = fileStore
+		a.rollupRepository = fileStore
+		a.stationRepository = fileStore
+		a.statusRetentionRepository = fileStore
+
+		a.stationWeights, err = storage.LoadFileStationWeights(cfg.StorageDir, cfg.StationWeightsKey, cfg.DefaultStationWeight)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		dbClient := dynamodb.NewFromConfig(awsCfg)
+
+		s3Client := s3.NewFromConfig(awsCfg)
+		a.statusArchive = storage.NewS3StatusArchive(s3Client, cfg.Bucket)
+		a.stationWeights, err = storage.LoadS3StationWeights(ctx, s3Client, cfg.Bucket, cfg.StationWeightsKey, cfg.DefaultStationWeight)
+		if err != nil {
+			return nil, err
+		}
+		a.incidentStatsRepository = storage.NewDynamoIncidentStatsRepository(dbClient, cfg.StatsTable)
+		a.runLedgerRepository = storage.NewDynamoRunLedgerRepository(dbClient, cfg.EtlRunsTable)
+		a.incidentRepository = storage.NewDynamoIncidentRepository(dbClient, cfg.IncidentsTable)
+		a.reliabilityRepository = storage.NewDynamoFixDateReliabilityRepository(dbClient, cfg.FixDateReliabilityTable)
+		a.rollupRepository = storage.NewDynamoStationRollupRepository(dbClient, cfg.DayRollupsTable)
+
+		// the status rows about to expire are checked against the archive
+		if cfg.StatusRetention > 0 {
+			a.stationRepository = storage.NewDynamoStationRepository(dbClient, cfg.StationsTable)
+			a.statusRetentionRepository = storage.NewDynamoStatusHistoryRepository(dbClient, cfg.StatusesTable)
+		}
+
+		// the status history is persisted in change-only mode when a heartbeat is set,
+		// the day counts table then tells when the last snapshot was taken
+		if cfg.Heartbeat > 0 {
+			a.countsRepository = storage.NewDynamoDayCountsRepository(dbClient, cfg.DayCountsTable)
+		}
+	}
+
+	var err error
+	a.location, err = time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the Europe/Bucharest time zone: %w", err)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,121 @@
+// Package availability serves the availability of the CMTEB site seen by the
+// ETL runs.
+package availability
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the site availability.
+type Handler struct {
+	runLedgerRepository storage.RunLedgerRepository
+	allowOrigin         string
+}
+
+// bucketSizes are the accepted values of the bucket parameter
+var bucketSizes = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// Availability is the CMTEB site availability seen by the ETL runs of a range.
+type Availability struct {
+	NumRuns      int                           `json:"numRuns"`
+	NumAvailable int                           `json:"numAvailable"`
+	Availability float64                       `json:"availability"`
+	LastRun      *scrapper.EtlRun              `json:"lastRun"`
+	Buckets      []scrapper.AvailabilityBucket `json:"buckets"`
+}
+
+type ApiResponseData struct {
+	Data Availability `json:"data"`
+}
+
+// parseQuery reads the from and to unix timestamps and the bucket size of the
+// query, the range defaults to the last 30 days and the bucket to a day
+func parseQuery(params map[string]string) (from, to time.Time, bucketSize time.Duration, err error) {
+	to = time.Now()
+	if toStr := params["to"]; toStr != "" {
+		toUnix, err := strconv.ParseInt(toStr, 10, 64)
+		if err != nil {
+			return from, to, bucketSize, err
+		}
+		to = time.Unix(toUnix, 0)
+	}
+
+	from = to.AddDate(0, 0, -30)
+	if fromStr := params["from"]; fromStr != "" {
+		fromUnix, err := strconv.ParseInt(fromStr, 10, 64)
+		if err != nil {
+			return from, to, bucketSize, err
+		}
+		from = time.Unix(fromUnix, 0)
+	}
+
+	if from.After(to) {
+		return from, to, bucketSize, errors.New("from is after to")
+	}
+
+	bucket := params["bucket"]
+	if bucket == "" {
+		bucket = "day"
+	}
+	bucketSize, found := bucketSizes[bucket]
+	if !found {
+		return from, to, bucketSize, errors.New("unknown bucket")
+	}
+	return from, to, bucketSize, nil
+}
+
+func (h *Handler) getAvailability(ctx context.Context, from, to time.Time, bucketSize time.Duration) (Availability, error) {
+	runs, err := h.runLedgerRepository.ListRuns(ctx, from, to)
+	if err != nil {
+		return Availability{}, err
+	}
+
+	availability := Availability{
+		NumRuns: len(runs),
+		Buckets: scrapper.SummarizeAvailability(runs, bucketSize),
+	}
+	for _, run := range runs {
+		if run.SiteAvailable() {
+			availability.NumAvailable++
+		}
+	}
+	if len(runs) > 0 {
+		availability.Availability = float64(availability.NumAvailable) / float64(len(runs))
+		availability.LastRun = &runs[len(runs)-1]
+	}
+	return availability, nil
+}
+
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	from, to, bucketSize, err := parseQuery(request.QueryStringParameters)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusBadRequest, "Invalid from, to or bucket parameter"), nil
+	}
+
+	availability, err := h.getAvailability(ctx, from, to, bucketSize)
+	if err != nil {
+		slog.Error("Failed to list etl runs", "error_msg", err.Error())
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	return httpapi.JSON(headers, ApiResponseData{Data: availability}), nil
+}
@@ -0,0 +1,97 @@
+// Package counts serves the daily counts of stations per status.
+package counts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the daily station counts.
+type Handler struct {
+	countsRepository storage.DayCountsRepository
+	allowOrigin      string
+}
+
+type ApiResponseData struct {
+	Data []scrapper.StationStatesCount `json:"data"`
+}
+
+// Sort counts from most recent to oldest
+func sortCountsByDate(counts []scrapper.StationStatesCount) {
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Time > counts[j].Time
+	})
+}
+
+// Get the counts taken between from and to from the counts repository
+func (h *Handler) getCounts(ctx context.Context, from, to time.Time) ([]scrapper.StationStatesCount, error) {
+	counts, err := h.countsRepository.ListCounts(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort counts before returning
+	sortCountsByDate(counts)
+	return counts, nil
+}
+
+// parseRange reads the from and to unix timestamps of the query, the range
+// defaults to the last year
+func parseRange(params map[string]string) (from, to time.Time, err error) {
+	to = time.Now()
+	if toStr := params["to"]; toStr != "" {
+		toUnix, err := strconv.ParseInt(toStr, 10, 64)
+		if err != nil {
+			return from, to, err
+		}
+		to = time.Unix(toUnix, 0)
+	}
+
+	from = to.AddDate(-1, 0, 0)
+	if fromStr := params["from"]; fromStr != "" {
+		fromUnix, err := strconv.ParseInt(fromStr, 10, 64)
+		if err != nil {
+			return from, to, err
+		}
+		from = time.Unix(fromUnix, 0)
+	}
+
+	if from.After(to) {
+		return from, to, errors.New("from is after to")
+	}
+	return from, to, nil
+}
+
+// Update handler to return counts
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	from, to, err := parseRange(request.QueryStringParameters)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusBadRequest, "Invalid from or to parameter"), nil
+	}
+
+	counts, err := h.getCounts(ctx, from, to)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	respData := ApiResponseData{
+		Data: counts,
+	}
+
+	return httpapi.JSON(headers, respData), nil
+}
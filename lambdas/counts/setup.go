@@ -0,0 +1,36 @@
+package counts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// RequiredVariables are the tables the handler reads.
+var RequiredVariables = []string{"DYNAMODB_TABLE_DAY_COUNTS"}
+
+// New sets up the handler of the daily station counts.
+func New(ctx context.Context, cfg config.API) (*Handler, error) {
+	h := &Handler{allowOrigin: cfg.AllowOrigin}
+
+	// a storage directory serves the API from local files instead of DynamoDB
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file store: %w", err)
+		}
+		h.countsRepository = fileStore
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		h.countsRepository = storage.NewDynamoDayCountsRepository(dynamodb.NewFromConfig(awsCfg), cfg.DayCountsTable)
+	}
+
+	return h, nil
+}
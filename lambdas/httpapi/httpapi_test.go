@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCheckMethod(t *testing.T) {
+	headers := Headers("https://example.org", ContentTypeJSON)
+
+	tests := []struct {
+		method     string
+		wantOk     bool
+		wantStatus int
+		wantBody   string
+	}{
+		{method: "GET", wantOk: true},
+		{method: "OPTIONS", wantStatus: 200, wantBody: ""},
+		{method: "POST", wantStatus: 400, wantBody: `{"message":"Invalid request method"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			response, ok := CheckMethod(events.APIGatewayProxyRequest{HTTPMethod: tt.method}, headers)
+			if ok != tt.wantOk {
+				t.Fatalf("CheckMethod() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok {
+				return
+			}
+			if response.StatusCode != tt.wantStatus || response.Body != tt.wantBody {
+				t.Errorf("CheckMethod() = %d %q, want %d %q", response.StatusCode, response.Body, tt.wantStatus, tt.wantBody)
+			}
+			if response.Headers["Access-Control-Allow-Origin"] != "https://example.org" {
+				t.Errorf("CheckMethod() headers = %v, want the allowed origin", response.Headers)
+			}
+		})
+	}
+}
+
+func TestResponses(t *testing.T) {
+	headers := Headers("*", ContentTypeGeoJSON)
+
+	tests := []struct {
+		name       string
+		response   events.APIGatewayProxyResponse
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "error message is escaped",
+			response:   Error(headers, http.StatusNotFound, `No "station" found`),
+			wantStatus: 404,
+			wantBody:   `{"message":"No \"station\" found"}`,
+		},
+		{
+			name:       "json body",
+			response:   JSON(headers, map[string][]int{"data": {1, 2}}),
+			wantStatus: 200,
+			wantBody:   `{"data":[1,2]}`,
+		},
+		{
+			name:       "unmarshalable body",
+			response:   JSON(headers, math.Inf(1)),
+			wantStatus: 500,
+			wantBody:   `{"message":"Error marshaling response"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.response.StatusCode != tt.wantStatus || tt.response.Body != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", tt.response.StatusCode, tt.response.Body, tt.wantStatus, tt.wantBody)
+			}
+			if tt.response.Headers["Content-Type"] != ContentTypeGeoJSON {
+				t.Errorf("Content-Type = %q, want %q", tt.response.Headers["Content-Type"], ContentTypeGeoJSON)
+			}
+		})
+	}
+}
+
+func TestRequestHeader(t *testing.T) {
+	request := events.APIGatewayProxyRequest{Headers: map[string]string{"if-none-match": `"v1"`}}
+	if got := RequestHeader(request, "If-None-Match"); got != `"v1"` {
+		t.Errorf("RequestHeader() = %q, want %q", got, `"v1"`)
+	}
+	if got := RequestHeader(request, "Origin"); got != "" {
+		t.Errorf("RequestHeader() of a missing header = %q, want empty", got)
+	}
+}
+
+func TestNewHTTPHandler(t *testing.T) {
+	var received events.APIGatewayProxyRequest
+	handler := NewHTTPHandler(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		received = request
+		if request.QueryStringParameters["fail"] != "" {
+			return events.APIGatewayProxyResponse{}, errors.New("invocation failed")
+		}
+		return JSON(Headers("*", ContentTypeJSON), map[string]string{"data": request.QueryStringParameters["geoId"]}), nil
+	})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/incidents?geoId=42&geoId=43", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	if recorder.Code != 200 || string(body) != `{"data":"42"}` {
+		t.Errorf("response = %d %q, want 200 %q", recorder.Code, body, `{"data":"42"}`)
+	}
+	if recorder.Header().Get("Content-Type") != ContentTypeJSON {
+		t.Errorf("Content-Type = %q, want %q", recorder.Header().Get("Content-Type"), ContentTypeJSON)
+	}
+	if received.HTTPMethod != "GET" || received.Path != "/incidents" || len(received.MultiValueQueryStringParameters["geoId"]) != 2 {
+		t.Errorf("request = %+v, want a GET of /incidents with both geoIds", received)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/incidents?fail=1", nil))
+	if recorder.Code != http.StatusBadGateway {
+		t.Errorf("failed invocation status = %d, want %d", recorder.Code, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,83 @@
+// Package httpapi holds what the API handlers share: the CORS headers, the
+// answer to the other methods than GET and the JSON bodies of the responses.
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Content types of the API responses.
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeGeoJSON = "application/geo+json"
+)
+
+// Headers returns the headers of every response of an endpoint.
+func Headers(allowOrigin, contentType string) map[string]string {
+	return map[string]string{
+		"Access-Control-Allow-Origin":  allowOrigin,
+		"Access-Control-Allow-Methods": "GET,OPTIONS",
+		"Content-Type":                 contentType,
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+	}
+}
+
+// CheckMethod answers the preflight requests and refuses the other methods
+// than GET, ok is true when the request is a GET to serve.
+func CheckMethod(request events.APIGatewayProxyRequest, headers map[string]string) (response events.APIGatewayProxyResponse, ok bool) {
+	switch request.HTTPMethod {
+	case http.MethodGet:
+		return events.APIGatewayProxyResponse{}, true
+	case http.MethodOptions:
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers:    headers,
+			Body:       "",
+		}, false
+	default:
+		return Error(headers, http.StatusBadRequest, "Invalid request method"), false
+	}
+}
+
+// RequestHeader returns the value of a request header, API Gateway passes the
+// header names the way the client wrote them.
+func RequestHeader(request events.APIGatewayProxyRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// Error returns a response with a message body.
+func Error(headers map[string]string, statusCode int, message string) events.APIGatewayProxyResponse {
+	body, err := json.Marshal(struct {
+		Message string `json:"message"`
+	}{message})
+	if err != nil {
+		body = []byte(`{"message": "Internal server error"}`)
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    headers,
+		Body:       string(body),
+	}
+}
+
+// JSON returns a successful response with the JSON encoding of body.
+func JSON(headers map[string]string, body any) events.APIGatewayProxyResponse {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return Error(headers, http.StatusInternalServerError, "Error marshaling response")
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       string(jsonData),
+	}
+}
@@ -0,0 +1,60 @@
+package httpapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler is the handler of an API Gateway proxy request.
+type Handler = func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// NewHTTPHandler serves an API handler over plain HTTP, the way API Gateway
+// would call it, to run the API locally.
+func NewHTTPHandler(handler Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		request := events.APIGatewayProxyRequest{
+			HTTPMethod:                      r.Method,
+			Path:                            r.URL.Path,
+			Headers:                         make(map[string]string, len(r.Header)),
+			MultiValueHeaders:               r.Header,
+			QueryStringParameters:           make(map[string]string),
+			MultiValueQueryStringParameters: r.URL.Query(),
+			Body:                            string(body),
+		}
+		for name := range r.Header {
+			request.Headers[name] = r.Header.Get(name)
+		}
+		for name, values := range request.MultiValueQueryStringParameters {
+			request.QueryStringParameters[name] = values[0]
+		}
+
+		response, err := handler(r.Context(), request)
+		if err != nil {
+			// API Gateway answers a failed invocation with a bad gateway
+			slog.Error("API handler failed", "path", r.URL.Path, "error_msg", err.Error())
+			http.Error(w, `{"message": "Internal server error"}`, http.StatusBadGateway)
+			return
+		}
+
+		for name, value := range response.Headers {
+			w.Header().Set(name, value)
+		}
+		for name, values := range response.MultiValueHeaders {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+		w.WriteHeader(response.StatusCode)
+		io.WriteString(w, response.Body)
+	})
+}
@@ -0,0 +1,55 @@
+// Package incidents serves the open incidents and the incidents of a station.
+package incidents
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the open and station incidents.
+type Handler struct {
+	incidentRepository storage.IncidentRepository
+	allowOrigin        string
+}
+
+// maxStationIncidents caps the incidents returned for a station
+const maxStationIncidents = 200
+
+type ApiResponseData struct {
+	Data []scrapper.Incident `json:"data"`
+}
+
+// Handle returns the incidents of a station, most recent first, or every open
+// incident, oldest first, when no geoId is given.
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	var incidents []scrapper.Incident
+	var err error
+	if geoIdStr := request.QueryStringParameters["geoId"]; geoIdStr != "" {
+		geoId, parseErr := strconv.ParseInt(geoIdStr, 10, 64)
+		if parseErr != nil {
+			return httpapi.Error(headers, http.StatusBadRequest, "Invalid geoId parameter"), nil
+		}
+		incidents, err = h.incidentRepository.ListStationIncidents(ctx, geoId, maxStationIncidents)
+	} else {
+		incidents, err = h.incidentRepository.ListOpenIncidents(ctx)
+	}
+	if err != nil {
+		slog.Error("Failed to list incidents", "error_msg", err.Error())
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	return httpapi.JSON(headers, ApiResponseData{Data: incidents}), nil
+}
@@ -0,0 +1,84 @@
+// Package scrape is the ETL function: every invocation scrapes the map and
+// persists what changed.
+package scrape
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/etl"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler runs the pipeline on every scheduled or manual invocation.
+type Handler struct {
+	pipeline *etl.Pipeline
+	// a dry run environment never writes, whatever the invocation payload
+	dryRun      bool
+	forceAccept bool
+}
+
+// Event is the scheduled event, manual invocations can set dryRun to get
+// a report of what the run would write without writing it, and forceAccept to
+// write a snapshot the plausibility rules would quarantine.
+type Event struct {
+	events.CloudWatchEvent
+	DryRun      bool `json:"dryRun"`
+	ForceAccept bool `json:"forceAccept"`
+}
+
+// Handle runs the pipeline, or reports what it would write on a dry run.
+func (h *Handler) Handle(ctx context.Context, ev Event) (*etl.Report, error) {
+	ctx, span := tracing.StartInvocation(tracing.ContextFromEventID(ctx, ev.ID), "etl.invoke")
+	defer tracing.Flush(ctx)
+	defer span.End()
+
+	slog.Info("Lambda handling event",
+		"id", ev.ID,
+		"source", ev.Source,
+		"account", ev.AccountID,
+		"region", ev.Region,
+		"version", ev.Version,
+		"time", ev.Time,
+		"detail_type", ev.DetailType,
+		"detail", ev.Detail,
+		"resources", ev.Resources,
+		"dry_run", ev.DryRun || h.dryRun,
+		"force_accept", ev.ForceAccept || h.forceAccept,
+	)
+
+	// invocations of a lambda instance are sequential, so the pipeline can be updated
+	h.pipeline.ForceAccept = ev.ForceAccept || h.forceAccept
+
+	if ev.DryRun || h.dryRun {
+		report, err := h.pipeline.DryRun(ctx)
+		if err != nil {
+			slog.Error("ETL dry run failed", "error_msg", err.Error())
+			span.RecordError(err)
+			return nil, err
+		}
+		return &report, nil
+	}
+
+	// the event time keys the run by its schedule slot, EventBridge can
+	// deliver an event twice and a failed invocation is retried
+	result, err := h.pipeline.RunScheduled(ctx, ev.Time)
+	// a slot held by an attempt that may have died is retried once its lease expired
+	if result.Duplicate {
+		return nil, err
+	}
+	// a quarantined snapshot is alerted on from the logs, failing the invocation
+	// would only have it retried against the same page
+	if errors.Is(err, etl.ErrImplausibleSnapshot) {
+		slog.Warn("ETL run ended with a quarantined snapshot", "runId", result.RunId, "error_msg", err.Error())
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("ETL run failed", "runId", result.RunId, "error_class", etl.ErrorClass(err), "error_msg", err.Error())
+		span.RecordError(err)
+		return nil, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,137 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/cloudevents"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/etl"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/metrics"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// New sets up the function running the pipeline of the configuration, its
+// metrics are written to emitter.
+func New(ctx context.Context, cfg config.ETL, emitter *metrics.Emitter) (*Handler, error) {
+	pipeline, err := NewPipeline(ctx, cfg, emitter)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{pipeline: pipeline, dryRun: cfg.DryRun, forceAccept: cfg.ForceAccept}, nil
+}
+
+// NewPipeline wires the pipeline of the configuration, the function and the
+// local collector run the same one.
+func NewPipeline(ctx context.Context, cfg config.ETL, emitter *metrics.Emitter) (*etl.Pipeline, error) {
+	pipeline := &etl.Pipeline{
+		ChangeOnly:          cfg.ChangeOnly,
+		Heartbeat:           cfg.Heartbeat,
+		InactiveGracePeriod: cfg.InactiveGracePeriod,
+		StatusRetention:     cfg.StatusRetention,
+		SlotInterval:        cfg.ScheduleInterval,
+		Rules:               cfg.Rules,
+		Metrics:             emitter,
+	}
+
+	var err error
+	pipeline.Scrapper, err = scrapper.NewTermoficareScrapper("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TermoficareScrapper: %w", err)
+	}
+	// a storage directory runs the function against local files instead of DynamoDB
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file store: %w", err)
+		}
+		pipeline.Counts = fileStore
+		pipeline.Stations = fileStore
+		pipeline.Statuses = fileStore
+		pipeline.Runs = fileStore
+		pipeline.Incidents = fileStore
+		pipeline.Quarantine = fileStore
+		pipeline.Outbox = fileStore
+		pipeline.Slots = fileStore
+		pipeline.CurrentState = fileStore
+
+		pipeline.Weights, err = storage.LoadFileStationWeights(cfg.StorageDir, cfg.StationWeightsKey, cfg.DefaultStationWeight)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		dbClient := dynamodb.NewFromConfig(awsCfg)
+
+		pipeline.Counts = storage.NewDynamoDayCountsRepository(dbClient, cfg.DayCountsTable)
+		pipeline.Stations = storage.NewDynamoStationRepository(dbClient, cfg.StationsTable)
+		pipeline.Statuses = storage.NewDynamoStatusHistoryRepository(dbClient, cfg.StatusesTable)
+		pipeline.Runs = storage.NewDynamoRunLedgerRepository(dbClient, cfg.EtlRunsTable)
+		pipeline.Incidents = storage.NewDynamoIncidentRepository(dbClient, cfg.IncidentsTable)
+		s3Client := s3.NewFromConfig(awsCfg)
+		// snapshots refused by the plausibility rules are kept with their page in the bucket
+		pipeline.Quarantine = storage.NewS3SnapshotQuarantine(s3Client, cfg.Bucket)
+		// so is the current state document the stations API serves
+		pipeline.CurrentState = storage.NewS3CurrentStateStore(s3Client, cfg.Bucket)
+		// and the station weights table when one is configured
+		pipeline.Weights, err = storage.LoadS3StationWeights(ctx, s3Client, cfg.Bucket, cfg.StationWeightsKey, cfg.DefaultStationWeight)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.RunSlotsTable != "" {
+			pipeline.Slots = storage.NewDynamoRunSlotRepository(dbClient, cfg.RunSlotsTable)
+		}
+		if cfg.EventOutboxTable != "" {
+			pipeline.Outbox = storage.NewDynamoOutboxRepository(dbClient, cfg.EventOutboxTable)
+		}
+	}
+
+	pipeline.Publisher, err = newPublisher(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s events publisher: %w", cfg.EventsSink, err)
+	}
+
+	return pipeline, nil
+}
+
+// newPublisher returns the publisher of the events sink, nil when the events
+// are not published.
+func newPublisher(ctx context.Context, cfg config.ETL) (cloudevents.Publisher, error) {
+	const httpTimeout = 10 * time.Second
+
+	switch cfg.EventsSink {
+	case cloudevents.SinkStdout:
+		return cloudevents.NewWriterPublisher(os.Stdout), nil
+	case cloudevents.SinkFile:
+		file, err := os.OpenFile(cfg.EventsTarget, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return cloudevents.NewWriterPublisher(file), nil
+	case cloudevents.SinkHTTP:
+		return cloudevents.NewHTTPPublisher(cfg.EventsTarget, &http.Client{Timeout: httpTimeout}), nil
+	case cloudevents.SinkSNS, cloudevents.SinkEventBridge:
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		if cfg.EventsSink == cloudevents.SinkSNS {
+			return cloudevents.NewSNSPublisher(sns.NewFromConfig(awsCfg), cfg.EventsTarget), nil
+		}
+		return cloudevents.NewEventBridgePublisher(eventbridge.NewFromConfig(awsCfg), cfg.EventsTarget), nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,118 @@
+// Package serviceareas serves the service areas of the stations as GeoJSON.
+package serviceareas
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/geometry"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the station service areas.
+type Handler struct {
+	stationRepository storage.StationRepository
+	allowOrigin       string
+}
+
+// the tessellation is kept between warm invocations and only recomputed
+// when a station appears or disappears
+var (
+	cellsMutex       sync.Mutex
+	cellsFingerprint string
+	cachedCells      []geometry.Cell
+)
+
+// Get the active stations from the stations repository, a station gone from
+// the map no longer serves any area
+func (h *Handler) getStations(ctx context.Context) ([]scrapper.HeatingStation, error) {
+	stations, err := h.stationRepository.ListStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return scrapper.ActiveStations(stations), nil
+}
+
+func getServiceAreaCells(stations []scrapper.HeatingStation) []geometry.Cell {
+	sites := make([]geometry.Site, len(stations))
+	for i, station := range stations {
+		sites[i] = geometry.Site{
+			GeoId: station.GeoId,
+			Point: geometry.Point{
+				Longitude: station.Longitude,
+				Latitude:  station.Latitude,
+			},
+		}
+	}
+
+	fingerprint := geometry.SitesFingerprint(sites)
+
+	cellsMutex.Lock()
+	defer cellsMutex.Unlock()
+
+	if fingerprint != cellsFingerprint {
+		cachedCells = geometry.VoronoiCells(sites, geometry.BucharestBoundary)
+		cellsFingerprint = fingerprint
+		slog.Info("Service areas recomputed",
+			"numStations", len(sites),
+			"numCells", len(cachedCells),
+			"fingerprint", fingerprint,
+		)
+		if len(cachedCells) != len(sites) {
+			slog.Warn("Some stations are outside of the city boundary and have no service area",
+				"numStationsOutside", len(sites)-len(cachedCells),
+			)
+		}
+	}
+
+	return cachedCells
+}
+
+func buildServiceAreas(stations []scrapper.HeatingStation) geometry.FeatureCollection {
+	stationsById := make(map[int64]scrapper.HeatingStation, len(stations))
+	for _, station := range stations {
+		stationsById[station.GeoId] = station
+	}
+
+	cells := getServiceAreaCells(stations)
+
+	features := make([]geometry.Feature, 0, len(cells))
+	for _, cell := range cells {
+		station := stationsById[cell.GeoId]
+		features = append(features, geometry.Feature{
+			Type:     "Feature",
+			Geometry: geometry.NewPolygonGeometry(cell.Polygon),
+			Properties: map[string]any{
+				"geoId":      fmt.Sprintf("%d", station.GeoId),
+				"name":       station.Name,
+				"latitude":   station.Latitude,
+				"longitude":  station.Longitude,
+				"lastStatus": station.LastStatus,
+			},
+		})
+	}
+
+	return geometry.NewFeatureCollection(features)
+}
+
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeGeoJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	stations, err := h.getStations(ctx)
+	if err != nil {
+		slog.Error("Unable to get stations", "error_msg", err.Error())
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	return httpapi.JSON(headers, buildServiceAreas(stations)), nil
+}
@@ -0,0 +1,77 @@
+// Package stationdetails serves the status history and the daily rollups of a
+// station.
+package stationdetails
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the status history and daily rollups of a station.
+type Handler struct {
+	statusRepository  storage.StatusHistoryRepository
+	rollupRepository  storage.StationRollupRepository
+	bucharestLocation *time.Location
+	allowOrigin       string
+}
+
+// rollupHistoryDays is how far back the daily rollups go, they outlive the expired status rows
+const rollupHistoryDays = 365
+
+type ApiResponseData struct {
+	Data         []scrapper.HeatingStationStatus `json:"data"`
+	DailyRollups []scrapper.StationDayRollup     `json:"dailyRollups"`
+}
+
+// Get the most recent station statuses by geoId
+func (h *Handler) getStationStatuses(ctx context.Context, geoId int64) ([]scrapper.HeatingStationStatus, error) {
+	return h.statusRepository.ListStationStatuses(ctx, geoId, 5000)
+}
+
+// Update handler to return counts
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	// Get geoId from query parameters
+	geoIdStr := request.QueryStringParameters["geoId"]
+	if geoIdStr == "" {
+		return httpapi.Error(headers, http.StatusBadRequest, "Missing geoId query parameter"), nil
+	}
+
+	geoId, err := strconv.ParseInt(geoIdStr, 10, 64)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusBadRequest, "Invalid geoId parameter"), nil
+	}
+
+	statuses, err := h.getStationStatuses(ctx, geoId)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	today := time.Now().In(h.bucharestLocation)
+	rollups, err := h.rollupRepository.ListStationRollups(ctx, geoId,
+		today.AddDate(0, 0, -rollupHistoryDays).Format(scrapper.RollupDateLayout),
+		today.Format(scrapper.RollupDateLayout),
+	)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	respData := ApiResponseData{
+		Data:         statuses,
+		DailyRollups: rollups,
+	}
+
+	return httpapi.JSON(headers, respData), nil
+}
@@ -0,0 +1,48 @@
+package stationdetails
+
+import (
+	"context"
+	"fmt"
+	"time"
+	// the lambda image has no time zone database
+	_ "time/tzdata"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// RequiredVariables are the tables the handler reads.
+var RequiredVariables = []string{"DYNAMODB_TABLE_STATUS_HISTORY", "DYNAMODB_TABLE_DAY_ROLLUPS"}
+
+// New sets up the handler of the station details.
+func New(ctx context.Context, cfg config.API) (*Handler, error) {
+	h := &Handler{allowOrigin: cfg.AllowOrigin}
+
+	// a storage directory serves the API from local files instead of DynamoDB
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file store: %w", err)
+		}
+		h.statusRepository = fileStore
+		h.rollupRepository = fileStore
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		dbClient := dynamodb.NewFromConfig(awsCfg)
+		h.statusRepository = storage.NewDynamoStatusHistoryRepository(dbClient, cfg.StatusHistoryTable)
+		h.rollupRepository = storage.NewDynamoStationRollupRepository(dbClient, cfg.DayRollupsTable)
+	}
+
+	var err error
+	h.bucharestLocation, err = time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the Europe/Bucharest time zone: %w", err)
+	}
+
+	return h, nil
+}
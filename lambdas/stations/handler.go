@@ -0,0 +1,213 @@
+// Package stations serves the heating stations and their last status.
+package stations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the stations, from the current state document the ETL
+// publishes when there is one, else from the stations table.
+type Handler struct {
+	stationRepository storage.StationRepository
+	// currentState is nil when the handler only reads the table
+	currentState storage.CurrentStateStore
+	allowOrigin  string
+
+	// cachedState is the last document read, documents never change so the
+	// pointer is the only read while its version stays the same
+	cacheMutex  sync.Mutex
+	cachedState scrapper.CurrentState
+	// expiredVersion is the version of a pointer whose document expired, such
+	// as after the ETL stopped for longer than the retention. The table is
+	// served without reading the document again until the pointer moves.
+	expiredVersion string
+}
+
+type HeatingStationAPI struct {
+	GeoId            string  `json:"geoId"`
+	Name             string  `json:"name"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	LastStatus       string  `json:"lastStatus"`
+	IncidentType     string  `json:"incidentType"`
+	IncidentText     string  `json:"incidentText"`
+	EstimatedFixDate int64   `json:"estimatedFixDate"`
+	FirstSeen        int64   `json:"firstSeen"`
+	LastSeen         int64   `json:"lastSeen"`
+	LastStatusChange int64   `json:"lastStatusChange"`
+	Active           bool    `json:"active"`
+}
+
+type ApiResponseData struct {
+	Data []HeatingStationAPI `json:"data"`
+	// Version and SnapshotTime are those of the current state document served, empty when served from the table
+	Version      string `json:"version,omitempty"`
+	SnapshotTime int64  `json:"snapshotTime,omitempty"`
+}
+
+// Sort stations by name
+func sortStationsByName(stations []HeatingStationAPI) {
+	sort.SliceStable(stations, func(i, j int) bool {
+		return stations[i].Name < stations[j].Name
+	})
+}
+
+// Get stations from the stations repository, the ones gone from the map are
+// only returned when includeInactive is set
+func (h *Handler) getStations(ctx context.Context, includeInactive bool) ([]HeatingStationAPI, error) {
+	stations, err := h.stationRepository.ListStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toAPIStations(stations, includeInactive), nil
+}
+
+// getCurrentState returns the document of version, or of the version the
+// pointer names when version is empty. found is false when no document was
+// published yet, or when the one of the pointer expired.
+func (h *Handler) getCurrentState(ctx context.Context, version string) (state scrapper.CurrentState, found bool, err error) {
+	pointed := version == ""
+	if pointed {
+		pointer, found, err := h.currentState.CurrentStatePointer(ctx)
+		if err != nil || !found {
+			return scrapper.CurrentState{}, false, err
+		}
+		version = pointer.Version
+	}
+
+	h.cacheMutex.Lock()
+	cached := h.cachedState
+	expired := pointed && h.expiredVersion == version
+	h.cacheMutex.Unlock()
+	if cached.Version == version {
+		return cached, true, nil
+	}
+	if expired {
+		return scrapper.CurrentState{}, false, nil
+	}
+
+	state, err = h.currentState.GetCurrentState(ctx, version)
+	if pointed && errors.Is(err, storage.ErrCurrentStateNotFound) {
+		slog.Warn("Current state document of the pointer expired, serving the stations table", "version", version)
+		h.cacheMutex.Lock()
+		h.expiredVersion = version
+		h.cacheMutex.Unlock()
+		return scrapper.CurrentState{}, false, nil
+	}
+	if err != nil {
+		return scrapper.CurrentState{}, false, err
+	}
+	h.cacheMutex.Lock()
+	if state.SnapshotTime >= h.cachedState.SnapshotTime {
+		h.cachedState = state
+	}
+	h.cacheMutex.Unlock()
+	return state, true, nil
+}
+
+// Convert to API format with string geoId
+func toAPIStations(stations []scrapper.HeatingStation, includeInactive bool) []HeatingStationAPI {
+	if !includeInactive {
+		stations = scrapper.ActiveStations(stations)
+	}
+
+	apiStations := make([]HeatingStationAPI, len(stations))
+	for i, station := range stations {
+		apiStations[i] = HeatingStationAPI{
+			GeoId:            fmt.Sprintf("%d", station.GeoId),
+			Name:             station.Name,
+			Latitude:         station.Latitude,
+			Longitude:        station.Longitude,
+			LastStatus:       station.LastStatus,
+			IncidentType:     station.LastIncidentType,
+			IncidentText:     station.LastIncidentText,
+			EstimatedFixDate: station.LastEstimatedFixDate,
+			FirstSeen:        station.FirstSeen,
+			LastSeen:         station.LastSeen,
+			LastStatusChange: station.LastStatusChange,
+			Active:           station.Active,
+		}
+	}
+
+	// Sort stations before returning
+	sortStationsByName(apiStations)
+	return apiStations
+}
+
+// Handle serves the stations. The current state document is served with its
+// version as ETag, and a version asked with the version parameter never
+// changes so it can be cached until the document expires.
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	includeInactive := request.QueryStringParameters["includeInactive"] == "true"
+	version := request.QueryStringParameters["version"]
+
+	if h.currentState != nil {
+		state, found, err := h.getCurrentState(ctx, version)
+		switch {
+		case errors.Is(err, storage.ErrCurrentStateNotFound) && version != "":
+			return httpapi.Error(headers, http.StatusNotFound, "Unknown version"), nil
+		case err != nil:
+			// the table still has every station
+			slog.Warn("Failed to read the current state, serving the stations table", "version", version, "error_msg", err.Error())
+		case found:
+			return h.currentStateResponse(request, headers, state, includeInactive, version != ""), nil
+		}
+	}
+	if version != "" {
+		return httpapi.Error(headers, http.StatusNotFound, "Unknown version"), nil
+	}
+
+	stations, err := h.getStations(ctx, includeInactive)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	respData := ApiResponseData{
+		Data: stations,
+	}
+
+	return httpapi.JSON(headers, respData), nil
+}
+
+// currentStateResponse answers with the stations of the document, or with a
+// not modified response when the client has its version already.
+func (h *Handler) currentStateResponse(request events.APIGatewayProxyRequest, headers map[string]string, state scrapper.CurrentState, includeInactive, pinned bool) events.APIGatewayProxyResponse {
+	// the inactive stations make another representation of the same version
+	etag := fmt.Sprintf("%q", state.Version)
+	if includeInactive {
+		etag = fmt.Sprintf("%q", state.Version+"-all")
+	}
+	headers["ETag"] = etag
+	headers["Cache-Control"] = "no-cache"
+	// a pinned version never changes, but is only kept for the retention of the documents
+	if remaining := time.Until(time.Unix(state.SnapshotTime, 0).Add(storage.CurrentStateRetention)); pinned && remaining > 0 {
+		headers["Cache-Control"] = fmt.Sprintf("public, max-age=%d, immutable", int64(remaining/time.Second))
+	}
+
+	if httpapi.RequestHeader(request, "If-None-Match") == etag {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotModified, Headers: headers}
+	}
+	return httpapi.JSON(headers, ApiResponseData{
+		Data:         toAPIStations(state.Stations, includeInactive),
+		Version:      state.Version,
+		SnapshotTime: state.SnapshotTime,
+	})
+}
@@ -0,0 +1,42 @@
+package stations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// RequiredVariables are the tables the handler reads. The current state
+// documents are read from S3_BUCKET when it is set.
+var RequiredVariables = []string{"DYNAMODB_TABLE_STATIONS"}
+
+// New sets up the handler of the stations.
+func New(ctx context.Context, cfg config.API) (*Handler, error) {
+	h := &Handler{allowOrigin: cfg.AllowOrigin}
+
+	// a storage directory serves the API from local files instead of DynamoDB
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file store: %w", err)
+		}
+		h.stationRepository = fileStore
+		h.currentState = fileStore
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		h.stationRepository = storage.NewDynamoStationRepository(dynamodb.NewFromConfig(awsCfg), cfg.StationsTable)
+		if cfg.Bucket != "" {
+			h.currentState = storage.NewS3CurrentStateStore(s3.NewFromConfig(awsCfg), cfg.Bucket)
+		}
+	}
+
+	return h, nil
+}
@@ -0,0 +1,109 @@
+// Package stationsstats serves the incident statistics of the stations and the
+// reliability of the fix dates.
+package stationsstats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/lambdas/httpapi"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler serves the station incident statistics.
+type Handler struct {
+	incidentStatsRepository storage.IncidentStatsRepository
+	reliabilityRepository   storage.FixDateReliabilityRepository
+	allowOrigin             string
+}
+
+type StationIncidentStatsAPI struct {
+	City                        string  `json:"city"`
+	Rank                        int     `json:"rank"`
+	GeoId                       string  `json:"geoId"`
+	LastName                    string  `json:"lastName"`
+	Latitude                    float64 `json:"latitude"`
+	Longitude                   float64 `json:"longitude"`
+	AvgMonthlyIncidentTimeHours float32 `json:"avgMonthlyIncidentTimeHours"`
+	AvgIncidentTimeHours        float32 `json:"avgIncidentTimeHours"`
+	MaxIncidentTimeHours        float32 `json:"maxIncidentTimeHours"`
+	Residents                   float64 `json:"residents"`
+	AvgMonthlyResidentHours     float64 `json:"avgMonthlyResidentHours"`
+	WeightIsDefault             bool    `json:"weightIsDefault"`
+	NumPromisedIncidents        int     `json:"numPromisedIncidents"`
+	NumPostponements            int     `json:"numPostponements"`
+	TotalSlippageHours          float64 `json:"totalSlippageHours"`
+	BeatFirstPromiseRate        float64 `json:"beatFirstPromiseRate"`
+}
+
+type ApiResponseData struct {
+	Data []StationIncidentStatsAPI `json:"data"`
+	// FixDateReliability has the city-wide row first, then a row per cause
+	FixDateReliability []scrapper.FixDateReliabilityDbRow `json:"fixDateReliability"`
+}
+
+func (h *Handler) getStationsStats(ctx context.Context) ([]StationIncidentStatsAPI, error) {
+
+	allStats, err := h.incidentStatsRepository.ListIncidentStats(ctx, "Bucharest")
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to API format with string geoId
+	apiStats := make([]StationIncidentStatsAPI, len(allStats))
+	for i, stat := range allStats {
+		apiStats[i] = StationIncidentStatsAPI{
+			City:                        stat.City,
+			Rank:                        stat.Rank,
+			GeoId:                       fmt.Sprintf("%d", stat.GeoId),
+			LastName:                    stat.LastName,
+			Latitude:                    stat.Latitude,
+			Longitude:                   stat.Longitude,
+			AvgMonthlyIncidentTimeHours: stat.AvgMonthlyIncidentTimeHours,
+			AvgIncidentTimeHours:        stat.AvgIncidentTimeHours,
+			MaxIncidentTimeHours:        stat.MaxIncidentTimeHours,
+			Residents:                   stat.Residents,
+			AvgMonthlyResidentHours:     stat.AvgMonthlyResidentHours,
+			WeightIsDefault:             stat.WeightIsDefault,
+			NumPromisedIncidents:        stat.NumPromisedIncidents,
+			NumPostponements:            stat.NumPostponements,
+			TotalSlippageHours:          stat.TotalSlippageHours,
+			BeatFirstPromiseRate:        stat.BeatFirstPromiseRate,
+		}
+	}
+
+	return apiStats, nil
+}
+
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := httpapi.Headers(h.allowOrigin, httpapi.ContentTypeJSON)
+
+	if response, ok := httpapi.CheckMethod(request, headers); !ok {
+		return response, nil
+	}
+
+	stats, err := h.getStationsStats(ctx)
+	if err != nil {
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+
+	reliability, err := h.reliabilityRepository.ListFixDateReliability(ctx, "Bucharest")
+	if err != nil {
+		return httpapi.Error(headers, http.StatusInternalServerError, "Internal server error"), nil
+	}
+	// the table is sorted by scope, the city-wide row goes first
+	sort.SliceStable(reliability, func(i, j int) bool {
+		return reliability[i].Scope == scrapper.ReliabilityScopeCity && reliability[j].Scope != scrapper.ReliabilityScopeCity
+	})
+
+	respData := ApiResponseData{
+		Data:               stats,
+		FixDateReliability: reliability,
+	}
+
+	return httpapi.JSON(headers, respData), nil
+}
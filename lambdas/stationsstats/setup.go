@@ -0,0 +1,39 @@
+package stationsstats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/config"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// RequiredVariables are the tables the handler reads.
+var RequiredVariables = []string{"DYNAMODB_TABLE_STATIONS_STATS", "DYNAMODB_TABLE_FIX_DATE_RELIABILITY"}
+
+// New sets up the handler of the station statistics.
+func New(ctx context.Context, cfg config.API) (*Handler, error) {
+	h := &Handler{allowOrigin: cfg.AllowOrigin}
+
+	// a storage directory serves the API from local files instead of DynamoDB
+	if cfg.StorageDir != "" {
+		fileStore, err := storage.OpenFileStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file store: %w", err)
+		}
+		h.incidentStatsRepository = fileStore
+		h.reliabilityRepository = fileStore
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		dbClient := dynamodb.NewFromConfig(awsCfg)
+		h.incidentStatsRepository = storage.NewDynamoIncidentStatsRepository(dbClient, cfg.StationsStatsTable)
+		h.reliabilityRepository = storage.NewDynamoFixDateReliabilityRepository(dbClient, cfg.FixDateReliabilityTable)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// APIHandler is the handler of an API Gateway proxy lambda.
+type APIHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// WrapAPIHandler records the latency and the status of every request of an
+// endpoint, in the Latency, Requests, ClientErrors and ServerErrors metrics.
+func WrapAPIHandler(emitter *Emitter, endpoint string, handler APIHandler) APIHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+		response, err := handler(ctx, request)
+
+		statusCode := response.StatusCode
+		if err != nil {
+			// API Gateway answers a failed invocation with a bad gateway
+			statusCode = 502
+		}
+		emitter.NewEntry(Dimension{Name: "Endpoint", Value: endpoint}).
+			Duration("Latency", time.Since(start)).
+			Count("Requests", 1).
+			Count("ClientErrors", boolToInt(statusCode >= 400 && statusCode < 500)).
+			Count("ServerErrors", boolToInt(statusCode >= 500)).
+			Property("StatusCode", statusCode).
+			Property("Method", request.HTTPMethod).
+			Emit()
+
+		return response, err
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,169 @@
+// Package metrics writes CloudWatch metrics as Embedded Metric Format log
+// lines. CloudWatch extracts the metrics from the lambda logs, so no metrics
+// client or network call is needed.
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultNamespace is the namespace of the metrics, the log metric filters use it too.
+const DefaultNamespace = "Termoficare"
+
+// Unit is a CloudWatch metric unit.
+type Unit string
+
+const (
+	UnitCount        Unit = "Count"
+	UnitMilliseconds Unit = "Milliseconds"
+	UnitNone         Unit = "None"
+)
+
+// Dimension is a name and value the metrics of an entry are grouped by.
+type Dimension struct {
+	Name  string
+	Value string
+}
+
+// Emitter writes the metric entries, one JSON line each. A nil Emitter
+// discards them, so metrics are optional wherever one is passed.
+type Emitter struct {
+	mutex      sync.Mutex
+	writer     io.Writer
+	namespace  string
+	dimensions []Dimension
+	now        func() time.Time
+}
+
+// New returns an emitter writing to the writer, usually os.Stdout. The
+// dimensions are added to every entry.
+func New(writer io.Writer, namespace string, dimensions ...Dimension) *Emitter {
+	return &Emitter{
+		writer:     writer,
+		namespace:  namespace,
+		dimensions: dimensions,
+		now:        time.Now,
+	}
+}
+
+// Entry is a set of metrics written on a single line, sharing its dimensions.
+type Entry struct {
+	emitter    *Emitter
+	dimensions []Dimension
+	metrics    []metricValue
+	properties map[string]any
+}
+
+type metricValue struct {
+	name  string
+	value float64
+	unit  Unit
+}
+
+// NewEntry starts an entry with the emitter dimensions and the given ones.
+func (e *Emitter) NewEntry(dimensions ...Dimension) *Entry {
+	if e == nil {
+		return nil
+	}
+	entry := &Entry{
+		emitter:    e,
+		dimensions: make([]Dimension, 0, len(e.dimensions)+len(dimensions)),
+		properties: make(map[string]any),
+	}
+	entry.dimensions = append(entry.dimensions, e.dimensions...)
+	entry.dimensions = append(entry.dimensions, dimensions...)
+	return entry
+}
+
+func (e *Entry) Value(name string, value float64, unit Unit) *Entry {
+	if e == nil {
+		return nil
+	}
+	e.metrics = append(e.metrics, metricValue{name: name, value: value, unit: unit})
+	return e
+}
+
+func (e *Entry) Count(name string, count int) *Entry {
+	return e.Value(name, float64(count), UnitCount)
+}
+
+// Duration records the duration in milliseconds.
+func (e *Entry) Duration(name string, duration time.Duration) *Entry {
+	return e.Value(name, float64(duration.Microseconds())/1000.0, UnitMilliseconds)
+}
+
+// Property adds a field to the log line which is not a metric, to find the
+// line from a metric in CloudWatch Logs Insights.
+func (e *Entry) Property(name string, value any) *Entry {
+	if e == nil {
+		return nil
+	}
+	e.properties[name] = value
+	return e
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// Emit writes the entry. A failed write is logged, metrics never fail the caller.
+func (e *Entry) Emit() {
+	if e == nil || len(e.metrics) == 0 {
+		return
+	}
+
+	// the metric values and the dimension values are top level members, the
+	// metadata tells which members are which
+	line := make(map[string]any, len(e.properties)+len(e.dimensions)+len(e.metrics)+1)
+	for name, value := range e.properties {
+		line[name] = value
+	}
+	dimensionNames := make([]string, 0, len(e.dimensions))
+	for _, dimension := range e.dimensions {
+		dimensionNames = append(dimensionNames, dimension.Name)
+		line[dimension.Name] = dimension.Value
+	}
+	metrics := make([]emfMetric, 0, len(e.metrics))
+	for _, metric := range e.metrics {
+		metrics = append(metrics, emfMetric{Name: metric.name, Unit: metric.unit})
+		line[metric.name] = metric.value
+	}
+	line["_aws"] = emfMetadata{
+		Timestamp: e.emitter.now().UnixMilli(),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  e.emitter.namespace,
+			Dimensions: [][]string{dimensionNames},
+			Metrics:    metrics,
+		}},
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		slog.Warn("Failed to marshal metrics", "error_msg", err.Error())
+		return
+	}
+	data = append(data, '\n')
+
+	// concurrent entries must not interleave their lines
+	e.emitter.mutex.Lock()
+	defer e.emitter.mutex.Unlock()
+	if _, err := e.emitter.writer.Write(data); err != nil {
+		slog.Warn("Failed to write metrics", "error_msg", err.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// decodeLines decodes every EMF line written to the buffer.
+func decodeLines(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	t.Helper()
+	lines := make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		decoded := make(map[string]any)
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, decoded)
+	}
+	return lines
+}
+
+func TestEntryEmit(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	emitter := New(buffer, DefaultNamespace, Dimension{Name: "Service", Value: "etl"})
+	emitter.now = func() time.Time { return time.UnixMilli(1700000000123) }
+
+	emitter.NewEntry().
+		Count("StationsParsed", 42).
+		Duration("FetchLatency", 1500*time.Millisecond).
+		Property("runId", "run-1").
+		Emit()
+	// an entry without metrics writes nothing
+	emitter.NewEntry().Property("runId", "run-2").Emit()
+
+	lines := decodeLines(t, buffer)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %s", len(lines), buffer.String())
+	}
+	line := lines[0]
+	if line["StationsParsed"] != 42.0 || line["FetchLatency"] != 1500.0 || line["Service"] != "etl" || line["runId"] != "run-1" {
+		t.Errorf("line members = %+v", line)
+	}
+
+	metadata, _ := json.Marshal(line["_aws"])
+	want := `{"CloudWatchMetrics":[{"Dimensions":[["Service"]],"Metrics":[{"Name":"StationsParsed","Unit":"Count"},{"Name":"FetchLatency","Unit":"Milliseconds"}],"Namespace":"Termoficare"}],"Timestamp":1700000000123}`
+	if string(metadata) != want {
+		t.Errorf("_aws = %s, want %s", metadata, want)
+	}
+}
+
+func TestNilEmitter(t *testing.T) {
+	var emitter *Emitter
+	// must not panic
+	emitter.NewEntry().Count("Requests", 1).Property("a", 1).Emit()
+}
+
+func TestWrapAPIHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		response         events.APIGatewayProxyResponse
+		err              error
+		wantStatus       float64
+		wantClientErrors float64
+		wantServerErrors float64
+	}{
+		{name: "ok", response: events.APIGatewayProxyResponse{StatusCode: 200}, wantStatus: 200},
+		{name: "bad request", response: events.APIGatewayProxyResponse{StatusCode: 400}, wantStatus: 400, wantClientErrors: 1},
+		{name: "internal error", response: events.APIGatewayProxyResponse{StatusCode: 500}, wantStatus: 500, wantServerErrors: 1},
+		{name: "invocation error", err: errors.New("boom"), wantStatus: 502, wantServerErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			handler := WrapAPIHandler(New(buffer, DefaultNamespace), "counts", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return tt.response, tt.err
+			})
+
+			response, err := handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+			if response.StatusCode != tt.response.StatusCode || !errors.Is(err, tt.err) {
+				t.Errorf("handler() = %+v, %v, want the wrapped handler result", response, err)
+			}
+
+			lines := decodeLines(t, buffer)
+			if len(lines) != 1 {
+				t.Fatalf("got %d lines, want 1", len(lines))
+			}
+			line := lines[0]
+			if line["Endpoint"] != "counts" || line["Requests"] != 1.0 || line["StatusCode"] != tt.wantStatus ||
+				line["ClientErrors"] != tt.wantClientErrors || line["ServerErrors"] != tt.wantServerErrors {
+				t.Errorf("line = %+v", line)
+			}
+			if _, ok := line["Latency"].(float64); !ok {
+				t.Errorf("line has no latency: %+v", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Package repair rebuilds the day counts of a period from the status history,
+// or from its S3 archive, and compares them with the stored rows. It fills the
+// holes left by failed runs, fixes miscomputed rows, and gives counts to the
+// periods whose history was backfilled from other sources.
+package repair
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+)
+
+// Kinds of the differences between the stored and the rebuilt counts.
+const (
+	// DifferenceMissing is a time of the schedule without stored row
+	DifferenceMissing = "missing"
+	// DifferenceChanged is a stored row with other counts than the rebuilt ones
+	DifferenceChanged = "changed"
+)
+
+// Options tell which rows are rebuilt and which ones are written.
+type Options struct {
+	From time.Time
+	To   time.Time
+	// Interval is the interval of the runs, the slots of the period without a
+	// stored row are rebuilt at every interval
+	Interval       time.Duration
+	Reconstruction scrapper.CountsReconstructionOptions
+	// Write writes the rebuilt rows of the differences, the report is only a
+	// dry run without it
+	Write bool
+	// OnlyMissing leaves the stored rows as they are, only the holes are filled
+	OnlyMissing bool
+	// MissingDays are the UTC days the statuses are missing for, such as the
+	// days without archive backup. The times a status of these days could
+	// hold at are not rebuilt, their stored rows are uncheckable.
+	MissingDays []string
+}
+
+// Difference is a time the stored counts differ from the rebuilt ones at.
+type Difference struct {
+	Time int64  `json:"time"`
+	Kind string `json:"kind"`
+	// Fields are the counts that changed, by their JSON name
+	Fields  []string                     `json:"fields,omitempty"`
+	Stored  *scrapper.StationStatesCount `json:"stored,omitempty"`
+	Rebuilt scrapper.StationStatesCount  `json:"rebuilt"`
+	Written bool                         `json:"written"`
+}
+
+// Report tells what a repair compared and wrote.
+type Report struct {
+	From      int64 `json:"from"`
+	To        int64 `json:"to"`
+	NumStored int   `json:"numStored"`
+	// NumRebuilt counts the times the history had stations at, the stored rows
+	// of the others cannot be checked
+	NumRebuilt     int `json:"numRebuilt"`
+	NumUnchanged   int `json:"numUnchanged"`
+	NumMissing     int `json:"numMissing"`
+	NumChanged     int `json:"numChanged"`
+	NumUncheckable int `json:"numUncheckable"`
+	NumWritten     int `json:"numWritten"`
+	// MissingDays are the days of Options.MissingDays
+	MissingDays []string `json:"missingDays"`
+	// DryRun is set when nothing was written
+	DryRun      bool         `json:"dryRun"`
+	Differences []Difference `json:"differences"`
+}
+
+// RepairCounts rebuilds the counts of the period from the statuses, at the time
+// of every stored row and at the schedule slots without a row, and writes the
+// differing ones when opts.Write is set. statuses must cover the period and
+// the MaxSampleDuration and InactiveGracePeriod before it.
+func RepairCounts(ctx context.Context, counts storage.DayCountsRepository, statuses []scrapper.HeatingStationStatus, opts Options) (report Report, err error) {
+	ctx, span := tracing.Start(ctx, "repair.counts")
+	defer func() {
+		span.SetAttribute("repair.num_missing", report.NumMissing)
+		span.SetAttribute("repair.num_changed", report.NumChanged)
+		span.SetAttribute("repair.num_written", report.NumWritten)
+		span.RecordError(err)
+		span.End()
+	}()
+
+	report = Report{From: opts.From.Unix(), To: opts.To.Unix(), MissingDays: make([]string, 0), Differences: make([]Difference, 0), DryRun: !opts.Write}
+	if opts.Interval <= 0 {
+		return report, fmt.Errorf("invalid interval %s", opts.Interval)
+	}
+	report.MissingDays = append(report.MissingDays, opts.MissingDays...)
+	gaps, err := missingDayGaps(opts.MissingDays, opts.Reconstruction.MaxSampleDuration)
+	if err != nil {
+		return report, err
+	}
+
+	stored, err := counts.ListCounts(ctx, opts.From, opts.To)
+	if err != nil {
+		return report, fmt.Errorf("failed to list stored day counts: %w", err)
+	}
+	report.NumStored = len(stored)
+	storedByTime := make(map[int64]scrapper.StationStatesCount, len(stored))
+	for _, row := range stored {
+		storedByTime[row.Time] = row
+	}
+
+	times := repairTimes(stored, opts.From, opts.To, opts.Interval)
+	times = slices.DeleteFunc(times, gaps.contains)
+	rebuilt := scrapper.ReconstructCounts(statuses, times, opts.Reconstruction)
+	report.NumRebuilt = len(rebuilt)
+	rebuiltTimes := make(map[int64]bool, len(rebuilt))
+
+	for _, row := range rebuilt {
+		rebuiltTimes[row.Time] = true
+		storedRow, found := storedByTime[row.Time]
+		difference := Difference{Time: row.Time, Rebuilt: row}
+		if !found {
+			difference.Kind = DifferenceMissing
+			report.NumMissing++
+		} else {
+			difference.Fields = changedFields(storedRow, row)
+			if len(difference.Fields) == 0 {
+				report.NumUnchanged++
+				continue
+			}
+			difference.Kind = DifferenceChanged
+			difference.Stored = &storedRow
+			report.NumChanged++
+		}
+
+		if opts.Write && (!found || !opts.OnlyMissing) {
+			if err := counts.PutCounts(ctx, row); err != nil {
+				return report, fmt.Errorf("failed to write day counts of %d: %w", row.Time, err)
+			}
+			difference.Written = true
+			report.NumWritten++
+		}
+		report.Differences = append(report.Differences, difference)
+	}
+	for _, row := range stored {
+		if !rebuiltTimes[row.Time] {
+			report.NumUncheckable++
+		}
+	}
+
+	slog.Info("Day counts compared with the history",
+		"from", opts.From,
+		"to", opts.To,
+		"numStored", report.NumStored,
+		"numRebuilt", report.NumRebuilt,
+		"numMissing", report.NumMissing,
+		"numChanged", report.NumChanged,
+		"numUncheckable", report.NumUncheckable,
+		"numMissingDays", len(report.MissingDays),
+		"numWritten", report.NumWritten,
+	)
+	return report, nil
+}
+
+// repairTimes returns the times of the stored rows, and the schedule slots of
+// the period with no stored row within half an interval, sorted.
+func repairTimes(stored []scrapper.StationStatesCount, from, to time.Time, interval time.Duration) []int64 {
+	halfInterval := int64(interval/time.Second) / 2
+	storedTimes := make([]int64, 0, len(stored))
+	for _, row := range stored {
+		storedTimes = append(storedTimes, row.Time)
+	}
+	sort.Slice(storedTimes, func(i, j int) bool { return storedTimes[i] < storedTimes[j] })
+
+	times := append(make([]int64, 0, len(storedTimes)), storedTimes...)
+	slot := from.UTC().Truncate(interval)
+	if slot.Before(from) {
+		slot = slot.Add(interval)
+	}
+	for ; !slot.After(to); slot = slot.Add(interval) {
+		t := slot.Unix()
+		// the stored row closest to the slot
+		i := sort.Search(len(storedTimes), func(i int) bool { return storedTimes[i] >= t })
+		covered := (i < len(storedTimes) && storedTimes[i]-t < halfInterval) ||
+			(i > 0 && t-storedTimes[i-1] < halfInterval)
+		if !covered {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times
+}
+
+// gap is a period the statuses are missing for, from its start included to its end excluded.
+type gap struct {
+	start int64
+	end   int64
+}
+
+type gaps []gap
+
+// missingDayGaps returns the periods of the missing days, extended by the
+// sample duration: the statuses of a day hold until that long after it.
+func missingDayGaps(days []string, sampleDuration time.Duration) (gaps, error) {
+	result := make(gaps, 0, len(days))
+	for _, day := range days {
+		start, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return nil, fmt.Errorf("invalid missing day: %w", err)
+		}
+		result = append(result, gap{
+			start: start.Unix(),
+			end:   start.AddDate(0, 0, 1).Add(sampleDuration).Unix(),
+		})
+	}
+	return result, nil
+}
+
+// contains tells if the time is in one of the gaps.
+func (g gaps) contains(t int64) bool {
+	for _, period := range g {
+		if t >= period.start && t < period.end {
+			return true
+		}
+	}
+	return false
+}
+
+// changedFields returns the JSON names of the counts differing between two rows.
+func changedFields(stored, rebuilt scrapper.StationStatesCount) []string {
+	const floatTolerance = 1e-6
+
+	storedValue := reflect.ValueOf(stored)
+	rebuiltValue := reflect.ValueOf(rebuilt)
+	countsType := storedValue.Type()
+
+	fields := make([]string, 0)
+	for i := 0; i < countsType.NumField(); i++ {
+		a, b := storedValue.Field(i), rebuiltValue.Field(i)
+		var changed bool
+		switch a.Kind() {
+		case reflect.Float64:
+			changed = math.Abs(a.Float()-b.Float()) > floatTolerance
+		default:
+			changed = !a.Equal(b)
+		}
+		if changed {
+			fields = append(fields, jsonName(countsType.Field(i)))
+		}
+	}
+	return fields
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
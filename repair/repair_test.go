@@ -0,0 +1,208 @@
+package repair
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+)
+
+func TestRepairCounts(t *testing.T) {
+	ctx := context.Background()
+	weights, err := scrapper.LoadStationWeights(strings.NewReader("geoId,residents\n1,1000\n2,2000\n"), 100)
+	if err != nil {
+		t.Fatalf("LoadStationWeights() error = %v", err)
+	}
+
+	start := time.Date(2025, time.December, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) int64 {
+		return start.Add(time.Duration(minutes) * time.Minute).Unix()
+	}
+	// station 2 breaks at 60, the run of 30 failed and the row of 60 was miscomputed
+	statuses := []scrapper.HeatingStationStatus{
+		{GeoId: 1, Status: "working", FetchTime: at(0)},
+		{GeoId: 2, Status: "working", FetchTime: at(0)},
+		{GeoId: 2, Status: "broken", IncidentType: "Oprire ACC", FetchTime: at(60)},
+	}
+	stored := []scrapper.StationStatesCount{
+		{Time: at(0), NumGreen: 2, TotalStations: 2},
+		{Time: at(60), NumGreen: 2, TotalStations: 2},
+	}
+
+	newStore := func() *storage.MemoryStore {
+		store := storage.NewMemoryStore()
+		for _, row := range stored {
+			store.PutCounts(ctx, row)
+		}
+		return store
+	}
+	options := func(write, onlyMissing bool) Options {
+		return Options{
+			From:     start,
+			To:       start.Add(time.Hour),
+			Interval: 30 * time.Minute,
+			Reconstruction: scrapper.CountsReconstructionOptions{
+				MaxSampleDuration:   6 * time.Hour,
+				InactiveGracePeriod: 24 * time.Hour,
+				Weights:             weights,
+			},
+			Write:       write,
+			OnlyMissing: onlyMissing,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		write       bool
+		onlyMissing bool
+		wantWritten []int64
+	}{
+		{name: "dry run writes nothing"},
+		{name: "only missing fills the hole", write: true, onlyMissing: true, wantWritten: []int64{at(30)}},
+		{name: "write fixes every difference", write: true, wantWritten: []int64{at(30), at(60)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore()
+			report, err := RepairCounts(ctx, store, statuses, options(tt.write, tt.onlyMissing))
+			if err != nil {
+				t.Fatalf("RepairCounts() error = %v", err)
+			}
+			if report.NumStored != 2 || report.NumRebuilt != 3 || report.NumUnchanged != 1 || report.NumMissing != 1 || report.NumChanged != 1 {
+				t.Errorf("RepairCounts() = %+v, want 1 unchanged, 1 missing and 1 changed row", report)
+			}
+			if report.DryRun == tt.write || report.NumWritten != len(tt.wantWritten) {
+				t.Errorf("RepairCounts() wrote %d rows, dry run %v, want %d", report.NumWritten, report.DryRun, len(tt.wantWritten))
+			}
+
+			changed := report.Differences[1]
+			if changed.Kind != DifferenceChanged || strings.Join(changed.Fields, ",") != "numGreen,numRed,residentsWithoutHotWater,incidentsOpened,hotWaterStops" {
+				t.Errorf("difference = %+v, want the changed row of 60 with its fields", changed)
+			}
+
+			rows, _ := store.ListCounts(ctx, start, start.Add(time.Hour))
+			rowsByTime := make(map[int64]scrapper.StationStatesCount, len(rows))
+			for _, row := range rows {
+				rowsByTime[row.Time] = row
+			}
+			written := make([]int64, 0)
+			for _, difference := range report.Differences {
+				if !difference.Written {
+					continue
+				}
+				written = append(written, difference.Time)
+				if rowsByTime[difference.Time] != difference.Rebuilt {
+					t.Errorf("row at %d = %+v, want the rebuilt %+v", difference.Time, rowsByTime[difference.Time], difference.Rebuilt)
+				}
+			}
+			if fmt.Sprint(written) != fmt.Sprint(tt.wantWritten) {
+				t.Errorf("written rows at %v, want %v", written, tt.wantWritten)
+			}
+			if !tt.write && len(rows) != 2 {
+				t.Errorf("dry run rows = %+v, want the stored ones", rows)
+			}
+		})
+	}
+}
+
+func TestRepairCountsMissingDays(t *testing.T) {
+	ctx := context.Background()
+	weights, err := scrapper.LoadStationWeights(strings.NewReader("geoId,residents\n1,1000\n"), 100)
+	if err != nil {
+		t.Fatalf("LoadStationWeights() error = %v", err)
+	}
+
+	start := time.Date(2025, time.December, 1, 10, 0, 0, 0, time.UTC)
+	statuses := []scrapper.HeatingStationStatus{{GeoId: 1, Status: "working", FetchTime: start.Unix()}}
+	store := storage.NewMemoryStore()
+	store.PutCounts(ctx, scrapper.StationStatesCount{Time: start.Unix(), NumRed: 1, TotalStations: 1})
+
+	tests := []struct {
+		name            string
+		missingDays     []string
+		wantRebuilt     int
+		wantUncheckable int
+	}{
+		{name: "no missing day", wantRebuilt: 1},
+		// the statuses of the day before hold until 6h after its end, not until 10h
+		{name: "day before missing", missingDays: []string{"2025-11-30"}, wantRebuilt: 1},
+		{name: "day of the row missing", missingDays: []string{"2025-12-01"}, wantUncheckable: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := RepairCounts(ctx, store, statuses, Options{
+				From:     start,
+				To:       start,
+				Interval: 30 * time.Minute,
+				Reconstruction: scrapper.CountsReconstructionOptions{
+					MaxSampleDuration:   6 * time.Hour,
+					InactiveGracePeriod: 24 * time.Hour,
+					Weights:             weights,
+				},
+				MissingDays: tt.missingDays,
+			})
+			if err != nil {
+				t.Fatalf("RepairCounts() error = %v", err)
+			}
+			if report.NumRebuilt != tt.wantRebuilt || report.NumUncheckable != tt.wantUncheckable || len(report.MissingDays) != len(tt.missingDays) {
+				t.Errorf("RepairCounts() = %+v, want %d rebuilt and %d uncheckable rows", report, tt.wantRebuilt, tt.wantUncheckable)
+			}
+		})
+	}
+}
+
+func TestRepairTimes(t *testing.T) {
+	start := time.Date(2025, time.December, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) int64 {
+		return start.Add(time.Duration(minutes) * time.Minute).Unix()
+	}
+
+	// rows of the runs before the slots were keyed are a few seconds late
+	stored := []scrapper.StationStatesCount{{Time: at(0) + 7}, {Time: at(61)}}
+	got := repairTimes(stored, start.Add(-time.Minute), start.Add(90*time.Minute), 30*time.Minute)
+	want := []int64{at(0) + 7, at(30), at(61), at(90)}
+	if len(got) != len(want) {
+		t.Fatalf("repairTimes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("repairTimes() = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestStatuses(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, time.December, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) int64 {
+		return start.Add(time.Duration(minutes) * time.Minute).Unix()
+	}
+
+	store := storage.NewMemoryStore()
+	store.PutStations(ctx, []scrapper.HeatingStation{{GeoId: 1}, {GeoId: 2}})
+	store.PutStatuses(ctx, []scrapper.HeatingStationStatus{
+		{GeoId: 1, Status: "working", FetchTime: at(-30)},
+		{GeoId: 1, Status: "broken", FetchTime: at(30)},
+		{GeoId: 2, Status: "working", FetchTime: at(60)},
+		{GeoId: 2, Status: "issue", FetchTime: at(90)},
+	})
+
+	history, err := HistoryStatuses(ctx, store, store, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("HistoryStatuses() error = %v", err)
+	}
+	archive, missingDays, err := ArchiveStatuses(ctx, store, start, start.Add(time.Hour))
+	if err != nil || len(missingDays) != 0 {
+		t.Fatalf("ArchiveStatuses() missing days %v, error = %v", missingDays, err)
+	}
+	for name, statuses := range map[string][]scrapper.HeatingStationStatus{"HistoryStatuses": history, "ArchiveStatuses": archive} {
+		if len(statuses) != 2 || statuses[0].FetchTime != at(30) || statuses[1].FetchTime != at(60) {
+			t.Errorf("%s() = %+v, want the statuses of 30 and 60", name, statuses)
+		}
+	}
+}
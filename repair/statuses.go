@@ -0,0 +1,40 @@
+package repair
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+)
+
+// HistoryStatuses returns the statuses of every known station taken between
+// from and to included, from the status history table.
+func HistoryStatuses(ctx context.Context, stations storage.StationRepository, history storage.StatusRetentionRepository, from, to time.Time) ([]scrapper.HeatingStationStatus, error) {
+	known, err := stations.ListStations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stations: %w", err)
+	}
+
+	statuses := make([]scrapper.HeatingStationStatus, 0)
+	for _, station := range known {
+		stationStatuses, err := history.ListStationStatusesBetween(ctx, station.GeoId, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list statuses of station %d: %w", station.GeoId, err)
+		}
+		statuses = append(statuses, stationStatuses...)
+	}
+	return statuses, nil
+}
+
+// ArchiveStatuses returns the statuses taken after from and until to included,
+// from the status archive, and the UTC days of the period it has no backup
+// for. The history backfilled from other sources is only in the archive.
+func ArchiveStatuses(ctx context.Context, archive storage.StatusRangeArchive, from, to time.Time) ([]scrapper.HeatingStationStatus, []string, error) {
+	statuses, missingDays, err := archive.ListStatusesBetween(ctx, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list archived statuses: %w", err)
+	}
+	return statuses, missingDays, nil
+}
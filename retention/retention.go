@@ -0,0 +1,122 @@
+// Package retention bounds the status history table. The ETL writes status
+// rows with a TTL, and the rows about to expire are checked against the S3
+// archive first, so a sample is never lost with its row.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
+	"golang.org/x/sync/errgroup"
+)
+
+// ttlDeletionLag is how long DynamoDB can keep an expired row before deleting
+// it, such rows are still checked.
+const ttlDeletionLag = 48 * time.Hour
+
+// maxConcurrentQueries caps the station queries run at once
+const maxConcurrentQueries = 8
+
+// Policy tells how long status rows live and how they are checked.
+type Policy struct {
+	// Retention is the TTL the ETL sets on status rows
+	Retention time.Duration
+	// Window is how far ahead expiring rows are checked, longer than the
+	// interval between checks so every row is checked at least once
+	Window time.Duration
+	// Extension is the new lifetime of a row missing from the archive
+	Extension time.Duration
+}
+
+func DefaultPolicy(retention time.Duration) Policy {
+	return Policy{
+		Retention: retention,
+		Window:    3 * 24 * time.Hour,
+		Extension: 7 * 24 * time.Hour,
+	}
+}
+
+// Result counts the rows a check looked at.
+type Result struct {
+	NumStations   int
+	NumExpiring   int
+	NumUnarchived int
+}
+
+// VerifyArchive lists the rows of the stations expiring within the policy
+// window, and rewrites the ones missing from the archived statuses with a later
+// expiry. The rewrite goes through the table stream, which archives the rows
+// again before the next check.
+func VerifyArchive(ctx context.Context, history storage.StatusRetentionRepository, geoIds []int64, archived []scrapper.HeatingStationStatus, policy Policy, now time.Time) (result Result, err error) {
+	ctx, span := tracing.Start(ctx, "retention.verify_archive")
+	defer func() {
+		span.SetAttribute("retention.num_expiring", result.NumExpiring)
+		span.SetAttribute("retention.num_unarchived", result.NumUnarchived)
+		span.RecordError(err)
+		span.End()
+	}()
+
+	result = Result{NumStations: len(geoIds)}
+	if policy.Retention <= 0 {
+		return result, nil
+	}
+
+	// rows expire Retention after their fetch time
+	from := now.Add(-policy.Retention - ttlDeletionLag)
+	to := now.Add(-policy.Retention + policy.Window)
+	expiresBefore := now.Add(policy.Window).Unix()
+
+	expiring := make([]scrapper.HeatingStationStatus, 0)
+	expiringMutex := sync.Mutex{}
+
+	errG, errCtx := errgroup.WithContext(ctx)
+	errG.SetLimit(maxConcurrentQueries)
+	for _, geoId := range geoIds {
+		errG.Go(func() error {
+			rows, err := history.ListStationStatusesBetween(errCtx, geoId, from, to)
+			if err != nil {
+				return fmt.Errorf("failed to list statuses of station %d: %w", geoId, err)
+			}
+
+			expiringMutex.Lock()
+			defer expiringMutex.Unlock()
+			for _, row := range rows {
+				if row.ExpiresAt != 0 && row.ExpiresAt <= expiresBefore {
+					expiring = append(expiring, row)
+				}
+			}
+			return nil
+		})
+	}
+	if err := errG.Wait(); err != nil {
+		return result, err
+	}
+	result.NumExpiring = len(expiring)
+
+	unarchived := scrapper.FindUnarchivedStatuses(expiring, archived)
+	result.NumUnarchived = len(unarchived)
+	if len(unarchived) == 0 {
+		return result, nil
+	}
+
+	// a metric filter alarms on this message
+	slog.Error("Status rows missing from the archive, expiry extended",
+		"numUnarchived", len(unarchived),
+		"firstFetchTime", unarchived[0].FetchTime,
+		"extension", policy.Extension.String(),
+	)
+	extendedUntil := now.Add(policy.Extension).Unix()
+	for i := range unarchived {
+		unarchived[i].ExpiresAt = extendedUntil
+	}
+	if err := history.PutStatuses(ctx, unarchived); err != nil {
+		return result, fmt.Errorf("failed to extend the expiry of unarchived statuses: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/storage"
+)
+
+func TestVerifyArchive(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(100*24*3600, 0)
+	policy := DefaultPolicy(30 * 24 * time.Hour)
+
+	// fetched a retention ago, the row expires now
+	expiringTime := now.Add(-policy.Retention).Unix()
+	// fetched recently, the row expires after the window
+	recentTime := now.Add(-time.Hour).Unix()
+
+	statuses := []scrapper.HeatingStationStatus{
+		{GeoId: 1, Status: "working", FetchTime: expiringTime},
+		{GeoId: 1, Status: "broken", FetchTime: recentTime},
+		{GeoId: 2, Status: "issue", FetchTime: expiringTime},
+		// written before the retention was enabled, never expires
+		{GeoId: 3, Status: "working", FetchTime: expiringTime},
+	}
+	scrapper.ApplyStatusRetention(statuses[:3], policy.Retention)
+
+	store := storage.NewMemoryStore()
+	if err := store.PutStatuses(ctx, statuses); err != nil {
+		t.Fatalf("PutStatuses() error = %v", err)
+	}
+	archived := []scrapper.HeatingStationStatus{statuses[0], statuses[1]}
+
+	result, err := VerifyArchive(ctx, store, []int64{1, 2, 3}, archived, policy, now)
+	if err != nil {
+		t.Fatalf("VerifyArchive() error = %v", err)
+	}
+	want := Result{NumStations: 3, NumExpiring: 2, NumUnarchived: 1}
+	if result != want {
+		t.Errorf("VerifyArchive() = %+v, want %+v", result, want)
+	}
+
+	rows, err := store.ListStationStatuses(ctx, 2, 10)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("ListStationStatuses() = %+v, %v", rows, err)
+	}
+	if rows[0].ExpiresAt != now.Add(policy.Extension).Unix() {
+		t.Errorf("unarchived row ExpiresAt = %d, want %d", rows[0].ExpiresAt, now.Add(policy.Extension).Unix())
+	}
+
+	rows, err = store.ListStationStatuses(ctx, 1, 10)
+	if err != nil || len(rows) != 2 || rows[1].ExpiresAt != statuses[0].ExpiresAt {
+		t.Errorf("archived row was rewritten: %+v, %v", rows, err)
+	}
+}
+
+func TestVerifyArchiveWithoutRetention(t *testing.T) {
+	store := storage.NewMemoryStore()
+	result, err := VerifyArchive(context.Background(), store, []int64{1}, nil, Policy{}, time.Now())
+	if err != nil || result.NumExpiring != 0 {
+		t.Errorf("VerifyArchive() = %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,98 @@
+package scrapper
+
+import (
+	"sort"
+	"time"
+)
+
+// CountsReconstructionOptions tell how the statuses of a history hold between
+// their rows.
+type CountsReconstructionOptions struct {
+	// MaxSampleDuration is how long a status holds without a newer row, the
+	// heartbeat of a change-only history with the interval of the runs. A
+	// station without a row for longer was off the map.
+	MaxSampleDuration time.Duration
+	// InactiveGracePeriod is how long a station off the map stays stored as
+	// active, the missing stations are counted from it, see DiffSnapshot
+	InactiveGracePeriod time.Duration
+	Weights             *StationWeights
+}
+
+// ReconstructCounts rebuilds the counts of the snapshots taken at the given
+// times from a status history. At each time a station shows its last status,
+// unless the row is older than MaxSampleDuration, and the stored state the
+// churn counts compare with is the last status of every station before that
+// time. The counts are returned in the order of times, the times no station
+// was on the map at are left out.
+func ReconstructCounts(statuses []HeatingStationStatus, times []int64, opts CountsReconstructionOptions) []StationStatesCount {
+	byStation := make(map[int64][]HeatingStationStatus)
+	for _, status := range statuses {
+		byStation[status.GeoId] = append(byStation[status.GeoId], status)
+	}
+	for _, stationStatuses := range byStation {
+		sort.SliceStable(stationStatuses, func(i, j int) bool {
+			return stationStatuses[i].FetchTime < stationStatuses[j].FetchTime
+		})
+	}
+
+	maxSampleSeconds := int64(opts.MaxSampleDuration / time.Second)
+	activeSeconds := maxSampleSeconds + int64(opts.InactiveGracePeriod/time.Second)
+
+	result := make([]StationStatesCount, 0, len(times))
+	for _, t := range times {
+		snapshot := make([]HeatingStationStatus, 0, len(byStation))
+		previous := make(map[int64]HeatingStation, len(byStation))
+
+		for geoId, stationStatuses := range byStation {
+			// the first row after t
+			next := sort.Search(len(stationStatuses), func(i int) bool {
+				return stationStatuses[i].FetchTime > t
+			})
+			if next == 0 {
+				continue
+			}
+			current := stationStatuses[next-1]
+			if t-current.FetchTime < maxSampleSeconds || current.FetchTime == t {
+				// the snapshot rows are taken at the snapshot time, which the overdue incidents are counted at
+				current.FetchTime = t
+				snapshot = append(snapshot, current)
+			}
+
+			// the stored state is the one of the run before, which did not see the rows of t
+			before := next - 1
+			for before >= 0 && stationStatuses[before].FetchTime == t {
+				before--
+			}
+			if before >= 0 {
+				last := stationStatuses[before].ToHeatingStation()
+				last.Active = t-stationStatuses[before].FetchTime < activeSeconds
+				previous[geoId] = last
+			}
+		}
+		if len(snapshot) == 0 {
+			continue
+		}
+		sort.Slice(snapshot, func(i, j int) bool {
+			return snapshot[i].GeoId < snapshot[j].GeoId
+		})
+
+		counts := StationStatesCount{Time: t}
+		for _, status := range snapshot {
+			switch status.Status {
+			case "working":
+				counts.NumGreen++
+			case "issue":
+				counts.NumYellow++
+			case "broken":
+				counts.NumRed++
+			}
+		}
+		impact := ComputeImpactCounts(snapshot, opts.Weights)
+		counts.ResidentsWithoutHotWater = impact.ResidentsWithoutHotWater
+		counts.ResidentsWithIssues = impact.ResidentsWithIssues
+		counts.UnweightedStations = len(impact.UnweightedStations)
+		ApplySnapshotCounts(&counts, previous, snapshot)
+		result = append(result, counts)
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package scrapper
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconstructCounts(t *testing.T) {
+	weights, err := LoadStationWeights(strings.NewReader("geoId,residents\n1,1000\n2,2000\n"), 100)
+	if err != nil {
+		t.Fatalf("LoadStationWeights() error = %v", err)
+	}
+	opts := CountsReconstructionOptions{
+		MaxSampleDuration:   2 * time.Hour,
+		InactiveGracePeriod: 4 * time.Hour,
+		Weights:             weights,
+	}
+	const hour = 3600
+
+	// a change-only history: station 1 breaks at 2h, station 2 is heard of
+	// until 1h then leaves the map, station 3 shows up at 3h
+	statuses := []HeatingStationStatus{
+		{GeoId: 1, Status: "working", IncidentType: "-", FetchTime: 0},
+		{GeoId: 1, Status: "broken", IncidentType: "Oprire ACC", EstimatedFixDate: 2*hour + 1800, FetchTime: 2 * hour},
+		{GeoId: 2, Status: "issue", IncidentType: "Deficienta ACC", FetchTime: 0},
+		{GeoId: 2, Status: "issue", IncidentType: "Deficienta ACC", FetchTime: 1 * hour},
+		{GeoId: 3, Status: "working", FetchTime: 3 * hour},
+		{GeoId: 1, Status: "broken", IncidentType: "Oprire ACC", EstimatedFixDate: 2*hour + 1800, FetchTime: 3*hour + 1800},
+	}
+
+	got := ReconstructCounts(statuses, []int64{-hour, 0, 2 * hour, 3*hour + 1800}, opts)
+	want := []StationStatesCount{
+		{Time: 0, NumGreen: 1, NumYellow: 1, ResidentsWithIssues: 2000, TotalStations: 2, IncidentsOpened: 1, Deficiencies: 1},
+		{Time: 2 * hour, NumYellow: 1, NumRed: 1, ResidentsWithoutHotWater: 1000, ResidentsWithIssues: 2000,
+			TotalStations: 2, IncidentsOpened: 1, HotWaterStops: 1, Deficiencies: 1},
+		// station 2 is off the map but still stored as active, station 1 is overdue
+		{Time: 3*hour + 1800, NumGreen: 1, NumRed: 1, ResidentsWithoutHotWater: 1000, UnweightedStations: 1,
+			TotalStations: 2, MissingStations: 1, HotWaterStops: 1, OverdueIncidents: 1},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("ReconstructCounts() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("counts %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
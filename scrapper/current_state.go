@@ -0,0 +1,47 @@
+package scrapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// CurrentState is the state of every station after a snapshot, published as a
+// single document so the stations are served without a scan of the table.
+type CurrentState struct {
+	// Version names the document, it changes whenever the snapshot or a station does
+	Version      string           `json:"version"`
+	SnapshotTime int64            `json:"snapshotTime"`
+	Stations     []HeatingStation `json:"stations"`
+}
+
+// NewCurrentState returns the document of the stations as stored after the
+// snapshot taken at snapshotTime: the previous state with the written changes
+// applied. The stations are sorted by GeoId so the same state always has the
+// same version.
+func NewCurrentState(previous map[int64]HeatingStation, changes []HeatingStation, snapshotTime int64) CurrentState {
+	byGeoId := make(map[int64]HeatingStation, len(previous)+len(changes))
+	for geoId, station := range previous {
+		byGeoId[geoId] = station
+	}
+	for _, station := range changes {
+		byGeoId[station.GeoId] = station
+	}
+
+	stations := make([]HeatingStation, 0, len(byGeoId))
+	for _, station := range byGeoId {
+		stations = append(stations, station)
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].GeoId < stations[j].GeoId
+	})
+
+	state := CurrentState{SnapshotTime: snapshotTime, Stations: stations}
+	// a document of stations only has no field failing to marshal
+	content, _ := json.Marshal(stations)
+	hash := sha256.Sum256(content)
+	state.Version = fmt.Sprintf("%d-%s", snapshotTime, hex.EncodeToString(hash[:6]))
+	return state
+}
@@ -0,0 +1,45 @@
+package scrapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewCurrentState(t *testing.T) {
+	const snapshotTime = 1763640000
+
+	previous := map[int64]HeatingStation{
+		3: {GeoId: 3, Name: "Station3", LastStatus: "working", Active: true},
+		1: {GeoId: 1, Name: "Station1", LastStatus: "working", Active: true},
+	}
+	changes := []HeatingStation{
+		{GeoId: 1, Name: "Station1", LastStatus: "broken", LastIncidentText: "avarie", LastEstimatedFixDate: snapshotTime + 3600, Active: true},
+		{GeoId: 2, Name: "Station2", LastStatus: "working", Active: true},
+	}
+
+	state := NewCurrentState(previous, changes, snapshotTime)
+	if state.SnapshotTime != snapshotTime {
+		t.Errorf("SnapshotTime = %d, want %d", state.SnapshotTime, snapshotTime)
+	}
+	if len(state.Stations) != 3 {
+		t.Fatalf("Stations = %+v, want the 3 stations", state.Stations)
+	}
+	for i, geoId := range []int64{1, 2, 3} {
+		if state.Stations[i].GeoId != geoId {
+			t.Errorf("Stations[%d].GeoId = %d, want %d", i, state.Stations[i].GeoId, geoId)
+		}
+	}
+	if state.Stations[0].LastStatus != "broken" || state.Stations[0].LastIncidentText != "avarie" {
+		t.Errorf("Stations[0] = %+v, want the written change", state.Stations[0])
+	}
+	if !strings.HasPrefix(state.Version, "1763640000-") {
+		t.Errorf("Version = %q, want it prefixed by the snapshot time", state.Version)
+	}
+
+	if again := NewCurrentState(previous, changes, snapshotTime); again.Version != state.Version {
+		t.Errorf("Version of the same state = %q, want %q", again.Version, state.Version)
+	}
+	if unchanged := NewCurrentState(previous, nil, snapshotTime); unchanged.Version == state.Version {
+		t.Errorf("Version of a different state = %q, want it to differ", unchanged.Version)
+	}
+}
@@ -0,0 +1,143 @@
+package scrapper
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// EtlRun is the ledger row of an ETL run, written whether the run succeeded or not.
+type EtlRun struct {
+	RunId string `json:"runId" dynamodbav:"RunId"`
+	// Slot is the schedule slot of the run, the attempts of a slot share it
+	Slot           int64 `json:"slot,omitempty" dynamodbav:"Slot,omitempty"`
+	StartTime      int64 `json:"startTime" dynamodbav:"StartTime"`
+	EndTime        int64 `json:"endTime" dynamodbav:"EndTime"`
+	FetchLatencyMs int64 `json:"fetchLatencyMs" dynamodbav:"FetchLatencyMs"`
+	// HttpStatus is 0 when the map page could not be downloaded
+	HttpStatus          int    `json:"httpStatus" dynamodbav:"HttpStatus"`
+	PageHash            string `json:"pageHash" dynamodbav:"PageHash"`
+	PageSize            int    `json:"pageSize" dynamodbav:"PageSize"`
+	NumParsed           int    `json:"numParsed" dynamodbav:"NumParsed"`
+	NumStationsWritten  int    `json:"numStationsWritten" dynamodbav:"NumStationsWritten"`
+	NumStatusesWritten  int    `json:"numStatusesWritten" dynamodbav:"NumStatusesWritten"`
+	NumValidationIssues int    `json:"numValidationIssues" dynamodbav:"NumValidationIssues"`
+	Success             bool   `json:"success" dynamodbav:"Success"`
+	// ErrorClass tells at which step a failed run stopped, see etl.ErrorClass
+	ErrorClass   string `json:"errorClass,omitempty" dynamodbav:"ErrorClass,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty" dynamodbav:"ErrorMessage,omitempty"`
+}
+
+// SiteAvailable tells if the CMTEB site served the map page during the run.
+func (r EtlRun) SiteAvailable() bool {
+	return r.HttpStatus == http.StatusOK
+}
+
+// ObservationGap is a period without any successful snapshot, during which
+// nothing is known about the stations.
+type ObservationGap struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// ObservationGaps are sorted and non overlapping gaps.
+type ObservationGaps []ObservationGap
+
+// Unobserved returns the number of seconds between from and to covered by the gaps.
+func (g ObservationGaps) Unobserved(from, to int64) int64 {
+	var unobserved int64
+	for _, gap := range g {
+		start := max(gap.Start, from)
+		end := min(gap.End, to)
+		if end > start {
+			unobserved += end - start
+		}
+	}
+	return unobserved
+}
+
+// ComputeObservationGaps returns the periods longer than maxInterval between two
+// successful runs of the ledger, and between the last successful run and now.
+// Nothing is returned before the first recorded run, so a history older than the
+// ledger is considered observed.
+func ComputeObservationGaps(runs []EtlRun, maxInterval time.Duration, now time.Time) ObservationGaps {
+	sorted := make([]EtlRun, len(runs))
+	copy(sorted, runs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime < sorted[j].StartTime
+	})
+
+	maxSeconds := int64(maxInterval / time.Second)
+	gaps := make(ObservationGaps, 0)
+	var lastObserved int64
+	for _, run := range sorted {
+		if lastObserved == 0 {
+			// the first recorded run starts the observed period, even when it failed
+			lastObserved = run.StartTime
+		}
+		if !run.Success {
+			continue
+		}
+		if run.StartTime-lastObserved > maxSeconds {
+			gaps = append(gaps, ObservationGap{Start: lastObserved, End: run.StartTime})
+		}
+		lastObserved = run.StartTime
+	}
+
+	if lastObserved != 0 && now.Unix()-lastObserved > maxSeconds {
+		gaps = append(gaps, ObservationGap{Start: lastObserved, End: now.Unix()})
+	}
+	return gaps
+}
+
+// AvailabilityBucket summarizes the runs started in a period.
+type AvailabilityBucket struct {
+	Start int64 `json:"start"`
+	// NumRuns counts every run, NumAvailable the ones the site served the page to
+	NumRuns      int `json:"numRuns"`
+	NumAvailable int `json:"numAvailable"`
+	NumSucceeded int `json:"numSucceeded"`
+	// Availability is NumAvailable over NumRuns
+	Availability      float64        `json:"availability"`
+	AvgFetchLatencyMs float64        `json:"avgFetchLatencyMs"`
+	ErrorClasses      map[string]int `json:"errorClasses"`
+}
+
+// SummarizeAvailability groups the runs in buckets of the given size aligned on
+// the unix epoch, ordered from the oldest. Periods without any run have no bucket.
+func SummarizeAvailability(runs []EtlRun, bucketSize time.Duration) []AvailabilityBucket {
+	bucketSeconds := int64(bucketSize / time.Second)
+	buckets := make(map[int64]*AvailabilityBucket)
+	latencies := make(map[int64]int64)
+
+	for _, run := range runs {
+		start := run.StartTime - run.StartTime%bucketSeconds
+		bucket, exists := buckets[start]
+		if !exists {
+			bucket = &AvailabilityBucket{Start: start, ErrorClasses: make(map[string]int)}
+			buckets[start] = bucket
+		}
+		bucket.NumRuns++
+		if run.SiteAvailable() {
+			bucket.NumAvailable++
+		}
+		if run.Success {
+			bucket.NumSucceeded++
+		}
+		if run.ErrorClass != "" {
+			bucket.ErrorClasses[run.ErrorClass]++
+		}
+		latencies[start] += run.FetchLatencyMs
+	}
+
+	summary := make([]AvailabilityBucket, 0, len(buckets))
+	for start, bucket := range buckets {
+		bucket.Availability = float64(bucket.NumAvailable) / float64(bucket.NumRuns)
+		bucket.AvgFetchLatencyMs = float64(latencies[start]) / float64(bucket.NumRuns)
+		summary = append(summary, *bucket)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].Start < summary[j].Start
+	})
+	return summary
+}
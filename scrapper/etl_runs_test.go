@@ -0,0 +1,126 @@
+package scrapper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeObservationGaps(t *testing.T) {
+	const halfHour = int64(1800)
+	now := time.Unix(10*halfHour, 0)
+
+	tests := []struct {
+		name string
+		runs []EtlRun
+		want ObservationGaps
+	}{
+		{
+			name: "empty ledger",
+			runs: nil,
+			want: ObservationGaps{},
+		},
+		{
+			name: "one missed run is tolerated",
+			runs: []EtlRun{
+				{StartTime: 7 * halfHour, Success: true},
+				{StartTime: 8 * halfHour, Success: false},
+				{StartTime: 9 * halfHour, Success: true},
+			},
+			want: ObservationGaps{},
+		},
+		{
+			name: "failed runs between successes",
+			runs: []EtlRun{
+				{StartTime: 5 * halfHour, Success: false},
+				{StartTime: 4 * halfHour, Success: true},
+				{StartTime: 6 * halfHour, Success: false},
+				{StartTime: 7 * halfHour, Success: false},
+				{StartTime: 8 * halfHour, Success: true},
+				{StartTime: 9 * halfHour, Success: true},
+			},
+			want: ObservationGaps{{Start: 4 * halfHour, End: 8 * halfHour}},
+		},
+		{
+			name: "no success since the first run",
+			runs: []EtlRun{
+				{StartTime: 2 * halfHour, Success: false},
+				{StartTime: 3 * halfHour, Success: false},
+			},
+			want: ObservationGaps{{Start: 2 * halfHour, End: 10 * halfHour}},
+		},
+		{
+			name: "ledger stopped",
+			runs: []EtlRun{
+				{StartTime: 1 * halfHour, Success: true},
+				{StartTime: 2 * halfHour, Success: true},
+			},
+			want: ObservationGaps{{Start: 2 * halfHour, End: 10 * halfHour}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeObservationGaps(tt.runs, time.Hour, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComputeObservationGaps() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObservationGapsUnobserved(t *testing.T) {
+	gaps := ObservationGaps{{Start: 100, End: 200}, {Start: 300, End: 400}}
+
+	tests := []struct {
+		name     string
+		from, to int64
+		want     int64
+	}{
+		{name: "before the gaps", from: 0, to: 100, want: 0},
+		{name: "inside a gap", from: 120, to: 150, want: 30},
+		{name: "overlapping both gaps", from: 150, to: 350, want: 100},
+		{name: "covering both gaps", from: 0, to: 500, want: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gaps.Unobserved(tt.from, tt.to); got != tt.want {
+				t.Errorf("Unobserved(%d, %d) = %d, want %d", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeAvailability(t *testing.T) {
+	const hour = int64(3600)
+	runs := []EtlRun{
+		{StartTime: 0, HttpStatus: 200, Success: true, FetchLatencyMs: 100},
+		{StartTime: hour / 2, HttpStatus: 0, ErrorClass: "fetch", FetchLatencyMs: 300},
+		{StartTime: 2 * hour, HttpStatus: 200, ErrorClass: "parse", FetchLatencyMs: 200},
+	}
+
+	got := SummarizeAvailability(runs, time.Hour)
+	want := []AvailabilityBucket{
+		{
+			Start:             0,
+			NumRuns:           2,
+			NumAvailable:      1,
+			NumSucceeded:      1,
+			Availability:      0.5,
+			AvgFetchLatencyMs: 200,
+			ErrorClasses:      map[string]int{"fetch": 1},
+		},
+		{
+			Start:             2 * hour,
+			NumRuns:           1,
+			NumAvailable:      1,
+			Availability:      1,
+			AvgFetchLatencyMs: 200,
+			ErrorClasses:      map[string]int{"parse": 1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeAvailability() = %+v, want %+v", got, want)
+	}
+}
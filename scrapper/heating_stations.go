@@ -36,14 +36,38 @@ type StationStatesCount struct {
 	NumGreen  int   `json:"numGreen" dynamodbav:"numGreen"`
 	NumYellow int   `json:"numYellow" dynamodbav:"numYellow"`
 	NumRed    int   `json:"numRed" dynamodbav:"numRed"`
+	// residents weighted counts, see StationWeights
+	ResidentsWithoutHotWater float64 `json:"residentsWithoutHotWater" dynamodbav:"residentsWithoutHotWater"`
+	ResidentsWithIssues      float64 `json:"residentsWithIssues" dynamodbav:"residentsWithIssues"`
+	UnweightedStations       int     `json:"unweightedStations" dynamodbav:"unweightedStations"`
+	// churn, incident type and overdue counts, see ApplySnapshotCounts
+	TotalStations     int `json:"totalStations" dynamodbav:"totalStations"`
+	MissingStations   int `json:"missingStations" dynamodbav:"missingStations"`
+	IncidentsOpened   int `json:"incidentsOpened" dynamodbav:"incidentsOpened"`
+	IncidentsResolved int `json:"incidentsResolved" dynamodbav:"incidentsResolved"`
+	HotWaterStops     int `json:"hotWaterStops" dynamodbav:"hotWaterStops"`
+	Deficiencies      int `json:"deficiencies" dynamodbav:"deficiencies"`
+	HeatingIncidents  int `json:"heatingIncidents" dynamodbav:"heatingIncidents"`
+	OtherIncidents    int `json:"otherIncidents" dynamodbav:"otherIncidents"`
+	OverdueIncidents  int `json:"overdueIncidents" dynamodbav:"overdueIncidents"`
 }
 
 type HeatingStation struct {
-	GeoId      int64   `json:"geoId" dynamodbav:"GeoId"`
-	Name       string  `json:"name" dynamodbav:"Name"`
-	Latitude   float64 `json:"latitude" dynamodbav:"Latitude"`
-	Longitude  float64 `json:"longitude" dynamodbav:"Longitude"`
-	LastStatus string  `json:"lastStatus" dynamodbav:"LastStatus"` // working,issue,broken
+	GeoId                int64   `json:"geoId" dynamodbav:"GeoId"`
+	Name                 string  `json:"name" dynamodbav:"Name"`
+	Latitude             float64 `json:"latitude" dynamodbav:"Latitude"`
+	Longitude            float64 `json:"longitude" dynamodbav:"Longitude"`
+	LastStatus           string  `json:"lastStatus" dynamodbav:"LastStatus"` // working,issue,broken
+	LastIncidentType     string  `json:"lastIncidentType" dynamodbav:"LastIncidentType"`
+	LastIncidentText     string  `json:"lastIncidentText" dynamodbav:"LastIncidentText"`
+	LastEstimatedFixDate int64   `json:"lastEstimatedFixDate" dynamodbav:"LastEstimatedFixDate"`
+	// LastStatusTime is the time of the last status row persisted for this station
+	LastStatusTime int64 `json:"lastStatusTime" dynamodbav:"LastStatusTime"`
+	// lifecycle of the station on the map, see DiffSnapshot
+	FirstSeen        int64 `json:"firstSeen" dynamodbav:"FirstSeen"`
+	LastSeen         int64 `json:"lastSeen" dynamodbav:"LastSeen"`
+	LastStatusChange int64 `json:"lastStatusChange" dynamodbav:"LastStatusChange"`
+	Active           bool  `json:"active" dynamodbav:"Active"`
 }
 
 type HeatingStationStatus struct {
@@ -56,6 +80,8 @@ type HeatingStationStatus struct {
 	EstimatedFixDate int64   `json:"estimatedFixDate" dynamodbav:"EstimatedFixDate"`
 	Latitude         float64 `json:"latitude" dynamodbav:"Latitude"`
 	Longitude        float64 `json:"longitude" dynamodbav:"Longitude"`
+	// ExpiresAt is the DynamoDB TTL of the row, 0 keeps it forever
+	ExpiresAt int64 `json:"expiresAt,omitempty" dynamodbav:"ExpiresAt,omitempty"`
 }
 
 func (rss *remoteStreetHeatingStatus) generateLocationId() int64 {
@@ -69,14 +95,34 @@ func (rss *remoteStreetHeatingStatus) generateLocationId() int64 {
 }
 
 func (rss *remoteStreetHeatingStatus) toHeatingStation() HeatingStation {
-	id := rss.generateLocationId()
+	status := rss.toHeatingStationStatus()
+	return status.ToHeatingStation()
+}
+
+// ToHeatingStation returns the station as it is after this status was recorded.
+func (s HeatingStationStatus) ToHeatingStation() HeatingStation {
 	return HeatingStation{
-		GeoId:      id,
-		Name:       rss.Denumire,
-		Latitude:   rss.Latitudine,
-		Longitude:  rss.Longitudine,
-		LastStatus: rss.getEnglishStatus(),
+		GeoId:                s.GeoId,
+		Name:                 s.Name,
+		Latitude:             s.Latitude,
+		Longitude:            s.Longitude,
+		LastStatus:           s.Status,
+		LastIncidentType:     s.IncidentType,
+		LastIncidentText:     s.IncidentText,
+		LastEstimatedFixDate: s.EstimatedFixDate,
+		LastStatusTime:       s.FetchTime,
+	}
+}
+
+// ActiveStations returns the stations still present on the map.
+func ActiveStations(stations []HeatingStation) []HeatingStation {
+	active := make([]HeatingStation, 0, len(stations))
+	for _, station := range stations {
+		if station.Active {
+			active = append(active, station)
+		}
 	}
+	return active
 }
 
 func (rss *remoteStreetHeatingStatus) getEnglishStatus() string {
@@ -0,0 +1,202 @@
+package scrapper
+
+import (
+	"fmt"
+)
+
+// Kinds of the events of an incident timeline.
+const (
+	IncidentEventOpened   = "opened"
+	IncidentEventSeverity = "severity"
+	IncidentEventType     = "type"
+	IncidentEventText     = "text"
+	IncidentEventFixDate  = "fixDate"
+	IncidentEventClosed   = "closed"
+)
+
+// maxTimelineEvents caps the timeline of an incident, the first events are
+// kept as they hold the initial promise.
+const maxTimelineEvents = 500
+
+// Incident is a period during which a station was not working, from the first
+// snapshot showing it with an issue or without hot water to the first snapshot
+// showing it working again.
+type Incident struct {
+	IncidentId  string `json:"incidentId" dynamodbav:"IncidentId"`
+	GeoId       int64  `json:"geoId" dynamodbav:"GeoId"`
+	StationName string `json:"stationName" dynamodbav:"StationName"`
+	StartTime   int64  `json:"startTime" dynamodbav:"StartTime"`
+	// EndTime is 0 while the incident is open
+	EndTime int64 `json:"endTime" dynamodbav:"EndTime"`
+	// PeakSeverity is the worst status of the incident, broken over issue
+	PeakSeverity string `json:"peakSeverity" dynamodbav:"PeakSeverity"`
+	Category     string `json:"category" dynamodbav:"Category"`
+	Type         string `json:"type" dynamodbav:"Type"`
+	// Cause is the last incident text shown by the map
+	Cause            string          `json:"cause" dynamodbav:"Cause"`
+	EstimatedFixDate int64           `json:"estimatedFixDate" dynamodbav:"EstimatedFixDate"`
+	Timeline         []IncidentEvent `json:"timeline" dynamodbav:"Timeline"`
+}
+
+// IncidentEvent is a change of an incident seen in a snapshot.
+type IncidentEvent struct {
+	Time             int64  `json:"time" dynamodbav:"Time"`
+	Kind             string `json:"kind" dynamodbav:"Kind"`
+	Status           string `json:"status" dynamodbav:"Status"`
+	IncidentType     string `json:"incidentType" dynamodbav:"IncidentType"`
+	IncidentText     string `json:"incidentText" dynamodbav:"IncidentText"`
+	EstimatedFixDate int64  `json:"estimatedFixDate" dynamodbav:"EstimatedFixDate"`
+}
+
+// IsOpen tells if the station is still not working.
+func (i Incident) IsOpen() bool {
+	return i.EndTime == 0
+}
+
+// IncidentChanges are the incidents to write after a snapshot.
+type IncidentChanges struct {
+	Incidents []Incident
+	NumOpened int
+	NumClosed int
+	// NumUpdated counts the incidents still open with a new timeline event
+	NumUpdated int
+}
+
+func newIncidentId(geoId, startTime int64) string {
+	return fmt.Sprintf("%d-%d", geoId, startTime)
+}
+
+func severityRank(status string) int {
+	switch status {
+	case "broken":
+		return 2
+	case "issue":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// UpdateIncidents compares a snapshot with the open incidents. It opens an
+// incident for every station not working without one, adds a timeline event
+// for every change of an open incident, and closes the incidents of the
+// stations working again. Open incidents of stations missing from the
+// snapshot are left open, as nothing is known about them.
+func UpdateIncidents(open []Incident, statuses []HeatingStationStatus, fetchTime int64) IncidentChanges {
+	changes := IncidentChanges{Incidents: make([]Incident, 0)}
+
+	openByStation := make(map[int64]Incident, len(open))
+	for _, incident := range open {
+		openByStation[incident.GeoId] = incident
+	}
+
+	seen := make(map[int64]bool, len(statuses))
+	for _, status := range statuses {
+		if seen[status.GeoId] {
+			continue
+		}
+		seen[status.GeoId] = true
+
+		event := IncidentEvent{
+			Time:             fetchTime,
+			Status:           status.Status,
+			IncidentType:     status.IncidentType,
+			IncidentText:     status.IncidentText,
+			EstimatedFixDate: status.EstimatedFixDate,
+		}
+		incident, isOpen := openByStation[status.GeoId]
+		working := status.Status == "working"
+
+		switch {
+		case working && !isOpen:
+			continue
+
+		case working && isOpen:
+			event.Kind = IncidentEventClosed
+			incident.EndTime = fetchTime
+			incident.appendEvent(event)
+			changes.NumClosed++
+
+		case !isOpen:
+			event.Kind = IncidentEventOpened
+			incident = Incident{
+				IncidentId:       newIncidentId(status.GeoId, fetchTime),
+				GeoId:            status.GeoId,
+				StationName:      status.Name,
+				StartTime:        fetchTime,
+				PeakSeverity:     status.Status,
+				Category:         IncidentCategory(status.IncidentType),
+				Type:             status.IncidentType,
+				Cause:            status.IncidentText,
+				EstimatedFixDate: status.EstimatedFixDate,
+			}
+			incident.appendEvent(event)
+			changes.NumOpened++
+
+		default:
+			if !incident.applyStatus(status, event) {
+				continue
+			}
+			changes.NumUpdated++
+		}
+
+		changes.Incidents = append(changes.Incidents, incident)
+	}
+
+	return changes
+}
+
+// applyStatus records the changes of a snapshot on an open incident, one
+// timeline event per changed field. It returns false when nothing changed.
+func (i *Incident) applyStatus(status HeatingStationStatus, event IncidentEvent) bool {
+	changed := false
+	record := func(kind string) {
+		event.Kind = kind
+		i.appendEvent(event)
+		changed = true
+	}
+
+	if severityRank(status.Status) > severityRank(i.PeakSeverity) {
+		i.PeakSeverity = status.Status
+		record(IncidentEventSeverity)
+	} else if status.Status != i.lastStatus() {
+		record(IncidentEventSeverity)
+	}
+	if status.IncidentType != i.Type {
+		i.Type = status.IncidentType
+		i.Category = IncidentCategory(status.IncidentType)
+		record(IncidentEventType)
+	}
+	if status.IncidentText != i.Cause {
+		i.Cause = status.IncidentText
+		record(IncidentEventText)
+	}
+	if status.EstimatedFixDate != i.EstimatedFixDate {
+		i.EstimatedFixDate = status.EstimatedFixDate
+		record(IncidentEventFixDate)
+	}
+	if status.Name != "" && status.Name != i.StationName {
+		i.StationName = status.Name
+		changed = true
+	}
+	return changed
+}
+
+// lastStatus is the status of the last timeline event.
+func (i *Incident) lastStatus() string {
+	if len(i.Timeline) == 0 {
+		return i.PeakSeverity
+	}
+	return i.Timeline[len(i.Timeline)-1].Status
+}
+
+func (i *Incident) appendEvent(event IncidentEvent) {
+	if len(i.Timeline) >= maxTimelineEvents {
+		// the closing event is always kept as the last one
+		if event.Kind != IncidentEventClosed {
+			return
+		}
+		i.Timeline = i.Timeline[:maxTimelineEvents-1]
+	}
+	i.Timeline = append(i.Timeline, event)
+}
@@ -0,0 +1,139 @@
+package scrapper
+
+import (
+	"testing"
+)
+
+func TestUpdateIncidents(t *testing.T) {
+	openIncident := Incident{
+		IncidentId:       "1-100",
+		GeoId:            1,
+		StationName:      "Station1",
+		StartTime:        100,
+		PeakSeverity:     "issue",
+		Category:         IncidentCategory("Avarie"),
+		Type:             "Avarie",
+		Cause:            "Lucrari",
+		EstimatedFixDate: 1000,
+		Timeline: []IncidentEvent{
+			{Time: 100, Kind: IncidentEventOpened, Status: "issue", IncidentType: "Avarie", IncidentText: "Lucrari", EstimatedFixDate: 1000},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		open        []Incident
+		statuses    []HeatingStationStatus
+		wantOpened  int
+		wantClosed  int
+		wantUpdated int
+		// wantKinds are the kinds of the timeline events added to the written incidents, in order
+		wantKinds []string
+		wantEnd   int64
+	}{
+		{
+			name:      "working station without incident",
+			statuses:  []HeatingStationStatus{{GeoId: 1, Name: "Station1", Status: "working"}},
+			wantKinds: []string{},
+		},
+		{
+			name:       "station broken opens an incident",
+			statuses:   []HeatingStationStatus{{GeoId: 1, Name: "Station1", Status: "broken", IncidentType: "Avarie", EstimatedFixDate: 1000}},
+			wantOpened: 1,
+			wantKinds:  []string{IncidentEventOpened},
+		},
+		{
+			name:      "unchanged open incident is not written",
+			open:      []Incident{openIncident},
+			statuses:  []HeatingStationStatus{{GeoId: 1, Name: "Station1", Status: "issue", IncidentType: "Avarie", IncidentText: "Lucrari", EstimatedFixDate: 1000}},
+			wantKinds: []string{},
+		},
+		{
+			name:        "fix date postponed and worse severity",
+			open:        []Incident{openIncident},
+			statuses:    []HeatingStationStatus{{GeoId: 1, Name: "Station1", Status: "broken", IncidentType: "Avarie", IncidentText: "Lucrari", EstimatedFixDate: 2000}},
+			wantUpdated: 1,
+			wantKinds:   []string{IncidentEventSeverity, IncidentEventFixDate},
+		},
+		{
+			name:        "new incident text",
+			open:        []Incident{openIncident},
+			statuses:    []HeatingStationStatus{{GeoId: 1, Name: "Station1", Status: "issue", IncidentType: "Avarie", IncidentText: "Inlocuire conducta", EstimatedFixDate: 1000}},
+			wantUpdated: 1,
+			wantKinds:   []string{IncidentEventText},
+		},
+		{
+			name:       "working station closes its incident",
+			open:       []Incident{openIncident},
+			statuses:   []HeatingStationStatus{{GeoId: 1, Name: "Station1", Status: "working"}},
+			wantClosed: 1,
+			wantKinds:  []string{IncidentEventClosed},
+			wantEnd:    500,
+		},
+		{
+			name:      "missing station keeps its incident open",
+			open:      []Incident{openIncident},
+			statuses:  []HeatingStationStatus{{GeoId: 2, Name: "Station2", Status: "working"}},
+			wantKinds: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the open incidents are copied as UpdateIncidents appends to their timeline
+			open := make([]Incident, 0, len(tt.open))
+			numPrevious := 0
+			for _, incident := range tt.open {
+				incident.Timeline = append([]IncidentEvent(nil), incident.Timeline...)
+				numPrevious += len(incident.Timeline)
+				open = append(open, incident)
+			}
+
+			changes := UpdateIncidents(open, tt.statuses, 500)
+
+			if changes.NumOpened != tt.wantOpened || changes.NumClosed != tt.wantClosed || changes.NumUpdated != tt.wantUpdated {
+				t.Errorf("UpdateIncidents() opened=%d closed=%d updated=%d, want %d %d %d",
+					changes.NumOpened, changes.NumClosed, changes.NumUpdated, tt.wantOpened, tt.wantClosed, tt.wantUpdated)
+			}
+
+			kinds := make([]string, 0)
+			for _, incident := range changes.Incidents {
+				if incident.EndTime != tt.wantEnd {
+					t.Errorf("incident %s EndTime = %d, want %d", incident.IncidentId, incident.EndTime, tt.wantEnd)
+				}
+				start := 0
+				if incident.StartTime == openIncident.StartTime {
+					start = numPrevious
+				}
+				for _, event := range incident.Timeline[start:] {
+					kinds = append(kinds, event.Kind)
+				}
+			}
+			if len(kinds) != len(tt.wantKinds) {
+				t.Fatalf("timeline events = %v, want %v", kinds, tt.wantKinds)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.wantKinds[i] {
+					t.Errorf("timeline events = %v, want %v", kinds, tt.wantKinds)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateIncidentsPeakSeverity(t *testing.T) {
+	changes := UpdateIncidents(nil, []HeatingStationStatus{{GeoId: 1, Status: "broken"}}, 100)
+	changes = UpdateIncidents(changes.Incidents, []HeatingStationStatus{{GeoId: 1, Status: "issue"}}, 200)
+
+	if len(changes.Incidents) != 1 {
+		t.Fatalf("UpdateIncidents() wrote %d incidents, want 1", len(changes.Incidents))
+	}
+	incident := changes.Incidents[0]
+	if incident.PeakSeverity != "broken" {
+		t.Errorf("PeakSeverity = %s, want broken after the station improved", incident.PeakSeverity)
+	}
+	if incident.IncidentId != "1-100" || !incident.IsOpen() {
+		t.Errorf("incident = %+v, want 1-100 still open", incident)
+	}
+}
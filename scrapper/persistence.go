@@ -0,0 +1,117 @@
+package scrapper
+
+import (
+	"time"
+)
+
+// SnapshotOptions controls what DiffSnapshot persists.
+type SnapshotOptions struct {
+	// FetchTime is the time the snapshot was taken at
+	FetchTime int64
+	// Heartbeat is the interval at which an unchanged status is persisted again,
+	// zero persists every status
+	Heartbeat time.Duration
+	// InactiveGracePeriod is how long a station can be missing from the map before
+	// it is marked inactive, zero never marks stations inactive
+	InactiveGracePeriod time.Duration
+}
+
+// SnapshotChanges is what needs to be written to persist a snapshot.
+type SnapshotChanges struct {
+	Stations []HeatingStation
+	Statuses []HeatingStationStatus
+	// NumUnchanged is the number of stations with neither a change nor a heartbeat due
+	NumUnchanged int
+	// NumDeactivated is the number of stations missing for longer than the grace period
+	NumDeactivated int
+	// NumReactivated is the number of inactive stations back on the map
+	NumReactivated int
+}
+
+// DiffSnapshot compares the snapshot statuses with the last stored state of each
+// station. A status row is kept only when the station is new or back on the map, its
+// status changed or its last persisted row is older than the heartbeat interval.
+// A station is kept only when one of its fields differs from the stored one, or
+// when it has been missing from the map for the grace period and gets deactivated.
+// Stations sharing a GeoId are only kept once.
+//
+// LastSeen only moves when the station is written, so in change-only mode it is
+// refreshed at least every heartbeat. Stations stored before the lifecycle was
+// tracked get their FirstSeen and LastStatusChange set to the snapshot time.
+func DiffSnapshot(previous map[int64]HeatingStation, statuses []HeatingStationStatus, opts SnapshotOptions) SnapshotChanges {
+	changes := SnapshotChanges{
+		Stations: make([]HeatingStation, 0, len(statuses)),
+		Statuses: make([]HeatingStationStatus, 0, len(statuses)),
+	}
+	seen := make(map[int64]bool, len(statuses))
+
+	for _, status := range statuses {
+		if seen[status.GeoId] {
+			continue
+		}
+		seen[status.GeoId] = true
+
+		station := status.ToHeatingStation()
+		station.FirstSeen = status.FetchTime
+		station.LastStatusChange = status.FetchTime
+		station.Active = true
+
+		last, exists := previous[status.GeoId]
+		if exists {
+			if last.FirstSeen != 0 {
+				station.FirstSeen = last.FirstSeen
+			}
+			if last.LastStatusChange != 0 && last.LastStatus == status.Status {
+				station.LastStatusChange = last.LastStatusChange
+			}
+			if last.FirstSeen != 0 && !last.Active {
+				changes.NumReactivated++
+			}
+		}
+
+		if !exists || !last.Active || statusChanged(last, status) || heartbeatDue(last, status, opts.Heartbeat) {
+			changes.Statuses = append(changes.Statuses, status)
+		} else {
+			station.LastStatusTime = last.LastStatusTime
+		}
+
+		station.LastSeen = last.LastSeen
+		if !exists || station != last {
+			station.LastSeen = status.FetchTime
+			changes.Stations = append(changes.Stations, station)
+		} else {
+			changes.NumUnchanged++
+		}
+	}
+
+	if opts.InactiveGracePeriod > 0 {
+		for geoId, last := range previous {
+			if seen[geoId] || !last.Active {
+				continue
+			}
+			if time.Duration(opts.FetchTime-last.LastSeen)*time.Second >= opts.InactiveGracePeriod {
+				last.Active = false
+				changes.Stations = append(changes.Stations, last)
+				changes.NumDeactivated++
+			}
+		}
+	}
+
+	return changes
+}
+
+func statusChanged(last HeatingStation, status HeatingStationStatus) bool {
+	return last.Name != status.Name ||
+		last.LastStatus != status.Status ||
+		last.LastIncidentType != status.IncidentType ||
+		last.LastIncidentText != status.IncidentText ||
+		last.LastEstimatedFixDate != status.EstimatedFixDate
+}
+
+func heartbeatDue(last HeatingStation, status HeatingStationStatus, heartbeat time.Duration) bool {
+	// stations written before change-only persistence have no status time
+	if last.LastStatusTime == 0 {
+		return true
+	}
+	return time.Duration(status.FetchTime-last.LastStatusTime)*time.Second >= heartbeat
+}
@@ -0,0 +1,279 @@
+package scrapper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshot(t *testing.T) {
+	const (
+		heartbeat = 6 * time.Hour
+		grace     = 24 * time.Hour
+	)
+	now := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC).Unix()
+
+	working := HeatingStationStatus{GeoId: 1, Name: "Station1", Status: "working", IncidentType: "-", FetchTime: now}
+	broken := HeatingStationStatus{GeoId: 2, Name: "Station2", Status: "broken", IncidentType: "Oprire ACC", IncidentText: "avarie", EstimatedFixDate: now + 3600, FetchTime: now}
+
+	storedAt := func(status HeatingStationStatus, at int64) HeatingStation {
+		station := status.ToHeatingStation()
+		station.LastStatusTime = at
+		station.FirstSeen = at
+		station.LastSeen = at
+		station.LastStatusChange = at
+		station.Active = true
+		return station
+	}
+	inactive := func(station HeatingStation) HeatingStation {
+		station.Active = false
+		return station
+	}
+
+	tests := []struct {
+		name          string
+		previous      map[int64]HeatingStation
+		statuses      []HeatingStationStatus
+		wantStations  int
+		wantStatuses  int
+		wantUnchanged int
+		// lifecycle of the first written station
+		wantFirstSeen        int64
+		wantLastStatusChange int64
+		wantActive           bool
+		wantDeactivated      int
+		wantReactivated      int
+	}{
+		{
+			name:                 "no previous state persists everything",
+			previous:             map[int64]HeatingStation{},
+			statuses:             []HeatingStationStatus{working, broken},
+			wantStations:         2,
+			wantStatuses:         2,
+			wantFirstSeen:        now,
+			wantLastStatusChange: now,
+			wantActive:           true,
+		},
+		{
+			name: "unchanged stations are skipped",
+			previous: map[int64]HeatingStation{
+				1: storedAt(working, now-1800),
+				2: storedAt(broken, now-1800),
+			},
+			statuses:      []HeatingStationStatus{working, broken},
+			wantUnchanged: 2,
+		},
+		{
+			name: "status change is persisted",
+			previous: map[int64]HeatingStation{
+				1: storedAt(working, now-1800),
+				2: storedAt(HeatingStationStatus{GeoId: 2, Name: "Station2", Status: "working", IncidentType: "-"}, now-1800),
+			},
+			statuses:             []HeatingStationStatus{working, broken},
+			wantStations:         1,
+			wantStatuses:         1,
+			wantUnchanged:        1,
+			wantFirstSeen:        now - 1800,
+			wantLastStatusChange: now,
+			wantActive:           true,
+		},
+		{
+			name: "estimated fix date revision is persisted",
+			previous: map[int64]HeatingStation{
+				2: storedAt(HeatingStationStatus{GeoId: 2, Name: "Station2", Status: "broken", IncidentType: "Oprire ACC", IncidentText: "avarie", EstimatedFixDate: now}, now-1800),
+			},
+			statuses:             []HeatingStationStatus{broken},
+			wantStations:         1,
+			wantStatuses:         1,
+			wantFirstSeen:        now - 1800,
+			wantLastStatusChange: now - 1800,
+			wantActive:           true,
+		},
+		{
+			name: "heartbeat is due",
+			previous: map[int64]HeatingStation{
+				1: storedAt(working, now-int64(heartbeat/time.Second)),
+				2: storedAt(broken, now-1800),
+			},
+			statuses:      []HeatingStationStatus{working, broken},
+			wantStations:  1,
+			wantStatuses:  1,
+			wantUnchanged: 1,
+		},
+		{
+			name: "station stored before change-only mode",
+			previous: map[int64]HeatingStation{
+				1: storedAt(working, 0),
+			},
+			statuses:     []HeatingStationStatus{working},
+			wantStations: 1,
+			wantStatuses: 1,
+		},
+		{
+			name: "station stored before lifecycle tracking",
+			previous: map[int64]HeatingStation{
+				1: working.ToHeatingStation(),
+			},
+			statuses:             []HeatingStationStatus{working},
+			wantStations:         1,
+			wantStatuses:         1,
+			wantFirstSeen:        now,
+			wantLastStatusChange: now,
+			wantActive:           true,
+		},
+		{
+			name: "missing station within grace period is kept active",
+			previous: map[int64]HeatingStation{
+				1: storedAt(working, now-1800),
+				2: storedAt(broken, now-1800),
+			},
+			statuses:      []HeatingStationStatus{working},
+			wantUnchanged: 1,
+		},
+		{
+			name: "missing station after grace period is deactivated",
+			previous: map[int64]HeatingStation{
+				1: storedAt(working, now-1800),
+				2: storedAt(broken, now-int64(grace/time.Second)),
+			},
+			statuses:             []HeatingStationStatus{working},
+			wantStations:         1,
+			wantUnchanged:        1,
+			wantFirstSeen:        now - int64(grace/time.Second),
+			wantLastStatusChange: now - int64(grace/time.Second),
+			wantDeactivated:      1,
+		},
+		{
+			name: "inactive station is not deactivated again",
+			previous: map[int64]HeatingStation{
+				2: inactive(storedAt(broken, now-2*int64(grace/time.Second))),
+			},
+			statuses: []HeatingStationStatus{},
+		},
+		{
+			name: "inactive station back on the map is reactivated",
+			previous: map[int64]HeatingStation{
+				2: inactive(storedAt(broken, now-2*int64(grace/time.Second))),
+			},
+			statuses:             []HeatingStationStatus{broken},
+			wantStations:         1,
+			wantStatuses:         1,
+			wantFirstSeen:        now - 2*int64(grace/time.Second),
+			wantLastStatusChange: now - 2*int64(grace/time.Second),
+			wantActive:           true,
+			wantReactivated:      1,
+		},
+		{
+			name:         "duplicated GeoId is kept once",
+			previous:     map[int64]HeatingStation{},
+			statuses:     []HeatingStationStatus{working, working},
+			wantStations: 1,
+			wantStatuses: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := DiffSnapshot(tt.previous, tt.statuses, SnapshotOptions{
+				FetchTime:           now,
+				Heartbeat:           heartbeat,
+				InactiveGracePeriod: grace,
+			})
+			if len(changes.Stations) != tt.wantStations {
+				t.Errorf("stations = %d, want %d", len(changes.Stations), tt.wantStations)
+			}
+			if len(changes.Statuses) != tt.wantStatuses {
+				t.Errorf("statuses = %d, want %d", len(changes.Statuses), tt.wantStatuses)
+			}
+			if changes.NumUnchanged != tt.wantUnchanged {
+				t.Errorf("unchanged = %d, want %d", changes.NumUnchanged, tt.wantUnchanged)
+			}
+			if changes.NumDeactivated != tt.wantDeactivated {
+				t.Errorf("deactivated = %d, want %d", changes.NumDeactivated, tt.wantDeactivated)
+			}
+			if changes.NumReactivated != tt.wantReactivated {
+				t.Errorf("reactivated = %d, want %d", changes.NumReactivated, tt.wantReactivated)
+			}
+			if tt.wantFirstSeen != 0 && len(changes.Stations) > 0 {
+				station := changes.Stations[0]
+				if station.FirstSeen != tt.wantFirstSeen || station.LastStatusChange != tt.wantLastStatusChange || station.Active != tt.wantActive {
+					t.Errorf("station lifecycle = first seen %d, last status change %d, active %v, want %d, %d, %v",
+						station.FirstSeen, station.LastStatusChange, station.Active,
+						tt.wantFirstSeen, tt.wantLastStatusChange, tt.wantActive)
+				}
+				if station.Active && station.LastSeen != now {
+					t.Errorf("written active station last seen = %d, want %d", station.LastSeen, now)
+				}
+			}
+		})
+	}
+}
+
+// The statistics of a change-only dataset must match the ones of the full dataset
+// it was persisted from.
+func TestComputeSparseIncidentStatisticsMatchesFullDataset(t *testing.T) {
+	const (
+		heartbeat   = 2 * time.Hour
+		runInterval = int64(1800)
+	)
+	start := time.Now().Add(-48 * time.Hour).Unix()
+
+	// station 1 breaks twice, station 2 always works, station 3 is broken at the end
+	statusAt := func(geoId int64, run int64) string {
+		switch geoId {
+		case 1:
+			if (run >= 10 && run < 15) || (run >= 40 && run < 52) {
+				return "broken"
+			}
+		case 3:
+			if run >= 80 {
+				return "issue"
+			}
+		}
+		return "working"
+	}
+
+	full := make([]HeatingStationStatus, 0, 3*96)
+	sparse := make([]HeatingStationStatus, 0, 3*96)
+	previous := map[int64]HeatingStation{}
+	for run := int64(0); run < 96; run++ {
+		snapshot := make([]HeatingStationStatus, 0, 3)
+		for geoId := int64(1); geoId <= 3; geoId++ {
+			snapshot = append(snapshot, HeatingStationStatus{
+				GeoId:     geoId,
+				Name:      "Station",
+				Status:    statusAt(geoId, run),
+				FetchTime: start + run*runInterval,
+			})
+		}
+		full = append(full, snapshot...)
+
+		changes := DiffSnapshot(previous, snapshot, SnapshotOptions{
+			FetchTime: start + run*runInterval,
+			Heartbeat: heartbeat,
+		})
+		sparse = append(sparse, changes.Statuses...)
+		for _, station := range changes.Stations {
+			previous[station.GeoId] = station
+		}
+	}
+
+	if len(sparse) >= len(full) {
+		t.Fatalf("sparse dataset has %d rows, expected less than %d", len(sparse), len(full))
+	}
+
+	fullStats := ComputeIncidentStatistics(full)
+	sparseStats := ComputeSparseIncidentStatistics(sparse, heartbeat, full[len(full)-1].FetchTime)
+
+	if len(fullStats) != len(sparseStats) {
+		t.Fatalf("sparse stats have %d stations, want %d", len(sparseStats), len(fullStats))
+	}
+	for i := range fullStats {
+		f, s := fullStats[i], sparseStats[i]
+		if f.GeoId != s.GeoId ||
+			abs(float64(f.AvgMonthlyIncidentTimeHours-s.AvgMonthlyIncidentTimeHours)) > 0.01 ||
+			abs(float64(f.AvgIncidentTimeHours-s.AvgIncidentTimeHours)) > 0.01 ||
+			abs(float64(f.MaxIncidentTimeHours-s.MaxIncidentTimeHours)) > 0.01 {
+			t.Errorf("sparse stats %+v differ from full stats %+v", s, f)
+		}
+	}
+}
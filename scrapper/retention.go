@@ -0,0 +1,38 @@
+package scrapper
+
+import (
+	"time"
+)
+
+// ApplyStatusRetention sets the expiry of status rows, a zero retention keeps them forever.
+func ApplyStatusRetention(statuses []HeatingStationStatus, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	retentionSeconds := int64(retention / time.Second)
+	for i := range statuses {
+		statuses[i].ExpiresAt = statuses[i].FetchTime + retentionSeconds
+	}
+}
+
+type statusKey struct {
+	geoId     int64
+	fetchTime int64
+}
+
+// FindUnarchivedStatuses returns the rows missing from the archive, rows are
+// matched on their table key.
+func FindUnarchivedStatuses(rows, archived []HeatingStationStatus) []HeatingStationStatus {
+	archivedKeys := make(map[statusKey]bool, len(archived))
+	for _, status := range archived {
+		archivedKeys[statusKey{geoId: status.GeoId, fetchTime: status.FetchTime}] = true
+	}
+
+	unarchived := make([]HeatingStationStatus, 0)
+	for _, row := range rows {
+		if !archivedKeys[statusKey{geoId: row.GeoId, fetchTime: row.FetchTime}] {
+			unarchived = append(unarchived, row)
+		}
+	}
+	return unarchived
+}
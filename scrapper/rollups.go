@@ -0,0 +1,189 @@
+package scrapper
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RollupDateLayout is the layout of the rollup dates, days of the Bucharest calendar.
+const RollupDateLayout = "2006-01-02"
+
+// StationDayRollup summarizes the statuses of a station over a calendar day,
+// it replaces the raw status rows once they expired.
+type StationDayRollup struct {
+	GeoId int64  `json:"geoId" dynamodbav:"GeoId"`
+	Date  string `json:"date" dynamodbav:"Date"`
+	Name  string `json:"name" dynamodbav:"Name"`
+	// ObservedHours is the part of the day the station status was known
+	ObservedHours        float64 `json:"observedHours" dynamodbav:"ObservedHours"`
+	HoursWithIssues      float64 `json:"hoursWithIssues" dynamodbav:"HoursWithIssues"`
+	HoursWithoutHotWater float64 `json:"hoursWithoutHotWater" dynamodbav:"HoursWithoutHotWater"`
+	// NumIncidentsOpened counts the changes from working to a failing status
+	NumIncidentsOpened int    `json:"numIncidentsOpened" dynamodbav:"NumIncidentsOpened"`
+	WorstStatus        string `json:"worstStatus" dynamodbav:"WorstStatus"`
+	// FirstChange and LastChange are the times of the status changes of the day, 0 without any
+	FirstChange int64 `json:"firstChange" dynamodbav:"FirstChange"`
+	LastChange  int64 `json:"lastChange" dynamodbav:"LastChange"`
+	NumSamples  int   `json:"numSamples" dynamodbav:"NumSamples"`
+}
+
+// RollupOptions tell how long the statuses hold and in which calendar days are cut.
+type RollupOptions struct {
+	Location *time.Location
+	// MaxSampleDuration is how long a status holds without a newer row, the
+	// heartbeat of a change-only history
+	MaxSampleDuration time.Duration
+	// ObservedUntil is the time of the last snapshot, the last status of a
+	// station holds until then at most
+	ObservedUntil int64
+}
+
+// ComputeStationDayRollups returns the rollups of every station and day the
+// statuses cover, sorted by day then station. A status holds until the next
+// status of the station, and is split at the midnights of the location, so
+// days with a daylight saving change are 23 or 25 hours long.
+func ComputeStationDayRollups(statuses []HeatingStationStatus, opts RollupOptions) []StationDayRollup {
+	byStation := make(map[int64][]HeatingStationStatus)
+	for _, status := range statuses {
+		byStation[status.GeoId] = append(byStation[status.GeoId], status)
+	}
+
+	rollups := make(map[rollupKey]*StationDayRollup)
+	for geoId, stationStatuses := range byStation {
+		sort.SliceStable(stationStatuses, func(i, j int) bool {
+			return stationStatuses[i].FetchTime < stationStatuses[j].FetchTime
+		})
+		addStationRollups(rollups, geoId, stationStatuses, opts)
+	}
+
+	result := make([]StationDayRollup, 0, len(rollups))
+	for _, rollup := range rollups {
+		result = append(result, *rollup)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].GeoId < result[j].GeoId
+	})
+	return result
+}
+
+type rollupKey struct {
+	geoId int64
+	date  string
+}
+
+func addStationRollups(rollups map[rollupKey]*StationDayRollup, geoId int64, statuses []HeatingStationStatus, opts RollupOptions) {
+	maxSampleSeconds := int64(opts.MaxSampleDuration / time.Second)
+
+	dayRollup := func(t int64, name string) *StationDayRollup {
+		date := time.Unix(t, 0).In(opts.Location).Format(RollupDateLayout)
+		key := rollupKey{geoId: geoId, date: date}
+		rollup, exists := rollups[key]
+		if !exists {
+			rollup = &StationDayRollup{GeoId: geoId, Date: date, WorstStatus: "working"}
+			rollups[key] = rollup
+		}
+		if name != "" {
+			rollup.Name = name
+		}
+		return rollup
+	}
+
+	// rewritten rows can be archived twice
+	unique := make([]HeatingStationStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if len(unique) > 0 && unique[len(unique)-1].FetchTime == status.FetchTime {
+			unique[len(unique)-1] = status
+			continue
+		}
+		unique = append(unique, status)
+	}
+	statuses = unique
+
+	previousStatus := ""
+	for i, status := range statuses {
+		rollup := dayRollup(status.FetchTime, status.Name)
+		rollup.NumSamples++
+		if severityRank(status.Status) > severityRank(rollup.WorstStatus) {
+			rollup.WorstStatus = status.Status
+		}
+		if previousStatus != "" && status.Status != previousStatus {
+			if rollup.FirstChange == 0 {
+				rollup.FirstChange = status.FetchTime
+			}
+			rollup.LastChange = status.FetchTime
+			if previousStatus == "working" {
+				rollup.NumIncidentsOpened++
+			}
+		}
+		previousStatus = status.Status
+
+		end := status.FetchTime
+		if i+1 < len(statuses) {
+			end = statuses[i+1].FetchTime
+		} else if opts.ObservedUntil > end {
+			end = opts.ObservedUntil
+		}
+		if maxSampleSeconds > 0 {
+			end = min(end, status.FetchTime+maxSampleSeconds)
+		}
+
+		// the status is split at every midnight it spans
+		for start := status.FetchTime; start < end; {
+			local := time.Unix(start, 0).In(opts.Location)
+			nextMidnight := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, opts.Location).Unix()
+			partEnd := min(end, nextMidnight)
+
+			day := dayRollup(start, status.Name)
+			hours := float64(partEnd-start) / 3600.0
+			day.ObservedHours += hours
+			switch status.Status {
+			case "issue":
+				day.HoursWithIssues += hours
+			case "broken":
+				day.HoursWithoutHotWater += hours
+			}
+			if severityRank(status.Status) > severityRank(day.WorstStatus) {
+				day.WorstStatus = status.Status
+			}
+			start = partEnd
+		}
+	}
+}
+
+// RollupDays returns the dates of the complete days before now, the most recent
+// first.
+func RollupDays(now time.Time, numDays int, location *time.Location) []string {
+	local := now.In(location)
+	dates := make([]string, 0, numDays)
+	for i := 1; i <= numDays; i++ {
+		dates = append(dates, time.Date(local.Year(), local.Month(), local.Day()-i, 0, 0, 0, 0, location).Format(RollupDateLayout))
+	}
+	return dates
+}
+
+// RollupDateRange returns the dates from first to last included, the most recent
+// first like RollupDays.
+func RollupDateRange(first, last string) ([]string, error) {
+	firstDay, err := time.Parse(RollupDateLayout, first)
+	if err != nil {
+		return nil, fmt.Errorf("invalid first date: %w", err)
+	}
+	lastDay, err := time.Parse(RollupDateLayout, last)
+	if err != nil {
+		return nil, fmt.Errorf("invalid last date: %w", err)
+	}
+	if lastDay.Before(firstDay) {
+		return nil, fmt.Errorf("last date %s is before first date %s", last, first)
+	}
+
+	dates := make([]string, 0)
+	// dates without a location have no daylight saving change, every day lasts 24 hours
+	for day := lastDay; !day.Before(firstDay); day = day.AddDate(0, 0, -1) {
+		dates = append(dates, day.Format(RollupDateLayout))
+	}
+	return dates, nil
+}
@@ -0,0 +1,187 @@
+package scrapper
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputeStationDayRollups(t *testing.T) {
+	bucharest, err := time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	at := func(year int, month time.Month, day, hour int) int64 {
+		return time.Date(year, month, day, hour, 0, 0, 0, bucharest).Unix()
+	}
+
+	tests := []struct {
+		name     string
+		statuses []HeatingStationStatus
+		opts     RollupOptions
+		want     []StationDayRollup
+	}{
+		{
+			name: "status split at midnight",
+			statuses: []HeatingStationStatus{
+				{GeoId: 1, Name: "Station1", Status: "working", FetchTime: at(2025, time.January, 10, 20)},
+				{GeoId: 1, Name: "Station1", Status: "broken", FetchTime: at(2025, time.January, 10, 22)},
+			},
+			opts: RollupOptions{Location: bucharest, ObservedUntil: at(2025, time.January, 11, 4)},
+			want: []StationDayRollup{
+				{GeoId: 1, Date: "2025-01-10", Name: "Station1", ObservedHours: 4, HoursWithoutHotWater: 2, NumIncidentsOpened: 1,
+					WorstStatus: "broken", FirstChange: at(2025, time.January, 10, 22), LastChange: at(2025, time.January, 10, 22), NumSamples: 2},
+				{GeoId: 1, Date: "2025-01-11", Name: "Station1", ObservedHours: 4, HoursWithoutHotWater: 4, WorstStatus: "broken"},
+			},
+		},
+		{
+			name: "spring forward day lasts 23 hours",
+			statuses: []HeatingStationStatus{
+				{GeoId: 1, Status: "issue", FetchTime: at(2025, time.March, 30, 0)},
+			},
+			opts: RollupOptions{Location: bucharest, ObservedUntil: at(2025, time.March, 31, 0)},
+			want: []StationDayRollup{
+				{GeoId: 1, Date: "2025-03-30", ObservedHours: 23, HoursWithIssues: 23, WorstStatus: "issue", NumSamples: 1},
+			},
+		},
+		{
+			name: "fall back day lasts 25 hours",
+			statuses: []HeatingStationStatus{
+				{GeoId: 1, Status: "working", FetchTime: at(2025, time.October, 26, 0)},
+			},
+			opts: RollupOptions{Location: bucharest, ObservedUntil: at(2025, time.October, 27, 0)},
+			want: []StationDayRollup{
+				{GeoId: 1, Date: "2025-10-26", ObservedHours: 25, WorstStatus: "working", NumSamples: 1},
+			},
+		},
+		{
+			name: "status spanning the fall back night",
+			statuses: []HeatingStationStatus{
+				{GeoId: 1, Status: "broken", FetchTime: at(2025, time.October, 25, 20)},
+			},
+			opts: RollupOptions{Location: bucharest, ObservedUntil: at(2025, time.October, 26, 4)},
+			want: []StationDayRollup{
+				{GeoId: 1, Date: "2025-10-25", ObservedHours: 4, HoursWithoutHotWater: 4, WorstStatus: "broken", NumSamples: 1},
+				{GeoId: 1, Date: "2025-10-26", ObservedHours: 5, HoursWithoutHotWater: 5, WorstStatus: "broken"},
+			},
+		},
+		{
+			name: "sample duration caps a stale status and duplicates are ignored",
+			statuses: []HeatingStationStatus{
+				{GeoId: 2, Status: "working", FetchTime: at(2025, time.January, 10, 0)},
+				{GeoId: 2, Status: "working", FetchTime: at(2025, time.January, 10, 0)},
+				{GeoId: 2, Status: "issue", FetchTime: at(2025, time.January, 10, 12)},
+				{GeoId: 2, Status: "working", FetchTime: at(2025, time.January, 10, 13)},
+			},
+			opts: RollupOptions{Location: bucharest, MaxSampleDuration: 6 * time.Hour, ObservedUntil: at(2025, time.January, 10, 14)},
+			want: []StationDayRollup{
+				{GeoId: 2, Date: "2025-01-10", ObservedHours: 8, HoursWithIssues: 1, NumIncidentsOpened: 1, WorstStatus: "issue",
+					FirstChange: at(2025, time.January, 10, 12), LastChange: at(2025, time.January, 10, 13), NumSamples: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeStationDayRollups(tt.statuses, tt.opts)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ComputeStationDayRollups() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				hoursMatch := math.Abs(g.ObservedHours-w.ObservedHours) < 1e-9 &&
+					math.Abs(g.HoursWithIssues-w.HoursWithIssues) < 1e-9 &&
+					math.Abs(g.HoursWithoutHotWater-w.HoursWithoutHotWater) < 1e-9
+				g.ObservedHours, g.HoursWithIssues, g.HoursWithoutHotWater = 0, 0, 0
+				w.ObservedHours, w.HoursWithIssues, w.HoursWithoutHotWater = 0, 0, 0
+				if !hoursMatch || g != w {
+					t.Errorf("rollup %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRollupDays(t *testing.T) {
+	bucharest, err := time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+
+	// 2 AM UTC is already the next day in Bucharest
+	now := time.Date(2025, time.March, 31, 23, 30, 0, 0, time.UTC)
+	got := RollupDays(now, 3, bucharest)
+	want := []string{"2025-03-31", "2025-03-30", "2025-03-29"}
+	if len(got) != len(want) {
+		t.Fatalf("RollupDays() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("RollupDays() = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestApplyStatusRetention(t *testing.T) {
+	statuses := []HeatingStationStatus{{GeoId: 1, FetchTime: 100}, {GeoId: 2, FetchTime: 200}}
+
+	ApplyStatusRetention(statuses, 0)
+	if statuses[0].ExpiresAt != 0 {
+		t.Errorf("ApplyStatusRetention() without retention set ExpiresAt = %d", statuses[0].ExpiresAt)
+	}
+
+	ApplyStatusRetention(statuses, time.Hour)
+	if statuses[0].ExpiresAt != 3700 || statuses[1].ExpiresAt != 3800 {
+		t.Errorf("ApplyStatusRetention() = %+v", statuses)
+	}
+}
+
+func TestFindUnarchivedStatuses(t *testing.T) {
+	rows := []HeatingStationStatus{
+		{GeoId: 1, FetchTime: 100},
+		{GeoId: 1, FetchTime: 200},
+		{GeoId: 2, FetchTime: 100},
+	}
+	archived := []HeatingStationStatus{
+		{GeoId: 1, FetchTime: 100},
+		{GeoId: 2, FetchTime: 200},
+	}
+
+	got := FindUnarchivedStatuses(rows, archived)
+	if len(got) != 2 || got[0].FetchTime != 200 || got[1].GeoId != 2 {
+		t.Errorf("FindUnarchivedStatuses() = %+v", got)
+	}
+}
+
+func TestRollupDateRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   string
+		last    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "single day", first: "2025-03-30", last: "2025-03-30", want: []string{"2025-03-30"}},
+		{name: "across a month and daylight saving", first: "2025-03-29", last: "2025-04-01", want: []string{"2025-04-01", "2025-03-31", "2025-03-30", "2025-03-29"}},
+		{name: "reversed range", first: "2025-04-01", last: "2025-03-29", wantErr: true},
+		{name: "invalid date", first: "2025-02-30", last: "2025-03-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RollupDateRange(tt.first, tt.last)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RollupDateRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("RollupDateRange() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("RollupDateRange() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package scrapper
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,10 +15,25 @@ import (
 	"time"
 )
 
+// ErrUnexpectedHTTPStatus is returned when the map page answers with another status than 200.
+var ErrUnexpectedHTTPStatus = errors.New("unexpected http status")
+
 type TermoficareScrapper struct {
 	httpClient *http.Client
 	rawData    []remoteStreetHeatingStatus
+	rawPage    []byte
 	fetchTime  time.Time
+	fetchInfo  FetchInfo
+}
+
+// FetchInfo describes the download of the map page by the last PullData call.
+type FetchInfo struct {
+	Latency time.Duration
+	// HTTPStatus is 0 when no response was received
+	HTTPStatus int
+	// PageHash is the hex SHA-256 of the page, empty when it was not downloaded
+	PageHash string
+	PageSize int
 }
 
 func NewTermoficareScrapper(proxyUrl string) (*TermoficareScrapper, error) {
@@ -40,26 +57,65 @@ func NewTermoficareScrapper(proxyUrl string) (*TermoficareScrapper, error) {
 	}, nil
 }
 
-func (t *TermoficareScrapper) PullData() (err error) {
-	t.fetchTime = time.Now().UTC()
+func (t *TermoficareScrapper) PullData() error {
+	return t.PullDataAt(time.Now())
+}
+
+// PullDataAt downloads the map page like PullData, but records the snapshot
+// at snapshotTime instead of the download time. A run retried for the same
+// schedule slot thus writes its rows under the same keys.
+func (t *TermoficareScrapper) PullDataAt(snapshotTime time.Time) (err error) {
+	t.fetchTime = snapshotTime.UTC()
+	t.rawPage = nil
+	t.fetchInfo = FetchInfo{}
 	t.rawData, err = t.getStreetHeatingStatuses()
 	return err
 }
 
+// RawPage returns the map page downloaded by the last PullData call, it is kept
+// even when the page could not be parsed.
+func (t *TermoficareScrapper) RawPage() []byte {
+	return t.rawPage
+}
+
+// FetchTime returns the snapshot time of the last PullData call.
+func (t *TermoficareScrapper) FetchTime() time.Time {
+	return t.fetchTime
+}
+
+// FetchInfo returns the download metadata of the last PullData call, it is
+// filled as far as the download went even when PullData failed.
+func (t *TermoficareScrapper) FetchInfo() FetchInfo {
+	return t.fetchInfo
+}
+
 func (t *TermoficareScrapper) getStreetHeatingStatuses() ([]remoteStreetHeatingStatus, error) {
 
 	const hartaUrl = "https://www.cmteb.ro/harta_stare_sistem_termoficare_bucuresti.php"
 
+	start := time.Now()
 	webpageContent, err := http.Get(hartaUrl)
 	if err != nil {
+		t.fetchInfo.Latency = time.Since(start)
 		return nil, err
 	}
 	defer webpageContent.Body.Close()
+	t.fetchInfo.HTTPStatus = webpageContent.StatusCode
 
 	body, err := io.ReadAll(webpageContent.Body)
+	t.fetchInfo.Latency = time.Since(start)
 	if err != nil {
 		return nil, err
 	}
+	t.rawPage = body
+
+	hash := sha256.Sum256(body)
+	t.fetchInfo.PageHash = hex.EncodeToString(hash[:])
+	t.fetchInfo.PageSize = len(body)
+
+	if webpageContent.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedHTTPStatus, webpageContent.StatusCode)
+	}
 
 	return extractStreetStatusesFromPage(string(body), t.fetchTime)
 }
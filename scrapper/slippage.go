@@ -0,0 +1,202 @@
+package scrapper
+
+import (
+	"sort"
+)
+
+// Scopes of the fix date reliability rows, a cause row has the incident
+// category appended to its scope.
+const (
+	ReliabilityScopeCity  = "city"
+	ReliabilityScopeCause = "cause#"
+)
+
+// FixDateRevision is an estimated fix date shown for an incident, in the order
+// the map showed them.
+type FixDateRevision struct {
+	Time             int64 `json:"time"`
+	EstimatedFixDate int64 `json:"estimatedFixDate"`
+}
+
+// FixDateRevisions returns every estimated fix date the incident was given,
+// the first one is the initial promise. Removed fix dates are left out.
+func (i Incident) FixDateRevisions() []FixDateRevision {
+	revisions := make([]FixDateRevision, 0)
+	for _, event := range i.Timeline {
+		if event.EstimatedFixDate == 0 {
+			continue
+		}
+		if len(revisions) > 0 && revisions[len(revisions)-1].EstimatedFixDate == event.EstimatedFixDate {
+			continue
+		}
+		revisions = append(revisions, FixDateRevision{Time: event.Time, EstimatedFixDate: event.EstimatedFixDate})
+	}
+	return revisions
+}
+
+// FixDateSlippage sums how the estimated fix dates of incidents held up. Only
+// incidents given at least one fix date are counted.
+type FixDateSlippage struct {
+	NumIncidents int
+	// NumPostponements counts the revisions moving the fix date later
+	NumPostponements int
+	// SlippageSeconds is the sum of the postponements, a fix date moved
+	// earlier does not make up for one moved later
+	SlippageSeconds int64
+	// NumResolved counts the closed incidents, the only ones whose promise
+	// can be judged
+	NumResolved int
+	// NumBeatFirstPromise counts the closed incidents resolved before their first fix date
+	NumBeatFirstPromise int
+}
+
+// IncidentSlippage returns the slippage of a single incident.
+func IncidentSlippage(incident Incident) FixDateSlippage {
+	revisions := incident.FixDateRevisions()
+	if len(revisions) == 0 {
+		return FixDateSlippage{}
+	}
+
+	slippage := FixDateSlippage{NumIncidents: 1}
+	for i := 1; i < len(revisions); i++ {
+		if shift := revisions[i].EstimatedFixDate - revisions[i-1].EstimatedFixDate; shift > 0 {
+			slippage.NumPostponements++
+			slippage.SlippageSeconds += shift
+		}
+	}
+	if !incident.IsOpen() {
+		slippage.NumResolved = 1
+		if incident.EndTime <= revisions[0].EstimatedFixDate {
+			slippage.NumBeatFirstPromise = 1
+		}
+	}
+	return slippage
+}
+
+func (s *FixDateSlippage) Add(other FixDateSlippage) {
+	s.NumIncidents += other.NumIncidents
+	s.NumPostponements += other.NumPostponements
+	s.SlippageSeconds += other.SlippageSeconds
+	s.NumResolved += other.NumResolved
+	s.NumBeatFirstPromise += other.NumBeatFirstPromise
+}
+
+func (s FixDateSlippage) TotalSlippageHours() float64 {
+	return float64(s.SlippageSeconds) / 3600.0
+}
+
+func (s FixDateSlippage) AvgPostponements() float64 {
+	if s.NumIncidents == 0 {
+		return 0
+	}
+	return float64(s.NumPostponements) / float64(s.NumIncidents)
+}
+
+func (s FixDateSlippage) AvgSlippageHours() float64 {
+	if s.NumIncidents == 0 {
+		return 0
+	}
+	return s.TotalSlippageHours() / float64(s.NumIncidents)
+}
+
+// BeatFirstPromiseRate is the share of resolved incidents fixed before their
+// first fix date, 0 without any resolved incident.
+func (s FixDateSlippage) BeatFirstPromiseRate() float64 {
+	if s.NumResolved == 0 {
+		return 0
+	}
+	return float64(s.NumBeatFirstPromise) / float64(s.NumResolved)
+}
+
+// FixDateReliability is the slippage of the incidents city-wide, per cause and per station.
+type FixDateReliability struct {
+	City      FixDateSlippage
+	ByCause   map[string]FixDateSlippage
+	ByStation map[int64]FixDateSlippage
+}
+
+// ComputeFixDateReliability sums the slippage of incidents, their cause is the
+// incident category.
+func ComputeFixDateReliability(incidents []Incident) FixDateReliability {
+	reliability := FixDateReliability{
+		ByCause:   make(map[string]FixDateSlippage),
+		ByStation: make(map[int64]FixDateSlippage),
+	}
+
+	for _, incident := range incidents {
+		slippage := IncidentSlippage(incident)
+		if slippage.NumIncidents == 0 {
+			continue
+		}
+		reliability.City.Add(slippage)
+
+		cause := reliability.ByCause[incident.Category]
+		cause.Add(slippage)
+		reliability.ByCause[incident.Category] = cause
+
+		station := reliability.ByStation[incident.GeoId]
+		station.Add(slippage)
+		reliability.ByStation[incident.GeoId] = station
+	}
+
+	return reliability
+}
+
+// FixDateReliabilityDbRow is the city-wide or per cause slippage of a city.
+type FixDateReliabilityDbRow struct {
+	City string `json:"city" dynamodbav:"City"`
+	// Scope is ReliabilityScopeCity, or ReliabilityScopeCause followed by the cause
+	Scope                string  `json:"scope" dynamodbav:"Scope"`
+	Cause                string  `json:"cause,omitempty" dynamodbav:"Cause,omitempty"`
+	NumIncidents         int     `json:"numIncidents" dynamodbav:"NumIncidents"`
+	NumPostponements     int     `json:"numPostponements" dynamodbav:"NumPostponements"`
+	AvgPostponements     float64 `json:"avgPostponements" dynamodbav:"AvgPostponements"`
+	TotalSlippageHours   float64 `json:"totalSlippageHours" dynamodbav:"TotalSlippageHours"`
+	AvgSlippageHours     float64 `json:"avgSlippageHours" dynamodbav:"AvgSlippageHours"`
+	NumResolved          int     `json:"numResolved" dynamodbav:"NumResolved"`
+	NumBeatFirstPromise  int     `json:"numBeatFirstPromise" dynamodbav:"NumBeatFirstPromise"`
+	BeatFirstPromiseRate float64 `json:"beatFirstPromiseRate" dynamodbav:"BeatFirstPromiseRate"`
+}
+
+func newFixDateReliabilityDbRow(city, scope, cause string, slippage FixDateSlippage) FixDateReliabilityDbRow {
+	return FixDateReliabilityDbRow{
+		City:                 city,
+		Scope:                scope,
+		Cause:                cause,
+		NumIncidents:         slippage.NumIncidents,
+		NumPostponements:     slippage.NumPostponements,
+		AvgPostponements:     slippage.AvgPostponements(),
+		TotalSlippageHours:   slippage.TotalSlippageHours(),
+		AvgSlippageHours:     slippage.AvgSlippageHours(),
+		NumResolved:          slippage.NumResolved,
+		NumBeatFirstPromise:  slippage.NumBeatFirstPromise,
+		BeatFirstPromiseRate: slippage.BeatFirstPromiseRate(),
+	}
+}
+
+// DbRows returns the city-wide row followed by a row per cause sorted by cause.
+func (r FixDateReliability) DbRows(city string) []FixDateReliabilityDbRow {
+	rows := make([]FixDateReliabilityDbRow, 0, len(r.ByCause)+1)
+	rows = append(rows, newFixDateReliabilityDbRow(city, ReliabilityScopeCity, "", r.City))
+
+	causes := make([]string, 0, len(r.ByCause))
+	for cause := range r.ByCause {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+	for _, cause := range causes {
+		rows = append(rows, newFixDateReliabilityDbRow(city, ReliabilityScopeCause+cause, cause, r.ByCause[cause]))
+	}
+	return rows
+}
+
+// ApplyFixDateReliability sets the slippage columns of the station stats.
+func ApplyFixDateReliability(stats []StationIncidentStatsDbRow, reliability FixDateReliability) {
+	for i := range stats {
+		slippage := reliability.ByStation[stats[i].GeoId]
+		stats[i].NumPromisedIncidents = slippage.NumIncidents
+		stats[i].NumPostponements = slippage.NumPostponements
+		stats[i].TotalSlippageHours = slippage.TotalSlippageHours()
+		stats[i].BeatFirstPromiseRate = slippage.BeatFirstPromiseRate()
+	}
+}
@@ -0,0 +1,133 @@
+package scrapper
+
+import (
+	"math"
+	"testing"
+)
+
+func fixDateIncident(geoId int64, typ string, endTime int64, fixDates ...int64) Incident {
+	incident := Incident{
+		IncidentId: newIncidentId(geoId, 100),
+		GeoId:      geoId,
+		StartTime:  100,
+		EndTime:    endTime,
+		Type:       typ,
+		Category:   IncidentCategory(typ),
+	}
+	for i, fixDate := range fixDates {
+		kind := IncidentEventFixDate
+		if i == 0 {
+			kind = IncidentEventOpened
+		}
+		incident.Timeline = append(incident.Timeline, IncidentEvent{Time: 100 + int64(i)*1800, Kind: kind, EstimatedFixDate: fixDate})
+	}
+	return incident
+}
+
+func TestIncidentSlippage(t *testing.T) {
+	const hour = int64(3600)
+
+	tests := []struct {
+		name     string
+		incident Incident
+		want     FixDateSlippage
+	}{
+		{
+			name:     "no fix date given",
+			incident: fixDateIncident(1, "Avarie", 0, 0),
+			want:     FixDateSlippage{},
+		},
+		{
+			name:     "open and never revised",
+			incident: fixDateIncident(1, "Avarie", 0, 10*hour),
+			want:     FixDateSlippage{NumIncidents: 1},
+		},
+		{
+			name:     "resolved before the first promise",
+			incident: fixDateIncident(1, "Avarie", 9*hour, 10*hour),
+			want:     FixDateSlippage{NumIncidents: 1, NumResolved: 1, NumBeatFirstPromise: 1},
+		},
+		{
+			name:     "postponed twice then resolved late",
+			incident: fixDateIncident(1, "Avarie", 30*hour, 10*hour, 20*hour, 30*hour),
+			want:     FixDateSlippage{NumIncidents: 1, NumPostponements: 2, SlippageSeconds: 20 * hour, NumResolved: 1},
+		},
+		{
+			name:     "fix date moved earlier is not a postponement",
+			incident: fixDateIncident(1, "Avarie", 0, 20*hour, 10*hour, 15*hour),
+			want:     FixDateSlippage{NumIncidents: 1, NumPostponements: 1, SlippageSeconds: 5 * hour},
+		},
+		{
+			name:     "removed fix date is ignored",
+			incident: fixDateIncident(1, "Avarie", 0, 10*hour, 0, 10*hour),
+			want:     FixDateSlippage{NumIncidents: 1},
+		},
+		{
+			name:     "first promise given after the opening",
+			incident: fixDateIncident(1, "Avarie", 12*hour, 0, 12*hour),
+			want:     FixDateSlippage{NumIncidents: 1, NumResolved: 1, NumBeatFirstPromise: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IncidentSlippage(tt.incident); got != tt.want {
+				t.Errorf("IncidentSlippage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeFixDateReliability(t *testing.T) {
+	const hour = int64(3600)
+
+	incidents := []Incident{
+		fixDateIncident(1, "Oprire ACC", 5*hour, 10*hour),
+		fixDateIncident(1, "Oprire ACC", 40*hour, 10*hour, 30*hour),
+		fixDateIncident(2, "Deficiente ACC", 0, 10*hour, 20*hour, 40*hour),
+		fixDateIncident(3, "Oprire ACC", 0, 0),
+	}
+	reliability := ComputeFixDateReliability(incidents)
+
+	city := reliability.City
+	if city.NumIncidents != 3 || city.NumPostponements != 3 || city.NumResolved != 2 || city.NumBeatFirstPromise != 1 {
+		t.Errorf("city slippage = %+v", city)
+	}
+	if math.Abs(city.TotalSlippageHours()-50) > 1e-9 || math.Abs(city.BeatFirstPromiseRate()-0.5) > 1e-9 {
+		t.Errorf("city slippage hours = %v, beat rate = %v", city.TotalSlippageHours(), city.BeatFirstPromiseRate())
+	}
+
+	if got := reliability.ByStation[1]; got.NumIncidents != 2 || got.NumPostponements != 1 {
+		t.Errorf("station 1 slippage = %+v", got)
+	}
+	if _, found := reliability.ByStation[3]; found {
+		t.Errorf("station 3 without a fix date should have no slippage")
+	}
+
+	rows := reliability.DbRows("Bucharest")
+	wantScopes := []string{
+		ReliabilityScopeCity,
+		ReliabilityScopeCause + IncidentCategoryDeficiency,
+		ReliabilityScopeCause + IncidentCategoryHotWaterStop,
+	}
+	if len(rows) != len(wantScopes) {
+		t.Fatalf("DbRows() returned %d rows, want %d", len(rows), len(wantScopes))
+	}
+	for i, scope := range wantScopes {
+		if rows[i].Scope != scope || rows[i].City != "Bucharest" {
+			t.Errorf("row %d = %+v, want scope %s", i, rows[i], scope)
+		}
+	}
+	if rows[2].NumIncidents != 2 || rows[2].AvgPostponements != 0.5 {
+		t.Errorf("hot water stop row = %+v", rows[2])
+	}
+
+	stats := []StationIncidentStatsDbRow{{GeoId: 1}, {GeoId: 3}}
+	ApplyFixDateReliability(stats, reliability)
+	if stats[0].NumPromisedIncidents != 2 || stats[0].TotalSlippageHours != 20 || stats[0].BeatFirstPromiseRate != 0.5 {
+		t.Errorf("station 1 stats = %+v", stats[0])
+	}
+	if stats[1].NumPromisedIncidents != 0 {
+		t.Errorf("station 3 stats = %+v", stats[1])
+	}
+}
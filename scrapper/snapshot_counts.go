@@ -0,0 +1,82 @@
+package scrapper
+
+import (
+	"strings"
+)
+
+// incident categories of the CMTEB incident types
+const (
+	IncidentCategoryHotWaterStop = "hotWaterStop"
+	IncidentCategoryDeficiency   = "deficiency"
+	IncidentCategoryHeating      = "heating"
+	IncidentCategoryOther        = "other"
+)
+
+// IncidentCategory classifies an incident type. Heating incidents are matched
+// first, so a heating deficiency or stop is never counted as a hot water one.
+func IncidentCategory(incidentType string) string {
+	normalized := strings.ToLower(incidentType)
+	switch {
+	case strings.Contains(normalized, "incalzire") ||
+		strings.Contains(normalized, "încălzire") ||
+		strings.Contains(normalized, "termic"):
+		return IncidentCategoryHeating
+	case strings.Contains(normalized, "oprire"):
+		return IncidentCategoryHotWaterStop
+	case strings.Contains(normalized, "deficient"):
+		return IncidentCategoryDeficiency
+	default:
+		return IncidentCategoryOther
+	}
+}
+
+// ApplySnapshotCounts sets the churn, incident type and overdue counts of a
+// snapshot. previous is the last stored state of the stations: a station missing
+// from the snapshot is one still stored as active, an incident is opened when a
+// station leaves the working status and resolved when it goes back to it.
+func ApplySnapshotCounts(counts *StationStatesCount, previous map[int64]HeatingStation, statuses []HeatingStationStatus) {
+	seen := make(map[int64]bool, len(statuses))
+
+	for _, status := range statuses {
+		if seen[status.GeoId] {
+			continue
+		}
+		seen[status.GeoId] = true
+		counts.TotalStations++
+
+		working := status.Status == "working"
+		last, exists := previous[status.GeoId]
+		wasWorking := !exists || last.LastStatus == "working" || last.LastStatus == ""
+		if !working && wasWorking {
+			counts.IncidentsOpened++
+		}
+		if working && !wasWorking {
+			counts.IncidentsResolved++
+		}
+
+		if working {
+			continue
+		}
+
+		switch IncidentCategory(status.IncidentType) {
+		case IncidentCategoryHotWaterStop:
+			counts.HotWaterStops++
+		case IncidentCategoryDeficiency:
+			counts.Deficiencies++
+		case IncidentCategoryHeating:
+			counts.HeatingIncidents++
+		default:
+			counts.OtherIncidents++
+		}
+
+		if status.EstimatedFixDate > 0 && status.EstimatedFixDate < status.FetchTime {
+			counts.OverdueIncidents++
+		}
+	}
+
+	for geoId, last := range previous {
+		if last.Active && !seen[geoId] {
+			counts.MissingStations++
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package scrapper
+
+import (
+	"testing"
+)
+
+func TestIncidentCategory(t *testing.T) {
+	tests := []struct {
+		incidentType string
+		want         string
+	}{
+		{incidentType: "Oprire ACC", want: IncidentCategoryHotWaterStop},
+		{incidentType: "Deficienta ACC", want: IncidentCategoryDeficiency},
+		{incidentType: "Deficienta agent termic", want: IncidentCategoryHeating},
+		{incidentType: "Oprire incalzire", want: IncidentCategoryHeating},
+		{incidentType: "-", want: IncidentCategoryOther},
+		{incidentType: "", want: IncidentCategoryOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.incidentType, func(t *testing.T) {
+			if got := IncidentCategory(tt.incidentType); got != tt.want {
+				t.Errorf("IncidentCategory(%q) = %q, want %q", tt.incidentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySnapshotCounts(t *testing.T) {
+	const now = int64(1763640000)
+
+	previous := map[int64]HeatingStation{
+		1: {GeoId: 1, LastStatus: "working", Active: true},
+		2: {GeoId: 2, LastStatus: "broken", Active: true},
+		3: {GeoId: 3, LastStatus: "issue", Active: true},
+		// missing from the snapshot
+		4: {GeoId: 4, LastStatus: "working", Active: true},
+		// already inactive, not counted as missing
+		5: {GeoId: 5, LastStatus: "working", Active: false},
+	}
+	statuses := []HeatingStationStatus{
+		{GeoId: 1, Status: "broken", IncidentType: "Oprire ACC", EstimatedFixDate: now + 3600, FetchTime: now},
+		{GeoId: 2, Status: "working", IncidentType: "-", FetchTime: now},
+		{GeoId: 3, Status: "issue", IncidentType: "Deficienta ACC", EstimatedFixDate: now - 3600, FetchTime: now},
+		// new station with an incident
+		{GeoId: 6, Status: "broken", IncidentType: "Oprire agent termic", FetchTime: now},
+		// duplicated row
+		{GeoId: 6, Status: "broken", IncidentType: "Oprire agent termic", FetchTime: now},
+	}
+
+	counts := StationStatesCount{}
+	ApplySnapshotCounts(&counts, previous, statuses)
+
+	want := StationStatesCount{
+		TotalStations:     4,
+		MissingStations:   1,
+		IncidentsOpened:   2,
+		IncidentsResolved: 1,
+		HotWaterStops:     1,
+		Deficiencies:      1,
+		HeatingIncidents:  1,
+		OverdueIncidents:  1,
+	}
+	if counts != want {
+		t.Errorf("ApplySnapshotCounts() = %+v, want %+v", counts, want)
+	}
+}
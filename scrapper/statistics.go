@@ -17,6 +17,14 @@ type StationIncidentStatsDbRow struct {
 	AvgMonthlyIncidentTimeHours float32 `json:"avgMonthlyIncidentTimeHours" dynamodbav:"AvgMonthlyIncidentTimeHours"`
 	AvgIncidentTimeHours        float32 `json:"avgIncidentTimeHours" dynamodbav:"AvgIncidentTimeHours"`
 	MaxIncidentTimeHours        float32 `json:"maxIncidentTimeHours" dynamodbav:"MaxIncidentTimeHours"`
+	Residents                   float64 `json:"residents" dynamodbav:"Residents"`
+	AvgMonthlyResidentHours     float64 `json:"avgMonthlyResidentHours" dynamodbav:"AvgMonthlyResidentHours"`
+	WeightIsDefault             bool    `json:"weightIsDefault" dynamodbav:"WeightIsDefault"`
+	// the fix date columns are computed from the incidents table, see ApplyFixDateReliability
+	NumPromisedIncidents int     `json:"numPromisedIncidents" dynamodbav:"NumPromisedIncidents"`
+	NumPostponements     int     `json:"numPostponements" dynamodbav:"NumPostponements"`
+	TotalSlippageHours   float64 `json:"totalSlippageHours" dynamodbav:"TotalSlippageHours"`
+	BeatFirstPromiseRate float64 `json:"beatFirstPromiseRate" dynamodbav:"BeatFirstPromiseRate"`
 }
 
 type StationIncidentsData struct {
@@ -43,16 +51,45 @@ func FilterDataset(dataset []HeatingStationStatus, cutoffTimestamp time.Time) []
 }
 
 func ComputeIncidentStatistics(dataset []HeatingStationStatus) []StationIncidentStatsDbRow {
+	return ComputeIncidentStatisticsWithOptions(dataset, IncidentStatisticsOptions{})
+}
+
+// ComputeSparseIncidentStatistics computes the statistics of a dataset persisted in
+// change-only mode, where an unchanged station only gets a row every heartbeat.
+// observedUntil is the time of the last snapshot, a station whose last row is less than
+// a heartbeat older is considered observed until then, as it would be with a row per snapshot.
+func ComputeSparseIncidentStatistics(dataset []HeatingStationStatus, heartbeat time.Duration, observedUntil int64) []StationIncidentStatsDbRow {
+	return ComputeIncidentStatisticsWithOptions(dataset, IncidentStatisticsOptions{
+		Heartbeat:     heartbeat,
+		ObservedUntil: observedUntil,
+	})
+}
+
+// IncidentStatisticsOptions describes how the status history was observed.
+type IncidentStatisticsOptions struct {
+	// Heartbeat and ObservedUntil are set for a change-only history, see ComputeSparseIncidentStatistics
+	Heartbeat     time.Duration
+	ObservedUntil int64
+	// Gaps are left out of both the incident durations and the observed range,
+	// so a period without snapshots is not mistaken for a period without incident
+	Gaps ObservationGaps
+}
+
+func ComputeIncidentStatisticsWithOptions(dataset []HeatingStationStatus, opts IncidentStatisticsOptions) []StationIncidentStatsDbRow {
 	stations := make([]StationIncidentStatsDbRow, 0, 1024)
 
 	slices.SortFunc(dataset, func(a, b HeatingStationStatus) int {
 		return int(a.FetchTime - b.FetchTime)
 	})
 
-	stationsIncidentStats := computeIncidentsPerStation(dataset)
+	stationsIncidentStats := computeIncidentsPerStation(dataset, opts.Gaps)
+
+	if opts.Heartbeat > 0 {
+		extendLastDatesToObservedUntil(stationsIncidentStats, opts.ObservedUntil, opts.Heartbeat)
+	}
 
 	for _, stats := range stationsIncidentStats {
-		stations = append(stations, aggregateIncidentDurations(stats))
+		stations = append(stations, aggregateIncidentDurations(stats, opts.Gaps))
 	}
 
 	slices.SortFunc(stations, func(a, b StationIncidentStatsDbRow) int {
@@ -72,7 +109,35 @@ func ComputeIncidentStatistics(dataset []HeatingStationStatus) []StationIncident
 	return stations
 }
 
-func aggregateIncidentDurations(stats StationIncidentsData) StationIncidentStatsDbRow {
+// ApplyStationWeights sets the residents served by each station and its monthly
+// residents-hours of outage. It returns the stations that used the default weight.
+func ApplyStationWeights(stats []StationIncidentStatsDbRow, weights *StationWeights) []StationIncidentStatsDbRow {
+	unweighted := make([]StationIncidentStatsDbRow, 0)
+	for i := range stats {
+		weight, found := weights.Weight(stats[i].GeoId, stats[i].LastName)
+		stats[i].Residents = weight
+		stats[i].AvgMonthlyResidentHours = float64(stats[i].AvgMonthlyIncidentTimeHours) * weight
+		stats[i].WeightIsDefault = !found
+		if !found {
+			unweighted = append(unweighted, stats[i])
+		}
+	}
+	return unweighted
+}
+
+// extendLastDatesToObservedUntil is applied after open incidents were closed at the
+// current time, so it only moves the last date of stations without an open incident.
+func extendLastDatesToObservedUntil(stationsIncidentData map[int64]StationIncidentsData, observedUntil int64, heartbeat time.Duration) {
+	heartbeatSeconds := int64(heartbeat / time.Second)
+	for geoId, stats := range stationsIncidentData {
+		if stats.LastDate < observedUntil && observedUntil-stats.LastDate < heartbeatSeconds {
+			stats.LastDate = observedUntil
+			stationsIncidentData[geoId] = stats
+		}
+	}
+}
+
+func aggregateIncidentDurations(stats StationIncidentsData, gaps ObservationGaps) StationIncidentStatsDbRow {
 	const (
 		avgDaysPerMonth = 30.4375
 		hoursPerDay     = 24.0
@@ -87,7 +152,8 @@ func aggregateIncidentDurations(stats StationIncidentsData) StationIncidentStats
 		numIncidents         float64
 	)
 
-	rangeDurationHours = (float64(stats.LastDate) - float64(stats.FirstDate)) / secondsPerHour
+	observedSeconds := stats.LastDate - stats.FirstDate - gaps.Unobserved(stats.FirstDate, stats.LastDate)
+	rangeDurationHours = float64(observedSeconds) / secondsPerHour
 	rangeDurationMonths = rangeDurationHours / (hoursPerDay * avgDaysPerMonth)
 	numIncidents = float64(len(stats.IncidentsDurationsHours))
 
@@ -134,7 +200,7 @@ func aggregateIncidentDurations(stats StationIncidentsData) StationIncidentStats
 	}
 }
 
-func computeIncidentsPerStation(dataset []HeatingStationStatus) map[int64]StationIncidentsData {
+func computeIncidentsPerStation(dataset []HeatingStationStatus, gaps ObservationGaps) map[int64]StationIncidentsData {
 	lastStationIncident := make(map[int64]int64, 1024)
 	stationsIncidentData := make(map[int64]StationIncidentsData, 1024)
 
@@ -177,7 +243,7 @@ func computeIncidentsPerStation(dataset []HeatingStationStatus) map[int64]Statio
 
 		if !stationIsInIncident && stationWasInIncident {
 			stationStats := stationsIncidentData[row.GeoId]
-			stationStats.IncidentsDurationsHours = append(stationStats.IncidentsDurationsHours, float64(row.FetchTime-lastIncidentTime)/3600.0)
+			stationStats.IncidentsDurationsHours = append(stationStats.IncidentsDurationsHours, observedHours(lastIncidentTime, row.FetchTime, gaps))
 			stationsIncidentData[row.GeoId] = stationStats
 			lastStationIncident[row.GeoId] = 0
 		}
@@ -187,7 +253,7 @@ func computeIncidentsPerStation(dataset []HeatingStationStatus) map[int64]Statio
 	for geoId, lastIncidentTime := range lastStationIncident {
 		if lastIncidentTime != 0 {
 			stationStats := stationsIncidentData[geoId]
-			stationStats.IncidentsDurationsHours = append(stationStats.IncidentsDurationsHours, float64(nowUnix-lastIncidentTime)/3600.0)
+			stationStats.IncidentsDurationsHours = append(stationStats.IncidentsDurationsHours, observedHours(lastIncidentTime, nowUnix, gaps))
 			stationStats.LastDate = nowUnix
 			stationsIncidentData[geoId] = stationStats
 		}
@@ -195,3 +261,8 @@ func computeIncidentsPerStation(dataset []HeatingStationStatus) map[int64]Statio
 
 	return stationsIncidentData
 }
+
+// observedHours is the duration between two unix times without the observation gaps.
+func observedHours(from, to int64, gaps ObservationGaps) float64 {
+	return float64(to-from-gaps.Unobserved(from, to)) / 3600.0
+}
@@ -206,3 +206,31 @@ func TestComputeIncidentStatistics(t *testing.T) {
 		})
 	}
 }
+
+func TestComputeIncidentStatisticsWithGaps(t *testing.T) {
+	const hour = int64(3600)
+	start := time.Now().Unix() - 100*hour
+
+	// the station is broken for 10 hours, 4 of which were not observed,
+	// and observed for 20 hours out of 24
+	dataset := []HeatingStationStatus{
+		{GeoId: 1, Name: "Station1", Status: "working", FetchTime: start},
+		{GeoId: 1, Name: "Station1", Status: "broken", FetchTime: start + 10*hour},
+		{GeoId: 1, Name: "Station1", Status: "working", FetchTime: start + 20*hour},
+		{GeoId: 1, Name: "Station1", Status: "working", FetchTime: start + 24*hour},
+	}
+	gaps := ObservationGaps{{Start: start + 12*hour, End: start + 16*hour}}
+
+	stats := ComputeIncidentStatisticsWithOptions(dataset, IncidentStatisticsOptions{Gaps: gaps})
+	if len(stats) != 1 {
+		t.Fatalf("ComputeIncidentStatisticsWithOptions() returned %d stations, want 1", len(stats))
+	}
+
+	if abs(float64(stats[0].MaxIncidentTimeHours)-6) > 0.01 {
+		t.Errorf("MaxIncidentTimeHours = %.3f, want 6", stats[0].MaxIncidentTimeHours)
+	}
+	wantMonthly := 6.0 / (20.0 / (24.0 * 30.4375))
+	if abs(float64(stats[0].AvgMonthlyIncidentTimeHours)-wantMonthly) > 0.1 {
+		t.Errorf("AvgMonthlyIncidentTimeHours = %.3f, want %.3f", stats[0].AvgMonthlyIncidentTimeHours, wantMonthly)
+	}
+}
@@ -0,0 +1,152 @@
+package scrapper
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultStationWeight is the number of residents assumed for a station that is
+// missing from the weights table, roughly what a punct termic serves.
+const DefaultStationWeight = 300.0
+
+// stationWeightsCSV is the weights table built into the binary, used when no
+// table is configured. It has no rows, the maintained table is deployed next
+// to the data, see STATION_WEIGHTS_KEY.
+//
+//go:embed station_weights.csv
+var stationWeightsCSV string
+
+// StationWeights gives the number of residents served by each station.
+type StationWeights struct {
+	byGeoId       map[int64]float64
+	byName        map[string]float64
+	defaultWeight float64
+	numStations   int
+}
+
+// LoadDefaultStationWeights parses the embedded weights table.
+func LoadDefaultStationWeights(defaultWeight float64) (*StationWeights, error) {
+	return LoadStationWeights(strings.NewReader(stationWeightsCSV), defaultWeight)
+}
+
+// LoadStationWeights parses a CSV with a header containing a residents (or
+// apartments) column and at least one of the geoId and name columns.
+func LoadStationWeights(r io.Reader, defaultWeight float64) (*StationWeights, error) {
+	weights := &StationWeights{
+		byGeoId:       make(map[int64]float64, 1024),
+		byName:        make(map[string]float64, 1024),
+		defaultWeight: defaultWeight,
+	}
+
+	csvReader := csv.NewReader(r)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weights header: %w", err)
+	}
+	columnMap := make(map[string]int, len(header))
+	for i, col := range header {
+		columnMap[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+
+	geoIdCol, hasGeoId := columnMap["geoid"]
+	nameCol, hasName := columnMap["name"]
+	weightCol, hasWeight := columnMap["residents"]
+	if !hasWeight {
+		weightCol, hasWeight = columnMap["apartments"]
+	}
+	if !hasWeight || (!hasGeoId && !hasName) {
+		return nil, errors.New("weights header must have a residents or apartments column and a geoId or name column")
+	}
+
+	line := 1
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read weights line %d: %w", line, err)
+		}
+		if weightCol >= len(row) {
+			return nil, fmt.Errorf("missing weight on line %d", line)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(row[weightCol]), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight on line %d: %q", line, row[weightCol])
+		}
+
+		matched := false
+		if hasGeoId && geoIdCol < len(row) && strings.TrimSpace(row[geoIdCol]) != "" {
+			geoId, err := strconv.ParseInt(strings.TrimSpace(row[geoIdCol]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid geoId on line %d: %w", line, err)
+			}
+			weights.byGeoId[geoId] = weight
+			matched = true
+		}
+		if hasName && nameCol < len(row) && strings.TrimSpace(row[nameCol]) != "" {
+			weights.byName[strings.TrimSpace(row[nameCol])] = weight
+			matched = true
+		}
+		if !matched {
+			return nil, fmt.Errorf("line %d has neither a geoId nor a name", line)
+		}
+		weights.numStations++
+	}
+
+	return weights, nil
+}
+
+// NumStations is the number of rows of the table, with none every station
+// gets the default weight.
+func (w *StationWeights) NumStations() int {
+	return w.numStations
+}
+
+// Weight returns the residents served by the station, and false if the
+// station is not in the table and the default weight was used.
+func (w *StationWeights) Weight(geoId int64, name string) (float64, bool) {
+	if weight, exists := w.byGeoId[geoId]; exists {
+		return weight, true
+	}
+	if weight, exists := w.byName[strings.TrimSpace(name)]; exists {
+		return weight, true
+	}
+	return w.defaultWeight, false
+}
+
+// ImpactCounts are the residents weighted variants of the states counts.
+type ImpactCounts struct {
+	ResidentsWithoutHotWater float64
+	ResidentsWithIssues      float64
+	// UnweightedStations lists the stations that used the default weight.
+	UnweightedStations []HeatingStationStatus
+}
+
+// ComputeImpactCounts sums the residents served by broken and degraded stations.
+func ComputeImpactCounts(statuses []HeatingStationStatus, weights *StationWeights) ImpactCounts {
+	var impact ImpactCounts
+	for _, status := range statuses {
+		weight, found := weights.Weight(status.GeoId, status.Name)
+		if !found {
+			impact.UnweightedStations = append(impact.UnweightedStations, status)
+		}
+		switch status.Status {
+		case "broken":
+			impact.ResidentsWithoutHotWater += weight
+		case "issue":
+			impact.ResidentsWithIssues += weight
+		}
+	}
+	return impact
+}
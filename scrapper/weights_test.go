@@ -0,0 +1,117 @@
+package scrapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadStationWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr bool
+	}{
+		{
+			name: "geoId and name rows",
+			csv: `geoId,name,residents
+42,,1200
+,Club Steaua,15
+`,
+		},
+		{
+			name: "apartments column",
+			csv: `name,apartments
+1 Colentina,400
+`,
+		},
+		{
+			name:    "missing weight column",
+			csv:     "geoId,name\n42,foo\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid weight",
+			csv:     "geoId,name,residents\n42,,lots\n",
+			wantErr: true,
+		},
+		{
+			name:    "row without key",
+			csv:     "geoId,name,residents\n,,10\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadStationWeights(strings.NewReader(tt.csv), DefaultStationWeight)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadDefaultStationWeights(t *testing.T) {
+	if _, err := LoadDefaultStationWeights(DefaultStationWeight); err != nil {
+		t.Fatalf("embedded weights table is invalid: %v", err)
+	}
+}
+
+func TestComputeImpactCounts(t *testing.T) {
+	weights, err := LoadStationWeights(strings.NewReader(`geoId,name,residents
+1,,1000
+,Club Steaua,10
+`), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights.NumStations() != 2 {
+		t.Errorf("NumStations() = %d, want 2", weights.NumStations())
+	}
+
+	statuses := []HeatingStationStatus{
+		{GeoId: 1, Name: "1 Colentina", Status: "broken"},
+		{GeoId: 2, Name: "Club Steaua", Status: "broken"},
+		{GeoId: 3, Name: "Unknown", Status: "issue"},
+		{GeoId: 4, Name: "Other", Status: "working"},
+	}
+
+	impact := ComputeImpactCounts(statuses, weights)
+
+	if impact.ResidentsWithoutHotWater != 1010 {
+		t.Errorf("ResidentsWithoutHotWater = %f, want 1010", impact.ResidentsWithoutHotWater)
+	}
+	if impact.ResidentsWithIssues != 200 {
+		t.Errorf("ResidentsWithIssues = %f, want 200", impact.ResidentsWithIssues)
+	}
+	if len(impact.UnweightedStations) != 2 {
+		t.Errorf("UnweightedStations = %d stations, want 2", len(impact.UnweightedStations))
+	}
+}
+
+func TestApplyStationWeights(t *testing.T) {
+	weights, err := LoadStationWeights(strings.NewReader("geoId,name,residents\n1,,1000\n"), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := []StationIncidentStatsDbRow{
+		{GeoId: 1, AvgMonthlyIncidentTimeHours: 2},
+		{GeoId: 2, AvgMonthlyIncidentTimeHours: 3},
+	}
+
+	unweighted := ApplyStationWeights(stats, weights)
+
+	if stats[0].AvgMonthlyResidentHours != 2000 || stats[0].WeightIsDefault {
+		t.Errorf("weighted station = %+v, want 2000 residents hours", stats[0])
+	}
+	if stats[1].AvgMonthlyResidentHours != 600 || !stats[1].WeightIsDefault {
+		t.Errorf("default weighted station = %+v, want 600 residents hours", stats[1])
+	}
+	if len(unweighted) != 1 || unweighted[0].GeoId != 2 {
+		t.Errorf("unweighted = %+v, want station 2", unweighted)
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	currentStateDirName      = "current_state"
+	currentStatePointerName  = "latest.json"
+	currentStateVersionsName = "versions"
+	// maxPointerSwapAttempts bounds the retries of a pointer swap lost to a concurrent one
+	maxPointerSwapAttempts = 3
+)
+
+// CurrentStateRetention is how long the documents are kept, the lifecycle rule
+// of the bucket expires them after it. A document is cached no longer.
+const CurrentStateRetention = 30 * 24 * time.Hour
+
+var (
+	// ErrCurrentStateSuperseded is returned when publishing a document older
+	// than the one the pointer names, which is left unchanged.
+	ErrCurrentStateSuperseded = errors.New("a more recent current state is already published")
+	ErrCurrentStateNotFound   = errors.New("current state version not found")
+)
+
+// CurrentStatePointer names the current state document served, it is
+// replaced in a single write once the document is written.
+type CurrentStatePointer struct {
+	Version      string `json:"version"`
+	SnapshotTime int64  `json:"snapshotTime"`
+}
+
+// currentStateVersionPattern is the form of the versions of
+// scrapper.NewCurrentState, the versions asked by the API are checked against it.
+var currentStateVersionPattern = regexp.MustCompile(`^[0-9]+-[0-9a-f]+$`)
+
+// currentStateKey is the key of the document of a version, documents are never
+// rewritten so they can be cached until they expire.
+func currentStateKey(version string) string {
+	return path.Join(currentStateDirName, currentStateVersionsName, version+".json")
+}
+
+// S3CurrentStateStore keeps the documents and their pointer in the
+// current_state folder of a bucket. The pointer swap is a conditional write on
+// the pointer read, so two concurrent runs cannot move it backwards.
+type S3CurrentStateStore struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3CurrentStateStore(client *s3.Client, bucket string) *S3CurrentStateStore {
+	return &S3CurrentStateStore{client: client, bucket: bucket}
+}
+
+func (s *S3CurrentStateStore) PublishCurrentState(ctx context.Context, state scrapper.CurrentState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal current state %s: %w", state.Version, err)
+	}
+	key := currentStateKey(state.Version)
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(content),
+		ContentType:  aws.String("application/json"),
+		CacheControl: aws.String(fmt.Sprintf("public, max-age=%d, immutable", int64(CurrentStateRetention/time.Second))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write s3://%s/%s: %w", s.bucket, key, err)
+	}
+
+	pointerContent, err := json.Marshal(CurrentStatePointer{Version: state.Version, SnapshotTime: state.SnapshotTime})
+	if err != nil {
+		return fmt.Errorf("failed to marshal current state pointer: %w", err)
+	}
+	pointerKey := path.Join(currentStateDirName, currentStatePointerName)
+	for attempt := 1; attempt <= maxPointerSwapAttempts; attempt++ {
+		pointer, etag, found, err := s.readPointer(ctx)
+		if err != nil {
+			return err
+		}
+		if found && pointer.SnapshotTime > state.SnapshotTime {
+			return ErrCurrentStateSuperseded
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket:       aws.String(s.bucket),
+			Key:          aws.String(pointerKey),
+			Body:         bytes.NewReader(pointerContent),
+			ContentType:  aws.String("application/json"),
+			CacheControl: aws.String("no-cache"),
+		}
+		if found {
+			input.IfMatch = etag
+		} else {
+			input.IfNoneMatch = aws.String("*")
+		}
+		_, err = s.client.PutObject(ctx, input)
+		if err == nil {
+			return nil
+		}
+		if !isConditionFailed(err) {
+			return fmt.Errorf("failed to write s3://%s/%s: %w", s.bucket, pointerKey, err)
+		}
+	}
+	return fmt.Errorf("failed to swap the current state pointer, %d attempts lost to concurrent publications", maxPointerSwapAttempts)
+}
+
+func (s *S3CurrentStateStore) CurrentStatePointer(ctx context.Context) (CurrentStatePointer, bool, error) {
+	pointer, _, found, err := s.readPointer(ctx)
+	return pointer, found, err
+}
+
+func (s *S3CurrentStateStore) GetCurrentState(ctx context.Context, version string) (scrapper.CurrentState, error) {
+	if !currentStateVersionPattern.MatchString(version) {
+		return scrapper.CurrentState{}, fmt.Errorf("%w: %q", ErrCurrentStateNotFound, version)
+	}
+	var state scrapper.CurrentState
+	_, found, err := s.getJSON(ctx, currentStateKey(version), &state)
+	if err != nil {
+		return scrapper.CurrentState{}, err
+	}
+	if !found {
+		return scrapper.CurrentState{}, fmt.Errorf("%w: %s", ErrCurrentStateNotFound, version)
+	}
+	return state, nil
+}
+
+// readPointer returns the pointer with its ETag, the condition of its swap.
+func (s *S3CurrentStateStore) readPointer(ctx context.Context) (pointer CurrentStatePointer, etag *string, found bool, err error) {
+	etag, found, err = s.getJSON(ctx, path.Join(currentStateDirName, currentStatePointerName), &pointer)
+	return pointer, etag, found, err
+}
+
+func (s *S3CurrentStateStore) getJSON(ctx context.Context, key string, v any) (etag *string, found bool, err error) {
+	getResult, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read s3://%s/%s: %w", s.bucket, key, err)
+	}
+	defer getResult.Body.Close()
+
+	content, err := io.ReadAll(getResult.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read s3://%s/%s: %w", s.bucket, key, err)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return nil, false, fmt.Errorf("failed to parse s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return getResult.ETag, true, nil
+}
+
+// isConditionFailed tells if a conditional write lost to another write of the object.
+func isConditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict"
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DynamoStationRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoStationRepository(client *dynamodb.Client, tableName string) *DynamoStationRepository {
+	return &DynamoStationRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoStationRepository) ListStations(ctx context.Context) ([]scrapper.HeatingStation, error) {
+	return scanAll[scrapper.HeatingStation](ctx, r.client, &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	})
+}
+
+func (r *DynamoStationRepository) PutStations(ctx context.Context, stations []scrapper.HeatingStation) error {
+	return putItemsInBatches(ctx, r.client, r.tableName, stations)
+}
+
+type DynamoStatusHistoryRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoStatusHistoryRepository(client *dynamodb.Client, tableName string) *DynamoStatusHistoryRepository {
+	return &DynamoStatusHistoryRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoStatusHistoryRepository) PutStatuses(ctx context.Context, statuses []scrapper.HeatingStationStatus) error {
+	return putItemsInBatches(ctx, r.client, r.tableName, statuses)
+}
+
+func (r *DynamoStatusHistoryRepository) ListStationStatuses(ctx context.Context, geoId int64, limit int) ([]scrapper.HeatingStationStatus, error) {
+	statuses := make([]scrapper.HeatingStationStatus, 0, min(limit, 1024))
+
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("GeoId = :geoId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":geoId": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", geoId)},
+		},
+		ScanIndexForward: aws.Bool(false), // Descending order by sort key (Timestamp)
+		Limit:            aws.Int32(int32(limit)),
+	})
+	for paginator.HasMorePages() && len(statuses) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageStatuses []scrapper.HeatingStationStatus
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageStatuses)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, pageStatuses...)
+	}
+
+	return statuses[:min(len(statuses), limit)], nil
+}
+
+func (r *DynamoStatusHistoryRepository) ListStationStatusesBetween(ctx context.Context, geoId int64, from, to time.Time) ([]scrapper.HeatingStationStatus, error) {
+	return queryAll[scrapper.HeatingStationStatus](ctx, r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("GeoId = :geoId AND #ts BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#ts": "Timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":geoId": &types.AttributeValueMemberN{Value: strconv.FormatInt(geoId, 10)},
+			":from":  &types.AttributeValueMemberN{Value: strconv.FormatInt(from.Unix(), 10)},
+			":to":    &types.AttributeValueMemberN{Value: strconv.FormatInt(to.Unix(), 10)},
+		},
+	})
+}
+
+// firstCountsYear is the year the counts collection started.
+const firstCountsYear = 2025
+
+// DynamoDayCountsRepository stores the counts partitioned by the UTC year of
+// their Timestamp, which is the sort key.
+type DynamoDayCountsRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDayCountsRepository(client *dynamodb.Client, tableName string) *DynamoDayCountsRepository {
+	return &DynamoDayCountsRepository{client: client, tableName: tableName}
+}
+
+// dayCountsItem is a counts row with its partition key.
+type dayCountsItem struct {
+	scrapper.StationStatesCount
+	Year int `dynamodbav:"Year"`
+}
+
+// yearPartition is the partition of the rows of year partitioned tables at a unix time.
+func yearPartition(timestamp int64) int {
+	return time.Unix(timestamp, 0).UTC().Year()
+}
+
+func newDayCountsItem(counts scrapper.StationStatesCount) dayCountsItem {
+	return dayCountsItem{StationStatesCount: counts, Year: yearPartition(counts.Time)}
+}
+
+func (r *DynamoDayCountsRepository) PutCounts(ctx context.Context, counts scrapper.StationStatesCount) error {
+	item, err := attributevalue.MarshalMap(newDayCountsItem(counts))
+	if err != nil {
+		return fmt.Errorf("failed to marshal counts: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+// PutManyCounts writes the counts in batches, it is used to migrate existing rows.
+func (r *DynamoDayCountsRepository) PutManyCounts(ctx context.Context, counts []scrapper.StationStatesCount) error {
+	items := make([]dayCountsItem, 0, len(counts))
+	for _, c := range counts {
+		items = append(items, newDayCountsItem(c))
+	}
+	return putItemsInBatches(ctx, r.client, r.tableName, items)
+}
+
+// ListCounts queries the year partitions overlapping the range, from the oldest to the most recent.
+func (r *DynamoDayCountsRepository) ListCounts(ctx context.Context, from, to time.Time) ([]scrapper.StationStatesCount, error) {
+	counts := make([]scrapper.StationStatesCount, 0, 1024)
+
+	for year := from.UTC().Year(); year <= to.UTC().Year(); year++ {
+		items, err := queryAll[dayCountsItem](ctx, r.client, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			KeyConditionExpression: aws.String("#year = :year AND #ts BETWEEN :from AND :to"),
+			ExpressionAttributeNames: map[string]string{
+				"#year": "Year",
+				"#ts":   "Timestamp",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":year": &types.AttributeValueMemberN{Value: strconv.Itoa(year)},
+				":from": &types.AttributeValueMemberN{Value: strconv.FormatInt(from.Unix(), 10)},
+				":to":   &types.AttributeValueMemberN{Value: strconv.FormatInt(to.Unix(), 10)},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			counts = append(counts, item.StationStatesCount)
+		}
+	}
+
+	return counts, nil
+}
+
+// LatestCounts looks for the most recent counts from the current year partition
+// back to the first year of data.
+func (r *DynamoDayCountsRepository) LatestCounts(ctx context.Context) (scrapper.StationStatesCount, bool, error) {
+	for year := time.Now().UTC().Year(); year >= firstCountsYear; year-- {
+		output, err := r.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			KeyConditionExpression: aws.String("#year = :year"),
+			ExpressionAttributeNames: map[string]string{
+				"#year": "Year",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":year": &types.AttributeValueMemberN{Value: strconv.Itoa(year)},
+			},
+			ScanIndexForward: aws.Bool(false),
+			Limit:            aws.Int32(1),
+		})
+		if err != nil {
+			return scrapper.StationStatesCount{}, false, err
+		}
+		if len(output.Items) > 0 {
+			var item dayCountsItem
+			if err := attributevalue.UnmarshalMap(output.Items[0], &item); err != nil {
+				return scrapper.StationStatesCount{}, false, err
+			}
+			return item.StationStatesCount, true, nil
+		}
+	}
+	return scrapper.StationStatesCount{}, false, nil
+}
+
+// ScanLegacyDayCounts reads every row of a counts table keyed by Timestamp only,
+// the layout used before the year partitions.
+func ScanLegacyDayCounts(ctx context.Context, client *dynamodb.Client, tableName string) ([]scrapper.StationStatesCount, error) {
+	counts, err := scanAll[scrapper.StationStatesCount](ctx, client, &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Time < counts[j].Time
+	})
+	return counts, nil
+}
+
+type DynamoIncidentStatsRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoIncidentStatsRepository(client *dynamodb.Client, tableName string) *DynamoIncidentStatsRepository {
+	return &DynamoIncidentStatsRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoIncidentStatsRepository) PutIncidentStats(ctx context.Context, stats []scrapper.StationIncidentStatsDbRow) error {
+	return putItemsInBatches(ctx, r.client, r.tableName, stats)
+}
+
+func (r *DynamoIncidentStatsRepository) ListIncidentStats(ctx context.Context, city string) ([]scrapper.StationIncidentStatsDbRow, error) {
+	stats := make([]scrapper.StationIncidentStatsDbRow, 0, 1024)
+
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("City = :city"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":city": &types.AttributeValueMemberS{Value: city},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageStats []scrapper.StationIncidentStatsDbRow
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageStats)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, pageStats...)
+	}
+
+	return stats, nil
+}
+
+func queryAll[T any](ctx context.Context, client *dynamodb.Client, input *dynamodb.QueryInput) ([]T, error) {
+	items := make([]T, 0, 1024)
+
+	paginator := dynamodb.NewQueryPaginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageItems []T
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageItems)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, pageItems...)
+	}
+
+	return items, nil
+}
+
+func scanAll[T any](ctx context.Context, client *dynamodb.Client, input *dynamodb.ScanInput) ([]T, error) {
+	items := make([]T, 0, 1024)
+
+	paginator := dynamodb.NewScanPaginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageItems []T
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageItems)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, pageItems...)
+	}
+
+	return items, nil
+}
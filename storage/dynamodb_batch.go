@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// maximum number of items accepted by BatchWriteItem
+	batchWriteMaxItems = 25
+	// number of batches in flight at once
+	batchWriteConcurrency = 8
+	// attempts to write the unprocessed items of a batch before giving up on them
+	batchWriteMaxAttempts = 6
+	batchWriteBaseBackoff = 50 * time.Millisecond
+	batchWriteMaxBackoff  = 2 * time.Second
+)
+
+// BatchWriteSummary reports the outcome of writing items to one table.
+type BatchWriteSummary struct {
+	TableName string
+	Written   int
+	Failed    int
+	// Errors holds the first error of each failed batch
+	Errors []error
+}
+
+// batchWriteItems writes the put or delete requests in chunks of 25 with
+// bounded concurrency. Unprocessed requests are retried with an exponential
+// backoff, requests that still fail are counted in the summary instead of
+// aborting the other batches. Requests must have distinct keys, as
+// BatchWriteItem rejects duplicates in a batch.
+func batchWriteItems(ctx context.Context, client *dynamodb.Client, tableName string, requests []types.WriteRequest) BatchWriteSummary {
+	summary := BatchWriteSummary{TableName: tableName}
+	summaryMutex := sync.Mutex{}
+
+	errG, errCtx := errgroup.WithContext(ctx)
+	errG.SetLimit(batchWriteConcurrency)
+
+	for start := 0; start < len(requests); start += batchWriteMaxItems {
+		chunk := requests[start:min(start+batchWriteMaxItems, len(requests))]
+		errG.Go(func() error {
+			unprocessed, err := writeBatchWithRetries(errCtx, client, tableName, chunk)
+
+			summaryMutex.Lock()
+			defer summaryMutex.Unlock()
+			summary.Written += len(chunk) - unprocessed
+			summary.Failed += unprocessed
+			if err != nil {
+				summary.Errors = append(summary.Errors, err)
+			}
+			// failures are reported in the summary, never cancel the other batches
+			return nil
+		})
+	}
+	errG.Wait()
+
+	return summary
+}
+
+// writeBatchWithRetries returns the number of requests that could not be written.
+func writeBatchWithRetries(ctx context.Context, client *dynamodb.Client, tableName string, chunk []types.WriteRequest) (int, error) {
+	requests := chunk
+	for attempt := 0; attempt < batchWriteMaxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepWithBackoff(ctx, attempt); err != nil {
+				return len(requests), err
+			}
+		}
+
+		output, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{
+				tableName: requests,
+			},
+		})
+		if err != nil {
+			// the SDK already retried throttling and transient errors
+			return len(requests), fmt.Errorf("batch write to %s failed: %w", tableName, err)
+		}
+
+		requests = output.UnprocessedItems[tableName]
+		if len(requests) == 0 {
+			return 0, nil
+		}
+		slog.Warn("Batch write returned unprocessed items, retrying",
+			"table", tableName,
+			"numUnprocessed", len(requests),
+			"attempt", attempt+1,
+		)
+	}
+
+	return len(requests), fmt.Errorf("batch write to %s left %d unprocessed items after %d attempts", tableName, len(requests), batchWriteMaxAttempts)
+}
+
+func sleepWithBackoff(ctx context.Context, attempt int) error {
+	backoff := min(batchWriteBaseBackoff<<(attempt-1), batchWriteMaxBackoff)
+	// full jitter keeps concurrent batches from retrying in lockstep
+	backoff = time.Duration(rand.Int64N(int64(backoff)) + 1)
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// logBatchWriteSummary logs the outcome of the writes and returns an error
+// if any item was permanently lost.
+func logBatchWriteSummary(summary BatchWriteSummary) error {
+	slog.Info("Batch write summary",
+		"table", summary.TableName,
+		"written", summary.Written,
+		"failed", summary.Failed,
+	)
+	for _, err := range summary.Errors {
+		slog.Error("Batch write failure", "table", summary.TableName, "error_msg", err.Error())
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%w: %d items failed in %s", ErrBatchWriteFailed, summary.Failed, summary.TableName)
+	}
+	return nil
+}
+
+// putItemsInBatches marshals the items and writes them with batchWriteItems.
+func putItemsInBatches[T any](ctx context.Context, client *dynamodb.Client, tableName string, items []T) error {
+	requests := make([]types.WriteRequest, 0, len(items))
+	for _, item := range items {
+		dbItem, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal item for %s: %w", tableName, err)
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: dbItem}})
+	}
+	return logBatchWriteSummary(batchWriteItems(ctx, client, tableName, requests))
+}
+
+// deleteKeysInBatches deletes the items of the keys with batchWriteItems.
+func deleteKeysInBatches(ctx context.Context, client *dynamodb.Client, tableName string, keys []map[string]types.AttributeValue) error {
+	requests := make([]types.WriteRequest, 0, len(keys))
+	for _, key := range keys {
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+	}
+	return logBatchWriteSummary(batchWriteItems(ctx, client, tableName, requests))
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// openIncidentsIndex is a sparse index, only open incidents have its partition key
+	openIncidentsIndex = "OpenIncidents"
+	openIncidentsKey   = "open"
+)
+
+// DynamoIncidentRepository stores the incidents keyed by GeoId and StartTime.
+type DynamoIncidentRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoIncidentRepository(client *dynamodb.Client, tableName string) *DynamoIncidentRepository {
+	return &DynamoIncidentRepository{client: client, tableName: tableName}
+}
+
+// incidentItem is an incident with the partition key of the open incidents index.
+type incidentItem struct {
+	scrapper.Incident
+	OpenKey string `dynamodbav:"OpenKey,omitempty"`
+}
+
+func newIncidentItem(incident scrapper.Incident) incidentItem {
+	item := incidentItem{Incident: incident}
+	if incident.IsOpen() {
+		item.OpenKey = openIncidentsKey
+	}
+	return item
+}
+
+func (r *DynamoIncidentRepository) PutIncidents(ctx context.Context, incidents []scrapper.Incident) error {
+	items := make([]incidentItem, 0, len(incidents))
+	for _, incident := range incidents {
+		items = append(items, newIncidentItem(incident))
+	}
+	return putItemsInBatches(ctx, r.client, r.tableName, items)
+}
+
+func (r *DynamoIncidentRepository) ListOpenIncidents(ctx context.Context) ([]scrapper.Incident, error) {
+	items, err := queryAll[incidentItem](ctx, r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(openIncidentsIndex),
+		KeyConditionExpression: aws.String("OpenKey = :open"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":open": &types.AttributeValueMemberS{Value: openIncidentsKey},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query open incidents: %w", err)
+	}
+
+	incidents := make([]scrapper.Incident, 0, len(items))
+	for _, item := range items {
+		incidents = append(incidents, item.Incident)
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].StartTime < incidents[j].StartTime
+	})
+	return incidents, nil
+}
+
+func (r *DynamoIncidentRepository) ListStationIncidents(ctx context.Context, geoId int64, limit int) ([]scrapper.Incident, error) {
+	incidents := make([]scrapper.Incident, 0, min(limit, 1024))
+
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("GeoId = :geoId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":geoId": &types.AttributeValueMemberN{Value: strconv.FormatInt(geoId, 10)},
+		},
+		ScanIndexForward: aws.Bool(false), // most recent StartTime first
+		Limit:            aws.Int32(int32(limit)),
+	})
+	for paginator.HasMorePages() && len(incidents) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []incidentItem
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			incidents = append(incidents, item.Incident)
+		}
+	}
+
+	return incidents[:min(len(incidents), limit)], nil
+}
+
+// ListIncidentsSince scans the table, the incidents of every station are needed.
+func (r *DynamoIncidentRepository) ListIncidentsSince(ctx context.Context, cutoff time.Time) ([]scrapper.Incident, error) {
+	items, err := scanAll[incidentItem](ctx, r.client, &dynamodb.ScanInput{
+		TableName:        aws.String(r.tableName),
+		FilterExpression: aws.String("EndTime = :open OR EndTime >= :cutoff"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":open":   &types.AttributeValueMemberN{Value: "0"},
+			":cutoff": &types.AttributeValueMemberN{Value: strconv.FormatInt(cutoff.Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan incidents: %w", err)
+	}
+
+	incidents := make([]scrapper.Incident, 0, len(items))
+	for _, item := range items {
+		incidents = append(incidents, item.Incident)
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].StartTime < incidents[j].StartTime
+	})
+	return incidents, nil
+}
+
+// DynamoFixDateReliabilityRepository stores the reliability rows keyed by City and Scope.
+type DynamoFixDateReliabilityRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoFixDateReliabilityRepository(client *dynamodb.Client, tableName string) *DynamoFixDateReliabilityRepository {
+	return &DynamoFixDateReliabilityRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoFixDateReliabilityRepository) PutFixDateReliability(ctx context.Context, rows []scrapper.FixDateReliabilityDbRow) error {
+	return putItemsInBatches(ctx, r.client, r.tableName, rows)
+}
+
+func (r *DynamoFixDateReliabilityRepository) ListFixDateReliability(ctx context.Context, city string) ([]scrapper.FixDateReliabilityDbRow, error) {
+	return queryAll[scrapper.FixDateReliabilityDbRow](ctx, r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("City = :city"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":city": &types.AttributeValueMemberS{Value: city},
+		},
+	})
+}
+
+func (r *DynamoFixDateReliabilityRepository) DeleteFixDateReliability(ctx context.Context, city string, scopes []string) error {
+	keys := make([]map[string]types.AttributeValue, 0, len(scopes))
+	for _, scope := range scopes {
+		keys = append(keys, map[string]types.AttributeValue{
+			"City":  &types.AttributeValueMemberS{Value: city},
+			"Scope": &types.AttributeValueMemberS{Value: scope},
+		})
+	}
+	return deleteKeysInBatches(ctx, r.client, r.tableName, keys)
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoStationRollupRepository stores the rollups keyed by GeoId and Date.
+type DynamoStationRollupRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoStationRollupRepository(client *dynamodb.Client, tableName string) *DynamoStationRollupRepository {
+	return &DynamoStationRollupRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoStationRollupRepository) PutRollups(ctx context.Context, rollups []scrapper.StationDayRollup) error {
+	return putItemsInBatches(ctx, r.client, r.tableName, rollups)
+}
+
+func (r *DynamoStationRollupRepository) ListStationRollups(ctx context.Context, geoId int64, fromDate, toDate string) ([]scrapper.StationDayRollup, error) {
+	return queryAll[scrapper.StationDayRollup](ctx, r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("GeoId = :geoId AND #date BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#date": "Date",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":geoId": &types.AttributeValueMemberN{Value: strconv.FormatInt(geoId, 10)},
+			":from":  &types.AttributeValueMemberS{Value: fromDate},
+			":to":    &types.AttributeValueMemberS{Value: toDate},
+		},
+	})
+}
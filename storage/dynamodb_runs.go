@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoRunLedgerRepository stores the ETL runs partitioned by the UTC year of
+// their StartTime. The sort key is the start time followed by the run id, so
+// two runs started in the same second, such as a manual and a scheduled one,
+// are both kept.
+type DynamoRunLedgerRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoRunLedgerRepository(client *dynamodb.Client, tableName string) *DynamoRunLedgerRepository {
+	return &DynamoRunLedgerRepository{client: client, tableName: tableName}
+}
+
+// etlRunItem is a ledger row with its keys.
+type etlRunItem struct {
+	scrapper.EtlRun
+	Year   int    `dynamodbav:"Year"`
+	RunKey string `dynamodbav:"RunKey"`
+}
+
+// runKeyTimeDigits pads the start times of the run keys, so they sort as numbers.
+const runKeyTimeDigits = 12
+
+// runKey is the sort key of a run, its zero padded start time then its id.
+func runKey(run scrapper.EtlRun) string {
+	return fmt.Sprintf("%0*d#%s", runKeyTimeDigits, run.StartTime, run.RunId)
+}
+
+func (r *DynamoRunLedgerRepository) PutRun(ctx context.Context, run scrapper.EtlRun) error {
+	item, err := attributevalue.MarshalMap(etlRunItem{EtlRun: run, Year: yearPartition(run.StartTime), RunKey: runKey(run)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+// ListRuns queries the year partitions overlapping the range, from the oldest to the most recent.
+func (r *DynamoRunLedgerRepository) ListRuns(ctx context.Context, from, to time.Time) ([]scrapper.EtlRun, error) {
+	runs := make([]scrapper.EtlRun, 0, 1024)
+
+	for year := from.UTC().Year(); year <= to.UTC().Year(); year++ {
+		items, err := queryAll[etlRunItem](ctx, r.client, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			KeyConditionExpression: aws.String("#year = :year AND #key BETWEEN :from AND :to"),
+			ExpressionAttributeNames: map[string]string{
+				"#year": "Year",
+				"#key":  "RunKey",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":year": &types.AttributeValueMemberN{Value: strconv.Itoa(year)},
+				// every run id of the last second sorts before the tilde
+				":from": &types.AttributeValueMemberS{Value: fmt.Sprintf("%0*d", runKeyTimeDigits, from.Unix())},
+				":to":   &types.AttributeValueMemberS{Value: fmt.Sprintf("%0*d~", runKeyTimeDigits, to.Unix())},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			runs = append(runs, item.EtlRun)
+		}
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,424 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+const (
+	stationsFileName      = "stations.json"
+	incidentStatsFileName = "incident_stats.json"
+	statusesFileName      = "statuses.jsonl"
+	countsFileName        = "day_counts.jsonl"
+	runsFileName          = "etl_runs.jsonl"
+	incidentsFileName     = "incidents.jsonl"
+	reliabilityFileName   = "fix_date_reliability.json"
+	rollupsFileName       = "day_rollups.jsonl"
+)
+
+// FileStore is a MemoryStore persisted to a directory, meant to run the system
+// without AWS. Stations, incident stats, fix date reliability, the event
+// outbox and the run slots are small and rewritten entirely on each write, statuses, counts, runs, incidents and
+// rollups are appended as JSON lines and replayed in order when the store is
+// opened, so a later line replaces an earlier one with the same key. The files
+// are loaded once, so a directory must not be written by several processes at
+// once.
+type FileStore struct {
+	*MemoryStore
+	dir string
+	// writeMutex keeps the files in the same order as the memory state
+	writeMutex sync.Mutex
+}
+
+// OpenFileStore loads the store from dir, creating the directory if needed.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory %s: %w", dir, err)
+	}
+	store := &FileStore{MemoryStore: NewMemoryStore(), dir: dir}
+	ctx := context.Background()
+
+	var stations []scrapper.HeatingStation
+	if err := readJSONFile(store.path(stationsFileName), &stations); err != nil {
+		return nil, err
+	}
+	store.MemoryStore.PutStations(ctx, stations)
+
+	var stats []scrapper.StationIncidentStatsDbRow
+	if err := readJSONFile(store.path(incidentStatsFileName), &stats); err != nil {
+		return nil, err
+	}
+	store.MemoryStore.PutIncidentStats(ctx, stats)
+
+	var reliability []scrapper.FixDateReliabilityDbRow
+	if err := readJSONFile(store.path(reliabilityFileName), &reliability); err != nil {
+		return nil, err
+	}
+	store.MemoryStore.PutFixDateReliability(ctx, reliability)
+
+	var outbox []OutboxEvent
+	if err := readJSONFile(store.path(outboxFileName), &outbox); err != nil {
+		return nil, err
+	}
+	store.MemoryStore.PutOutboxEvents(ctx, outbox)
+
+	var runSlots []RunSlot
+	if err := readJSONFile(store.path(runSlotsFileName), &runSlots); err != nil {
+		return nil, err
+	}
+	for _, slot := range runSlots {
+		store.runSlots[slot.Slot] = slot
+	}
+
+	err := readJSONLines(store.path(statusesFileName), func(status scrapper.HeatingStationStatus) {
+		store.MemoryStore.PutStatuses(ctx, []scrapper.HeatingStationStatus{status})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readJSONLines(store.path(countsFileName), func(counts scrapper.StationStatesCount) {
+		store.MemoryStore.PutCounts(ctx, counts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readJSONLines(store.path(runsFileName), func(run scrapper.EtlRun) {
+		store.MemoryStore.PutRun(ctx, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readJSONLines(store.path(incidentsFileName), func(incident scrapper.Incident) {
+		store.MemoryStore.PutIncidents(ctx, []scrapper.Incident{incident})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readJSONLines(store.path(rollupsFileName), func(rollup scrapper.StationDayRollup) {
+		store.MemoryStore.PutRollups(ctx, []scrapper.StationDayRollup{rollup})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (f *FileStore) PutStations(ctx context.Context, stations []scrapper.HeatingStation) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	f.MemoryStore.PutStations(ctx, stations)
+	all, _ := f.MemoryStore.ListStations(ctx)
+	return writeJSONFile(f.path(stationsFileName), all)
+}
+
+func (f *FileStore) PutIncidentStats(ctx context.Context, stats []scrapper.StationIncidentStatsDbRow) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	f.MemoryStore.PutIncidentStats(ctx, stats)
+
+	f.mutex.RLock()
+	all := make([]scrapper.StationIncidentStatsDbRow, 0, len(f.incidentStats))
+	for _, row := range f.incidentStats {
+		all = append(all, row)
+	}
+	f.mutex.RUnlock()
+
+	return writeJSONFile(f.path(incidentStatsFileName), all)
+}
+
+func (f *FileStore) PutFixDateReliability(ctx context.Context, rows []scrapper.FixDateReliabilityDbRow) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	f.MemoryStore.PutFixDateReliability(ctx, rows)
+	return f.writeReliability()
+}
+
+func (f *FileStore) DeleteFixDateReliability(ctx context.Context, city string, scopes []string) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	f.MemoryStore.DeleteFixDateReliability(ctx, city, scopes)
+	return f.writeReliability()
+}
+
+func (f *FileStore) writeReliability() error {
+	f.mutex.RLock()
+	all := make([]scrapper.FixDateReliabilityDbRow, 0, len(f.reliability))
+	for _, row := range f.reliability {
+		all = append(all, row)
+	}
+	f.mutex.RUnlock()
+
+	return writeJSONFile(f.path(reliabilityFileName), all)
+}
+
+func (f *FileStore) PutOutboxEvents(ctx context.Context, events []OutboxEvent) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	f.MemoryStore.PutOutboxEvents(ctx, events)
+	return f.writeOutbox(ctx)
+}
+
+func (f *FileStore) DeleteOutboxEvents(ctx context.Context, eventIds []string) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	f.MemoryStore.DeleteOutboxEvents(ctx, eventIds)
+	return f.writeOutbox(ctx)
+}
+
+func (f *FileStore) writeOutbox(ctx context.Context) error {
+	all, _ := f.MemoryStore.ListOutboxEvents(ctx, math.MaxInt)
+	return writeJSONFile(f.path(outboxFileName), all)
+}
+
+func (f *FileStore) ClaimRunSlot(ctx context.Context, claim RunSlot, now int64) (RunSlot, bool, error) {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	slot, claimed, _ := f.MemoryStore.ClaimRunSlot(ctx, claim, now)
+	if !claimed {
+		return slot, false, nil
+	}
+	return slot, true, f.writeRunSlots()
+}
+
+func (f *FileStore) UpdateRunSlot(ctx context.Context, slot RunSlot) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	if err := f.MemoryStore.UpdateRunSlot(ctx, slot); err != nil {
+		return err
+	}
+	return f.writeRunSlots()
+}
+
+func (f *FileStore) AddRunSlotStep(ctx context.Context, slot RunSlot, step string) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	if err := f.MemoryStore.AddRunSlotStep(ctx, slot, step); err != nil {
+		return err
+	}
+	return f.writeRunSlots()
+}
+
+func (f *FileStore) writeRunSlots() error {
+	f.mutex.RLock()
+	all := make([]RunSlot, 0, len(f.runSlots))
+	for _, slot := range f.runSlots {
+		all = append(all, slot)
+	}
+	f.mutex.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Slot < all[j].Slot
+	})
+	return writeJSONFile(f.path(runSlotsFileName), all)
+}
+
+func (f *FileStore) PutStatuses(ctx context.Context, statuses []scrapper.HeatingStationStatus) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	if err := appendJSONLines(f.path(statusesFileName), statuses); err != nil {
+		return err
+	}
+	return f.MemoryStore.PutStatuses(ctx, statuses)
+}
+
+func (f *FileStore) PutCounts(ctx context.Context, counts scrapper.StationStatesCount) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	if err := appendJSONLines(f.path(countsFileName), []scrapper.StationStatesCount{counts}); err != nil {
+		return err
+	}
+	return f.MemoryStore.PutCounts(ctx, counts)
+}
+
+func (f *FileStore) PutRun(ctx context.Context, run scrapper.EtlRun) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	if err := appendJSONLines(f.path(runsFileName), []scrapper.EtlRun{run}); err != nil {
+		return err
+	}
+	return f.MemoryStore.PutRun(ctx, run)
+}
+
+func (f *FileStore) PutIncidents(ctx context.Context, incidents []scrapper.Incident) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	if err := appendJSONLines(f.path(incidentsFileName), incidents); err != nil {
+		return err
+	}
+	return f.MemoryStore.PutIncidents(ctx, incidents)
+}
+
+func (f *FileStore) PutRollups(ctx context.Context, rollups []scrapper.StationDayRollup) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	if err := appendJSONLines(f.path(rollupsFileName), rollups); err != nil {
+		return err
+	}
+	return f.MemoryStore.PutRollups(ctx, rollups)
+}
+
+// PublishCurrentState writes the document to the current_state folder of the
+// store directory, then replaces the pointer file through a rename. The files
+// are read again on every call, so a long running API sees the documents
+// published by the local ETL runs.
+func (f *FileStore) PublishCurrentState(ctx context.Context, state scrapper.CurrentState) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	versionPath := filepath.Join(f.dir, filepath.FromSlash(currentStateKey(state.Version)))
+	if err := os.MkdirAll(filepath.Dir(versionPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create current state directory: %w", err)
+	}
+	if err := writeJSONFile(versionPath, state); err != nil {
+		return err
+	}
+
+	pointer, found, err := f.CurrentStatePointer(ctx)
+	if err != nil {
+		return err
+	}
+	if found && pointer.SnapshotTime > state.SnapshotTime {
+		return ErrCurrentStateSuperseded
+	}
+	return writeJSONFile(f.currentStatePointerPath(), CurrentStatePointer{Version: state.Version, SnapshotTime: state.SnapshotTime})
+}
+
+func (f *FileStore) CurrentStatePointer(ctx context.Context) (CurrentStatePointer, bool, error) {
+	var pointer CurrentStatePointer
+	if err := readJSONFile(f.currentStatePointerPath(), &pointer); err != nil {
+		return CurrentStatePointer{}, false, err
+	}
+	return pointer, pointer.Version != "", nil
+}
+
+func (f *FileStore) GetCurrentState(ctx context.Context, version string) (scrapper.CurrentState, error) {
+	if !currentStateVersionPattern.MatchString(version) {
+		return scrapper.CurrentState{}, fmt.Errorf("%w: %q", ErrCurrentStateNotFound, version)
+	}
+	var state scrapper.CurrentState
+	if err := readJSONFile(filepath.Join(f.dir, filepath.FromSlash(currentStateKey(version))), &state); err != nil {
+		return scrapper.CurrentState{}, err
+	}
+	if state.Version == "" {
+		return scrapper.CurrentState{}, fmt.Errorf("%w: %s", ErrCurrentStateNotFound, version)
+	}
+	return state, nil
+}
+
+func (f *FileStore) currentStatePointerPath() string {
+	return filepath.Join(f.dir, currentStateDirName, currentStatePointerName)
+}
+
+func (f *FileStore) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+func readJSONFile(path string, v any) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile replaces the file through a rename so a crash never leaves it truncated.
+func writeJSONFile(path string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+func readJSONLines[T any](path string, handle func(T)) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var item T
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return fmt.Errorf("failed to parse %s line %d: %w", path, lineNumber, err)
+		}
+		handle(item)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+func appendJSONLines[T any](path string, items []T) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return fmt.Errorf("failed to append to %s: %w", path, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+func TestFileStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+
+	writes := []error{
+		store.PutStations(ctx, []scrapper.HeatingStation{{GeoId: 1, Name: "Station1", LastStatus: "working"}}),
+		store.PutStations(ctx, []scrapper.HeatingStation{{GeoId: 1, Name: "Station1", LastStatus: "broken"}}),
+		store.PutStatuses(ctx, []scrapper.HeatingStationStatus{
+			{GeoId: 1, Status: "working", FetchTime: 100},
+			{GeoId: 1, Status: "broken", FetchTime: 200},
+		}),
+		// rewriting a status with the same key replaces it on reopen
+		store.PutStatuses(ctx, []scrapper.HeatingStationStatus{{GeoId: 1, Status: "issue", FetchTime: 200}}),
+		store.PutCounts(ctx, scrapper.StationStatesCount{Time: 200, NumRed: 1}),
+		store.PutIncidentStats(ctx, []scrapper.StationIncidentStatsDbRow{{City: "Bucharest", GeoId: 1, Rank: 1}}),
+		store.PutRun(ctx, scrapper.EtlRun{RunId: "run-1", StartTime: 200, Success: true}),
+		store.PutIncidents(ctx, []scrapper.Incident{{IncidentId: "1-100", GeoId: 1, StartTime: 100}}),
+		// closing the incident rewrites it
+		store.PutIncidents(ctx, []scrapper.Incident{{IncidentId: "1-100", GeoId: 1, StartTime: 100, EndTime: 200}}),
+		store.PutRollups(ctx, []scrapper.StationDayRollup{{GeoId: 1, Date: "2025-01-10", NumSamples: 1}}),
+		// recomputing a day replaces its rollup
+		store.PutRollups(ctx, []scrapper.StationDayRollup{{GeoId: 1, Date: "2025-01-10", NumSamples: 2}}),
+		store.PutOutboxEvents(ctx, []OutboxEvent{{EventId: "a", Time: 200}, {EventId: "b", Time: 100}}),
+		// a delivered event is removed from the file
+		store.DeleteOutboxEvents(ctx, []string{"a"}),
+	}
+	for _, err := range writes {
+		if err != nil {
+			t.Fatalf("write error = %v", err)
+		}
+	}
+
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStore() on existing directory error = %v", err)
+	}
+
+	stations, err := reopened.ListStations(ctx)
+	if err != nil || len(stations) != 1 || stations[0].LastStatus != "broken" {
+		t.Errorf("ListStations() = %+v, %v", stations, err)
+	}
+
+	statuses, err := reopened.ListStationStatuses(ctx, 1, 10)
+	if err != nil || len(statuses) != 2 || statuses[0].Status != "issue" {
+		t.Errorf("ListStationStatuses() = %+v, %v", statuses, err)
+	}
+
+	counts, err := reopened.ListCounts(ctx, time.Unix(0, 0), time.Unix(1000, 0))
+	if err != nil || len(counts) != 1 || counts[0].NumRed != 1 {
+		t.Errorf("ListCounts() = %+v, %v", counts, err)
+	}
+
+	stats, err := reopened.ListIncidentStats(ctx, "Bucharest")
+	if err != nil || len(stats) != 1 || stats[0].Rank != 1 {
+		t.Errorf("ListIncidentStats() = %+v, %v", stats, err)
+	}
+
+	runs, err := reopened.ListRuns(ctx, time.Unix(0, 0), time.Unix(1000, 0))
+	if err != nil || len(runs) != 1 || runs[0].RunId != "run-1" {
+		t.Errorf("ListRuns() = %+v, %v", runs, err)
+	}
+
+	open, err := reopened.ListOpenIncidents(ctx)
+	if err != nil || len(open) != 0 {
+		t.Errorf("ListOpenIncidents() = %+v, %v", open, err)
+	}
+
+	incidents, err := reopened.ListStationIncidents(ctx, 1, 10)
+	if err != nil || len(incidents) != 1 || incidents[0].EndTime != 200 {
+		t.Errorf("ListStationIncidents() = %+v, %v", incidents, err)
+	}
+
+	rollups, err := reopened.ListStationRollups(ctx, 1, "2025-01-01", "2025-01-31")
+	if err != nil || len(rollups) != 1 || rollups[0].NumSamples != 2 {
+		t.Errorf("ListStationRollups() = %+v, %v", rollups, err)
+	}
+
+	outbox, err := reopened.ListOutboxEvents(ctx, 10)
+	if err != nil || len(outbox) != 1 || outbox[0].EventId != "b" {
+		t.Errorf("ListOutboxEvents() = %+v, %v", outbox, err)
+	}
+}
+
+func TestFileStoreCurrentState(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	state := scrapper.CurrentState{
+		Version:      "200-bb",
+		SnapshotTime: 200,
+		Stations:     []scrapper.HeatingStation{{GeoId: 1, LastStatus: "broken", LastIncidentText: "avarie"}},
+	}
+	if err := store.PublishCurrentState(ctx, state); err != nil {
+		t.Fatalf("PublishCurrentState() error = %v", err)
+	}
+	if err := store.PublishCurrentState(ctx, scrapper.CurrentState{Version: "100-aa", SnapshotTime: 100}); !errors.Is(err, ErrCurrentStateSuperseded) {
+		t.Fatalf("PublishCurrentState() of an older snapshot error = %v, want ErrCurrentStateSuperseded", err)
+	}
+
+	// the documents are read from the directory, not from the memory of the store
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStore() on existing directory error = %v", err)
+	}
+	pointer, found, err := reopened.CurrentStatePointer(ctx)
+	if err != nil || !found || pointer.Version != state.Version || pointer.SnapshotTime != 200 {
+		t.Fatalf("CurrentStatePointer() = %+v, %v, %v, want the published version", pointer, found, err)
+	}
+	got, err := reopened.GetCurrentState(ctx, pointer.Version)
+	if err != nil || len(got.Stations) != 1 || got.Stations[0].LastIncidentText != "avarie" {
+		t.Errorf("GetCurrentState() = %+v, %v", got, err)
+	}
+
+	for _, version := range []string{"300-cc", "../stations", "200-bb/../../x"} {
+		if _, err := reopened.GetCurrentState(ctx, version); !errors.Is(err, ErrCurrentStateNotFound) {
+			t.Errorf("GetCurrentState(%q) error = %v, want ErrCurrentStateNotFound", version, err)
+		}
+	}
+}
+
+func TestLoadFileStationWeights(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "weights.csv"), []byte("geoId,residents\n1,1000\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "empty.csv"), []byte("geoId,residents\n"), 0o644)
+
+	tests := []struct {
+		name       string
+		key        string
+		wantWeight float64
+		wantErr    bool
+	}{
+		{name: "configured table", key: "weights.csv", wantWeight: 1000},
+		{name: "built-in table", key: "", wantWeight: 100},
+		{name: "configured table without rows", key: "empty.csv", wantErr: true},
+		{name: "missing table", key: "missing.csv", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weights, err := LoadFileStationWeights(dir, tt.key, 100)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadFileStationWeights() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFileStationWeights() error = %v", err)
+			}
+			if weight, _ := weights.Weight(1, ""); weight != tt.wantWeight {
+				t.Errorf("Weight(1) = %f, want %f", weight, tt.wantWeight)
+			}
+		})
+	}
+}
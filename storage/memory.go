@@ -0,0 +1,493 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+type incidentStatsKey struct {
+	City  string
+	GeoId int64
+}
+
+type reliabilityKey struct {
+	City  string
+	Scope string
+}
+
+// MemoryStore implements every repository and the status archive in memory.
+// Items are keyed like in the DynamoDB tables, so writing an item with an
+// existing key replaces it. It is safe for concurrent use.
+type MemoryStore struct {
+	mutex         sync.RWMutex
+	stations      map[int64]scrapper.HeatingStation
+	statuses      map[int64]map[int64]scrapper.HeatingStationStatus
+	counts        map[int64]scrapper.StationStatesCount
+	incidentStats map[incidentStatsKey]scrapper.StationIncidentStatsDbRow
+	runs          map[string]scrapper.EtlRun
+	incidents     map[string]scrapper.Incident
+	reliability   map[reliabilityKey]scrapper.FixDateReliabilityDbRow
+	rollups       map[int64]map[string]scrapper.StationDayRollup
+	outbox        map[string]OutboxEvent
+	runSlots      map[int64]RunSlot
+	currentStates map[string]scrapper.CurrentState
+	// currentState is the pointer to the served current state, empty before the first one
+	currentState CurrentStatePointer
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		stations:      make(map[int64]scrapper.HeatingStation),
+		statuses:      make(map[int64]map[int64]scrapper.HeatingStationStatus),
+		counts:        make(map[int64]scrapper.StationStatesCount),
+		incidentStats: make(map[incidentStatsKey]scrapper.StationIncidentStatsDbRow),
+		runs:          make(map[string]scrapper.EtlRun),
+		incidents:     make(map[string]scrapper.Incident),
+		reliability:   make(map[reliabilityKey]scrapper.FixDateReliabilityDbRow),
+		rollups:       make(map[int64]map[string]scrapper.StationDayRollup),
+		outbox:        make(map[string]OutboxEvent),
+		runSlots:      make(map[int64]RunSlot),
+		currentStates: make(map[string]scrapper.CurrentState),
+	}
+}
+
+// ListStations returns the stations ordered by GeoId.
+func (m *MemoryStore) ListStations(ctx context.Context) ([]scrapper.HeatingStation, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stations := make([]scrapper.HeatingStation, 0, len(m.stations))
+	for _, station := range m.stations {
+		stations = append(stations, station)
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].GeoId < stations[j].GeoId
+	})
+	return stations, nil
+}
+
+func (m *MemoryStore) PutStations(ctx context.Context, stations []scrapper.HeatingStation) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, station := range stations {
+		m.stations[station.GeoId] = station
+	}
+	return nil
+}
+
+func (m *MemoryStore) PutStatuses(ctx context.Context, statuses []scrapper.HeatingStationStatus) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, status := range statuses {
+		stationStatuses, exists := m.statuses[status.GeoId]
+		if !exists {
+			stationStatuses = make(map[int64]scrapper.HeatingStationStatus)
+			m.statuses[status.GeoId] = stationStatuses
+		}
+		stationStatuses[status.FetchTime] = status
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListStationStatuses(ctx context.Context, geoId int64, limit int) ([]scrapper.HeatingStationStatus, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	statuses := make([]scrapper.HeatingStationStatus, 0, len(m.statuses[geoId]))
+	for _, status := range m.statuses[geoId] {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].FetchTime > statuses[j].FetchTime
+	})
+	return statuses[:min(len(statuses), limit)], nil
+}
+
+func (m *MemoryStore) ListStationStatusesBetween(ctx context.Context, geoId int64, from, to time.Time) ([]scrapper.HeatingStationStatus, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	statuses := make([]scrapper.HeatingStationStatus, 0)
+	for _, status := range m.statuses[geoId] {
+		if status.FetchTime >= from.Unix() && status.FetchTime <= to.Unix() {
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].FetchTime < statuses[j].FetchTime
+	})
+	return statuses, nil
+}
+
+func (m *MemoryStore) PutCounts(ctx context.Context, counts scrapper.StationStatesCount) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.counts[counts.Time] = counts
+	return nil
+}
+
+// ListCounts returns the counts taken between from and to included, oldest first.
+func (m *MemoryStore) ListCounts(ctx context.Context, from, to time.Time) ([]scrapper.StationStatesCount, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	counts := make([]scrapper.StationStatesCount, 0, len(m.counts))
+	for _, count := range m.counts {
+		if count.Time >= from.Unix() && count.Time <= to.Unix() {
+			counts = append(counts, count)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Time < counts[j].Time
+	})
+	return counts, nil
+}
+
+func (m *MemoryStore) LatestCounts(ctx context.Context) (scrapper.StationStatesCount, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var latest scrapper.StationStatesCount
+	found := false
+	for _, count := range m.counts {
+		if !found || count.Time > latest.Time {
+			latest = count
+			found = true
+		}
+	}
+	return latest, found, nil
+}
+
+func (m *MemoryStore) PutIncidentStats(ctx context.Context, stats []scrapper.StationIncidentStatsDbRow) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, row := range stats {
+		m.incidentStats[incidentStatsKey{City: row.City, GeoId: row.GeoId}] = row
+	}
+	return nil
+}
+
+// ListIncidentStats returns the stats of a city ordered by GeoId, like the table sort key.
+func (m *MemoryStore) ListIncidentStats(ctx context.Context, city string) ([]scrapper.StationIncidentStatsDbRow, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats := make([]scrapper.StationIncidentStatsDbRow, 0, len(m.incidentStats))
+	for key, row := range m.incidentStats {
+		if key.City == city {
+			stats = append(stats, row)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].GeoId < stats[j].GeoId
+	})
+	return stats, nil
+}
+
+func (m *MemoryStore) PutRun(ctx context.Context, run scrapper.EtlRun) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.runs[runKey(run)] = run
+	return nil
+}
+
+// ListRuns returns the runs started between from and to included, oldest first.
+func (m *MemoryStore) ListRuns(ctx context.Context, from, to time.Time) ([]scrapper.EtlRun, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	runs := make([]scrapper.EtlRun, 0, len(m.runs))
+	for _, run := range m.runs {
+		if run.StartTime >= from.Unix() && run.StartTime <= to.Unix() {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runKey(runs[i]) < runKey(runs[j])
+	})
+	return runs, nil
+}
+
+func (m *MemoryStore) PutIncidents(ctx context.Context, incidents []scrapper.Incident) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, incident := range incidents {
+		m.incidents[incident.IncidentId] = incident
+	}
+	return nil
+}
+
+// ListOpenIncidents returns the incidents without an end time, oldest first.
+func (m *MemoryStore) ListOpenIncidents(ctx context.Context) ([]scrapper.Incident, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	incidents := make([]scrapper.Incident, 0)
+	for _, incident := range m.incidents {
+		if incident.IsOpen() {
+			incidents = append(incidents, incident)
+		}
+	}
+	sortIncidents(incidents, false)
+	return incidents, nil
+}
+
+func (m *MemoryStore) ListStationIncidents(ctx context.Context, geoId int64, limit int) ([]scrapper.Incident, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	incidents := make([]scrapper.Incident, 0)
+	for _, incident := range m.incidents {
+		if incident.GeoId == geoId {
+			incidents = append(incidents, incident)
+		}
+	}
+	sortIncidents(incidents, true)
+	return incidents[:min(len(incidents), limit)], nil
+}
+
+func (m *MemoryStore) ListIncidentsSince(ctx context.Context, cutoff time.Time) ([]scrapper.Incident, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	incidents := make([]scrapper.Incident, 0)
+	for _, incident := range m.incidents {
+		if incident.IsOpen() || incident.EndTime >= cutoff.Unix() {
+			incidents = append(incidents, incident)
+		}
+	}
+	sortIncidents(incidents, false)
+	return incidents, nil
+}
+
+func (m *MemoryStore) PutFixDateReliability(ctx context.Context, rows []scrapper.FixDateReliabilityDbRow) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, row := range rows {
+		m.reliability[reliabilityKey{City: row.City, Scope: row.Scope}] = row
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteFixDateReliability(ctx context.Context, city string, scopes []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, scope := range scopes {
+		delete(m.reliability, reliabilityKey{City: city, Scope: scope})
+	}
+	return nil
+}
+
+// ListFixDateReliability returns the rows of a city ordered by Scope, like the table sort key.
+func (m *MemoryStore) ListFixDateReliability(ctx context.Context, city string) ([]scrapper.FixDateReliabilityDbRow, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	rows := make([]scrapper.FixDateReliabilityDbRow, 0)
+	for key, row := range m.reliability {
+		if key.City == city {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Scope < rows[j].Scope
+	})
+	return rows, nil
+}
+
+func (m *MemoryStore) PutRollups(ctx context.Context, rollups []scrapper.StationDayRollup) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, rollup := range rollups {
+		stationRollups, exists := m.rollups[rollup.GeoId]
+		if !exists {
+			stationRollups = make(map[string]scrapper.StationDayRollup)
+			m.rollups[rollup.GeoId] = stationRollups
+		}
+		stationRollups[rollup.Date] = rollup
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListStationRollups(ctx context.Context, geoId int64, fromDate, toDate string) ([]scrapper.StationDayRollup, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	rollups := make([]scrapper.StationDayRollup, 0)
+	for date, rollup := range m.rollups[geoId] {
+		if date >= fromDate && date <= toDate {
+			rollups = append(rollups, rollup)
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		return rollups[i].Date < rollups[j].Date
+	})
+	return rollups, nil
+}
+
+func (m *MemoryStore) PutOutboxEvents(ctx context.Context, events []OutboxEvent) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, event := range events {
+		m.outbox[event.EventId] = event
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	events := make([]OutboxEvent, 0, len(m.outbox))
+	for _, event := range m.outbox {
+		events = append(events, event)
+	}
+	sortOutboxEvents(events)
+	return events[:min(len(events), limit)], nil
+}
+
+func (m *MemoryStore) DeleteOutboxEvents(ctx context.Context, eventIds []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, eventId := range eventIds {
+		delete(m.outbox, eventId)
+	}
+	return nil
+}
+
+func (m *MemoryStore) ClaimRunSlot(ctx context.Context, claim RunSlot, now int64) (RunSlot, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	slot, exists := m.runSlots[claim.Slot]
+	if exists && !slot.claimable(now) {
+		return slot, false, nil
+	}
+	slot.Slot = claim.Slot
+	slot.RunId = claim.RunId
+	slot.Attempts++
+	slot.LeaseExpiresAt = claim.LeaseExpiresAt
+	slot.ExpiresAt = claim.ExpiresAt
+	if slot.Steps == nil {
+		slot.Steps = make([]string, 0)
+	}
+	m.runSlots[slot.Slot] = slot
+	return slot, true, nil
+}
+
+func (m *MemoryStore) UpdateRunSlot(ctx context.Context, slot RunSlot) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.runSlots[slot.Slot].RunId != slot.RunId {
+		return fmt.Errorf("%w: slot %d", ErrRunSlotLost, slot.Slot)
+	}
+	m.runSlots[slot.Slot] = slot
+	return nil
+}
+
+func (m *MemoryStore) AddRunSlotStep(ctx context.Context, slot RunSlot, step string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored, exists := m.runSlots[slot.Slot]
+	if !exists || stored.RunId != slot.RunId {
+		return fmt.Errorf("%w: slot %d", ErrRunSlotLost, slot.Slot)
+	}
+	if !stored.HasStep(step) {
+		stored.Steps = append(slices.Clone(stored.Steps), step)
+	}
+	m.runSlots[slot.Slot] = stored
+	return nil
+}
+
+func sortIncidents(incidents []scrapper.Incident, mostRecentFirst bool) {
+	sort.Slice(incidents, func(i, j int) bool {
+		if incidents[i].StartTime != incidents[j].StartTime {
+			return (incidents[i].StartTime > incidents[j].StartTime) == mostRecentFirst
+		}
+		return incidents[i].GeoId < incidents[j].GeoId
+	})
+}
+
+// ListStatusesSince returns the statuses fetched after the cutoff, oldest first.
+func (m *MemoryStore) ListStatusesSince(ctx context.Context, cutoff time.Time) ([]scrapper.HeatingStationStatus, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	statuses := make([]scrapper.HeatingStationStatus, 0, 1024)
+	for _, stationStatuses := range m.statuses {
+		for _, status := range stationStatuses {
+			if cutoff.Before(time.Unix(status.FetchTime, 0)) {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].FetchTime != statuses[j].FetchTime {
+			return statuses[i].FetchTime < statuses[j].FetchTime
+		}
+		return statuses[i].GeoId < statuses[j].GeoId
+	})
+	return statuses, nil
+}
+
+// ListStatusesBetween returns the statuses fetched after from and until to
+// included, oldest first. The store has no day folder to miss.
+func (m *MemoryStore) ListStatusesBetween(ctx context.Context, from, to time.Time) ([]scrapper.HeatingStationStatus, []string, error) {
+	since, _ := m.ListStatusesSince(ctx, from)
+	statuses := make([]scrapper.HeatingStationStatus, 0, len(since))
+	for _, status := range since {
+		if status.FetchTime <= to.Unix() {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, make([]string, 0), nil
+}
+
+func (m *MemoryStore) PublishCurrentState(ctx context.Context, state scrapper.CurrentState) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.currentStates[state.Version] = state
+	if m.currentState.Version != "" && m.currentState.SnapshotTime > state.SnapshotTime {
+		return ErrCurrentStateSuperseded
+	}
+	m.currentState = CurrentStatePointer{Version: state.Version, SnapshotTime: state.SnapshotTime}
+	return nil
+}
+
+func (m *MemoryStore) CurrentStatePointer(ctx context.Context) (CurrentStatePointer, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.currentState, m.currentState.Version != "", nil
+}
+
+func (m *MemoryStore) GetCurrentState(ctx context.Context, version string) (scrapper.CurrentState, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	state, found := m.currentStates[version]
+	if !found {
+		return scrapper.CurrentState{}, fmt.Errorf("%w: %q", ErrCurrentStateNotFound, version)
+	}
+	return state, nil
+}
@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+func TestMemoryStoreStations(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	err := store.PutStations(ctx, []scrapper.HeatingStation{
+		{GeoId: 2, Name: "Station2", LastStatus: "working"},
+		{GeoId: 1, Name: "Station1", LastStatus: "working"},
+	})
+	if err != nil {
+		t.Fatalf("PutStations() error = %v", err)
+	}
+	// same key replaces the stored station
+	err = store.PutStations(ctx, []scrapper.HeatingStation{{GeoId: 2, Name: "Station2", LastStatus: "broken"}})
+	if err != nil {
+		t.Fatalf("PutStations() error = %v", err)
+	}
+
+	stations, err := store.ListStations(ctx)
+	if err != nil {
+		t.Fatalf("ListStations() error = %v", err)
+	}
+	if len(stations) != 2 {
+		t.Fatalf("ListStations() returned %d stations, want 2", len(stations))
+	}
+	if stations[0].GeoId != 1 || stations[1].LastStatus != "broken" {
+		t.Errorf("ListStations() = %+v", stations)
+	}
+}
+
+func TestMemoryStoreStatuses(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	statuses := make([]scrapper.HeatingStationStatus, 0, 10)
+	for i := range 5 {
+		for geoId := int64(1); geoId <= 2; geoId++ {
+			statuses = append(statuses, scrapper.HeatingStationStatus{
+				GeoId:     geoId,
+				Status:    "working",
+				FetchTime: start.Add(time.Duration(i) * time.Hour).Unix(),
+			})
+		}
+	}
+	if err := store.PutStatuses(ctx, statuses); err != nil {
+		t.Fatalf("PutStatuses() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		geoId    int64
+		limit    int
+		wantLen  int
+		wantLast int64
+	}{
+		{name: "limit below history size", geoId: 1, limit: 3, wantLen: 3, wantLast: start.Add(4 * time.Hour).Unix()},
+		{name: "limit above history size", geoId: 2, limit: 10, wantLen: 5, wantLast: start.Add(4 * time.Hour).Unix()},
+		{name: "unknown station", geoId: 3, limit: 10, wantLen: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListStationStatuses(ctx, tt.geoId, tt.limit)
+			if err != nil {
+				t.Fatalf("ListStationStatuses() error = %v", err)
+			}
+			if len(got) != tt.wantLen {
+				t.Fatalf("ListStationStatuses() returned %d statuses, want %d", len(got), tt.wantLen)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i-1].FetchTime <= got[i].FetchTime {
+					t.Errorf("statuses are not sorted from the most recent: %+v", got)
+				}
+			}
+			if len(got) > 0 && got[0].FetchTime != tt.wantLast {
+				t.Errorf("most recent status time = %d, want %d", got[0].FetchTime, tt.wantLast)
+			}
+		})
+	}
+
+	since, err := store.ListStatusesSince(ctx, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("ListStatusesSince() error = %v", err)
+	}
+	if len(since) != 4 {
+		t.Errorf("ListStatusesSince() returned %d statuses, want 4", len(since))
+	}
+}
+
+func TestMemoryStoreCountsAndIncidentStats(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for _, ts := range []int64{300, 100, 200} {
+		if err := store.PutCounts(ctx, scrapper.StationStatesCount{Time: ts}); err != nil {
+			t.Fatalf("PutCounts() error = %v", err)
+		}
+	}
+	counts, err := store.ListCounts(ctx, time.Unix(100, 0), time.Unix(200, 0))
+	if err != nil {
+		t.Fatalf("ListCounts() error = %v", err)
+	}
+	if len(counts) != 2 || counts[0].Time != 100 || counts[1].Time != 200 {
+		t.Errorf("ListCounts() = %+v, want the 2 counts in range from the oldest", counts)
+	}
+	latest, found, err := store.LatestCounts(ctx)
+	if err != nil || !found || latest.Time != 300 {
+		t.Errorf("LatestCounts() = %+v, %v, %v, want the counts at 300", latest, found, err)
+	}
+
+	err = store.PutIncidentStats(ctx, []scrapper.StationIncidentStatsDbRow{
+		{City: "Bucharest", GeoId: 2},
+		{City: "Bucharest", GeoId: 1},
+		{City: "Cluj", GeoId: 1},
+	})
+	if err != nil {
+		t.Fatalf("PutIncidentStats() error = %v", err)
+	}
+	stats, err := store.ListIncidentStats(ctx, "Bucharest")
+	if err != nil {
+		t.Fatalf("ListIncidentStats() error = %v", err)
+	}
+	if len(stats) != 2 || stats[0].GeoId != 1 {
+		t.Errorf("ListIncidentStats() = %+v, want the 2 Bucharest stations", stats)
+	}
+}
+
+func TestMemoryStoreRuns(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// a manual and a scheduled run started in the same second are both kept
+	store.PutRun(ctx, scrapper.EtlRun{RunId: "manual", StartTime: 100})
+	store.PutRun(ctx, scrapper.EtlRun{RunId: "scheduled", StartTime: 100})
+	store.PutRun(ctx, scrapper.EtlRun{RunId: "earlier", StartTime: 99})
+	store.PutRun(ctx, scrapper.EtlRun{RunId: "later", StartTime: 1000})
+
+	runs, err := store.ListRuns(ctx, time.Unix(99, 0), time.Unix(100, 0))
+	if err != nil {
+		t.Fatalf("ListRuns() error = %v", err)
+	}
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		ids = append(ids, run.RunId)
+	}
+	if strings.Join(ids, ",") != "earlier,manual,scheduled" {
+		t.Errorf("ListRuns() = %v, want earlier,manual,scheduled", ids)
+	}
+}
+
+func TestMemoryStoreIncidents(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	err := store.PutIncidents(ctx, []scrapper.Incident{
+		{IncidentId: "1-300", GeoId: 1, StartTime: 300},
+		{IncidentId: "1-100", GeoId: 1, StartTime: 100, EndTime: 200},
+		{IncidentId: "2-150", GeoId: 2, StartTime: 150},
+	})
+	if err != nil {
+		t.Fatalf("PutIncidents() error = %v", err)
+	}
+
+	open, err := store.ListOpenIncidents(ctx)
+	if err != nil {
+		t.Fatalf("ListOpenIncidents() error = %v", err)
+	}
+	if len(open) != 2 || open[0].IncidentId != "2-150" || open[1].IncidentId != "1-300" {
+		t.Errorf("ListOpenIncidents() = %+v, want 2-150 then 1-300", open)
+	}
+
+	tests := []struct {
+		name    string
+		geoId   int64
+		limit   int
+		wantIds []string
+	}{
+		{name: "most recent first", geoId: 1, limit: 10, wantIds: []string{"1-300", "1-100"}},
+		{name: "limit", geoId: 1, limit: 1, wantIds: []string{"1-300"}},
+		{name: "unknown station", geoId: 3, limit: 10, wantIds: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incidents, err := store.ListStationIncidents(ctx, tt.geoId, tt.limit)
+			if err != nil {
+				t.Fatalf("ListStationIncidents() error = %v", err)
+			}
+			if len(incidents) != len(tt.wantIds) {
+				t.Fatalf("ListStationIncidents() returned %d incidents, want %d", len(incidents), len(tt.wantIds))
+			}
+			for i, id := range tt.wantIds {
+				if incidents[i].IncidentId != id {
+					t.Errorf("incident %d = %s, want %s", i, incidents[i].IncidentId, id)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreFixDateReliability(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	store.PutFixDateReliability(ctx, []scrapper.FixDateReliabilityDbRow{
+		{City: "Bucharest", Scope: "city", NumIncidents: 3},
+		{City: "Bucharest", Scope: "cause#Avarie", NumIncidents: 2},
+		{City: "Bucharest", Scope: "cause#Oprire ACC", NumIncidents: 1},
+		{City: "Cluj", Scope: "cause#Avarie", NumIncidents: 1},
+	})
+	if err := store.DeleteFixDateReliability(ctx, "Bucharest", []string{"cause#Avarie", "cause#unknown"}); err != nil {
+		t.Fatalf("DeleteFixDateReliability() error = %v", err)
+	}
+
+	rows, _ := store.ListFixDateReliability(ctx, "Bucharest")
+	if len(rows) != 2 || rows[0].Scope != "cause#Oprire ACC" || rows[1].Scope != "city" {
+		t.Errorf("ListFixDateReliability() = %+v, want the city and Oprire ACC rows", rows)
+	}
+	if rows, _ := store.ListFixDateReliability(ctx, "Cluj"); len(rows) != 1 {
+		t.Errorf("ListFixDateReliability() of another city = %+v, want its row kept", rows)
+	}
+}
+
+func TestMemoryStoreOutbox(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	err := store.PutOutboxEvents(ctx, []OutboxEvent{
+		{EventId: "c", Time: 200},
+		{EventId: "b", Time: 100},
+		{EventId: "a", Time: 200},
+	})
+	if err != nil {
+		t.Fatalf("PutOutboxEvents() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		limit   int
+		wantIds []string
+	}{
+		{name: "oldest first, then by id", limit: 10, wantIds: []string{"b", "a", "c"}},
+		{name: "limit", limit: 2, wantIds: []string{"b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := store.ListOutboxEvents(ctx, tt.limit)
+			if err != nil {
+				t.Fatalf("ListOutboxEvents() error = %v", err)
+			}
+			if len(events) != len(tt.wantIds) {
+				t.Fatalf("ListOutboxEvents() returned %d events, want %d", len(events), len(tt.wantIds))
+			}
+			for i, id := range tt.wantIds {
+				if events[i].EventId != id {
+					t.Errorf("event %d = %s, want %s", i, events[i].EventId, id)
+				}
+			}
+		})
+	}
+
+	if err := store.DeleteOutboxEvents(ctx, []string{"a", "b", "unknown"}); err != nil {
+		t.Fatalf("DeleteOutboxEvents() error = %v", err)
+	}
+	events, _ := store.ListOutboxEvents(ctx, 10)
+	if len(events) != 1 || events[0].EventId != "c" {
+		t.Errorf("ListOutboxEvents() after delete = %+v, want only c", events)
+	}
+}
+
+func TestMemoryStoreRunSlots(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	claim := func(runId string, now int64) (RunSlot, bool) {
+		t.Helper()
+		slot, claimed, err := store.ClaimRunSlot(ctx, RunSlot{Slot: 1800, RunId: runId, LeaseExpiresAt: now + 600}, now)
+		if err != nil {
+			t.Fatalf("ClaimRunSlot() error = %v", err)
+		}
+		return slot, claimed
+	}
+
+	first, claimed := claim("first", 1800)
+	if !claimed || first.Attempts != 1 {
+		t.Fatalf("first claim = %+v, %v, want a claimed slot", first, claimed)
+	}
+
+	// a duplicate delivery while the first attempt runs
+	if slot, claimed := claim("duplicate", 1810); claimed || slot.RunId != "first" {
+		t.Errorf("duplicate claim = %+v, %v, want the slot held by the first attempt", slot, claimed)
+	}
+
+	// the first attempt records the counts as soon as they are written, then fails and releases the slot
+	if err := store.AddRunSlotStep(ctx, first, "counts"); err != nil {
+		t.Fatalf("AddRunSlotStep() error = %v", err)
+	}
+	first.Steps = append(first.Steps, "counts")
+	first.LeaseExpiresAt = 0
+	if err := store.UpdateRunSlot(ctx, first); err != nil {
+		t.Fatalf("UpdateRunSlot() error = %v", err)
+	}
+
+	retry, claimed := claim("retry", 1900)
+	if !claimed || retry.Attempts != 2 || !retry.HasStep("counts") {
+		t.Fatalf("retry claim = %+v, %v, want the slot with the counts step", retry, claimed)
+	}
+	if err := store.UpdateRunSlot(ctx, first); !errors.Is(err, ErrRunSlotLost) {
+		t.Errorf("UpdateRunSlot() by the first attempt error = %v, want ErrRunSlotLost", err)
+	}
+	if err := store.AddRunSlotStep(ctx, first, "stations"); !errors.Is(err, ErrRunSlotLost) {
+		t.Errorf("AddRunSlotStep() by the first attempt error = %v, want ErrRunSlotLost", err)
+	}
+
+	retry.Completed = true
+	retry.LeaseExpiresAt = 0
+	if err := store.UpdateRunSlot(ctx, retry); err != nil {
+		t.Fatalf("UpdateRunSlot() error = %v", err)
+	}
+	if slot, claimed := claim("late", 5000); claimed || !slot.Completed {
+		t.Errorf("claim of a completed slot = %+v, %v, want it refused", slot, claimed)
+	}
+}
+
+func TestMemoryStoreCurrentState(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	if _, found, err := store.CurrentStatePointer(ctx); found || err != nil {
+		t.Fatalf("CurrentStatePointer() before a publication = found %v, %v", found, err)
+	}
+
+	newer := scrapper.CurrentState{Version: "200-bb", SnapshotTime: 200}
+	older := scrapper.CurrentState{Version: "100-aa", SnapshotTime: 100}
+	if err := store.PublishCurrentState(ctx, newer); err != nil {
+		t.Fatalf("PublishCurrentState() error = %v", err)
+	}
+	if err := store.PublishCurrentState(ctx, older); !errors.Is(err, ErrCurrentStateSuperseded) {
+		t.Fatalf("PublishCurrentState() of an older snapshot error = %v, want ErrCurrentStateSuperseded", err)
+	}
+
+	pointer, found, err := store.CurrentStatePointer(ctx)
+	if err != nil || !found || pointer.Version != newer.Version {
+		t.Errorf("CurrentStatePointer() = %+v, %v, %v, want the newer version", pointer, found, err)
+	}
+	// the superseded document is still served by version
+	if state, err := store.GetCurrentState(ctx, older.Version); err != nil || state.SnapshotTime != 100 {
+		t.Errorf("GetCurrentState(%s) = %+v, %v", older.Version, state, err)
+	}
+	if _, err := store.GetCurrentState(ctx, "300-cc"); !errors.Is(err, ErrCurrentStateNotFound) {
+		t.Errorf("GetCurrentState() of an unknown version error = %v, want ErrCurrentStateNotFound", err)
+	}
+}
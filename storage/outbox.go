@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const outboxFileName = "event_outbox.json"
+
+// OutboxEvent is a domain event written with the state it describes and kept
+// until a publisher delivered it.
+type OutboxEvent struct {
+	EventId string `json:"eventId" dynamodbav:"EventId"`
+	// Time orders the delivery, the events of a same time are delivered by id
+	Time int64 `json:"time" dynamodbav:"Time"`
+	// Payload is the encoded event, as published
+	Payload string `json:"payload" dynamodbav:"Payload"`
+	// ExpiresAt is the DynamoDB TTL dropping an event that could never be delivered
+	ExpiresAt int64 `json:"expiresAt,omitempty" dynamodbav:"ExpiresAt,omitempty"`
+}
+
+func sortOutboxEvents(events []OutboxEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Time != events[j].Time {
+			return events[i].Time < events[j].Time
+		}
+		return events[i].EventId < events[j].EventId
+	})
+}
+
+// DynamoOutboxRepository stores the pending events keyed by EventId. The table
+// only holds the events not delivered yet, so it is scanned.
+type DynamoOutboxRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoOutboxRepository(client *dynamodb.Client, tableName string) *DynamoOutboxRepository {
+	return &DynamoOutboxRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoOutboxRepository) PutOutboxEvents(ctx context.Context, events []OutboxEvent) error {
+	return putItemsInBatches(ctx, r.client, r.tableName, events)
+}
+
+func (r *DynamoOutboxRepository) ListOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	events, err := scanAll[OutboxEvent](ctx, r.client, &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan outbox events: %w", err)
+	}
+	sortOutboxEvents(events)
+	return events[:min(len(events), limit)], nil
+}
+
+func (r *DynamoOutboxRepository) DeleteOutboxEvents(ctx context.Context, eventIds []string) error {
+	keys := make([]map[string]types.AttributeValue, 0, len(eventIds))
+	for _, eventId := range eventIds {
+		keys = append(keys, map[string]types.AttributeValue{
+			"EventId": &types.AttributeValueMemberS{Value: eventId},
+		})
+	}
+	return deleteKeysInBatches(ctx, r.client, r.tableName, keys)
+}
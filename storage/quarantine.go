@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const quarantineDirName = "quarantine"
+
+// QuarantinedSnapshot is a snapshot the ETL refused to publish, kept with its
+// raw page so it can be inspected and replayed.
+type QuarantinedSnapshot struct {
+	RunId     string
+	FetchTime time.Time
+	Page      []byte
+	// Report is the JSON document telling why the snapshot was refused
+	Report []byte
+}
+
+// quarantineKey is the folder of a snapshot, <date>/<time>-<run id> under the quarantine folder.
+func quarantineKey(snapshot QuarantinedSnapshot) string {
+	fetchTime := snapshot.FetchTime.UTC()
+	return path.Join(quarantineDirName, fetchTime.Format("2006-01-02"), fetchTime.Format("150405")+"-"+snapshot.RunId)
+}
+
+// S3SnapshotQuarantine writes the snapshots to the quarantine folder of a bucket.
+type S3SnapshotQuarantine struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3SnapshotQuarantine(client *s3.Client, bucket string) *S3SnapshotQuarantine {
+	return &S3SnapshotQuarantine{client: client, bucket: bucket}
+}
+
+func (q *S3SnapshotQuarantine) QuarantineSnapshot(ctx context.Context, snapshot QuarantinedSnapshot) error {
+	key := quarantineKey(snapshot)
+
+	objects := []struct {
+		name        string
+		contentType string
+		content     []byte
+	}{
+		{name: "page.html", contentType: "text/html", content: snapshot.Page},
+		{name: "report.json", contentType: "application/json", content: snapshot.Report},
+	}
+	for _, object := range objects {
+		_, err := q.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(q.bucket),
+			Key:         aws.String(path.Join(key, object.name)),
+			Body:        bytes.NewReader(object.content),
+			ContentType: aws.String(object.contentType),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write %s to s3://%s/%s: %w", object.name, q.bucket, key, err)
+		}
+	}
+	return nil
+}
+
+// QuarantineSnapshot writes the snapshot to the quarantine folder of the store directory.
+func (f *FileStore) QuarantineSnapshot(ctx context.Context, snapshot QuarantinedSnapshot) error {
+	dir := filepath.Join(f.dir, filepath.FromSlash(quarantineKey(snapshot)))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), snapshot.Page, 0o644); err != nil {
+		return fmt.Errorf("failed to write quarantined page: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.json"), snapshot.Report, 0o644); err != nil {
+		return fmt.Errorf("failed to write quarantine report: %w", err)
+	}
+	return nil
+}
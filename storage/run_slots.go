@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const runSlotsFileName = "run_slots.json"
+
+// ErrRunSlotLost is returned when updating a slot another attempt took over.
+var ErrRunSlotLost = errors.New("run slot held by another attempt")
+
+// RunSlot is the schedule slot of the ETL, claimed by the attempt running it.
+// A slot is claimed again only by a retry after an attempt failed or died,
+// which skips the steps already done.
+type RunSlot struct {
+	// Slot is the start of the slot, in Unix seconds
+	Slot int64 `json:"slot" dynamodbav:"Slot"`
+	// RunId is the attempt holding the slot, or the last one that did
+	RunId    string `json:"runId" dynamodbav:"RunId"`
+	Attempts int    `json:"attempts" dynamodbav:"Attempts"`
+	// Steps are the writes done by the attempts of the slot
+	Steps     []string `json:"steps" dynamodbav:"Steps"`
+	Completed bool     `json:"completed" dynamodbav:"Completed"`
+	// LeaseExpiresAt is when an attempt that died holding the slot can be
+	// taken over, 0 once the attempt ended
+	LeaseExpiresAt int64 `json:"leaseExpiresAt" dynamodbav:"LeaseExpiresAt"`
+	// ExpiresAt is the DynamoDB TTL of the row, once no event of the slot can be delivered again
+	ExpiresAt int64 `json:"expiresAt,omitempty" dynamodbav:"ExpiresAt,omitempty"`
+}
+
+// HasStep tells if an attempt did the step.
+func (s RunSlot) HasStep(step string) bool {
+	return slices.Contains(s.Steps, step)
+}
+
+// claimable tells if a new attempt can take the slot at now.
+func (s RunSlot) claimable(now int64) bool {
+	return !s.Completed && s.LeaseExpiresAt < now
+}
+
+// DynamoRunSlotRepository stores the slots keyed by Slot, claims are
+// conditional writes so two deliveries of a schedule event never both run.
+type DynamoRunSlotRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoRunSlotRepository(client *dynamodb.Client, tableName string) *DynamoRunSlotRepository {
+	return &DynamoRunSlotRepository{client: client, tableName: tableName}
+}
+
+func (r *DynamoRunSlotRepository) ClaimRunSlot(ctx context.Context, claim RunSlot, now int64) (RunSlot, bool, error) {
+	output, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"Slot": &types.AttributeValueMemberN{Value: strconv.FormatInt(claim.Slot, 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(Slot) OR (Completed = :false AND LeaseExpiresAt < :now)"),
+		UpdateExpression: aws.String("SET RunId = :runId, LeaseExpiresAt = :lease, ExpiresAt = :expiresAt, " +
+			"Completed = :false, Steps = if_not_exists(Steps, :noSteps) ADD Attempts :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":false":     &types.AttributeValueMemberBOOL{Value: false},
+			":now":       &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+			":runId":     &types.AttributeValueMemberS{Value: claim.RunId},
+			":lease":     &types.AttributeValueMemberN{Value: strconv.FormatInt(claim.LeaseExpiresAt, 10)},
+			":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(claim.ExpiresAt, 10)},
+			":noSteps":   &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":one":       &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		var existing RunSlot
+		if err := attributevalue.UnmarshalMap(conditionErr.Item, &existing); err != nil {
+			return RunSlot{}, false, fmt.Errorf("failed to unmarshal run slot: %w", err)
+		}
+		return existing, false, nil
+	}
+	if err != nil {
+		return RunSlot{}, false, fmt.Errorf("failed to claim run slot %d: %w", claim.Slot, err)
+	}
+
+	var claimed RunSlot
+	if err := attributevalue.UnmarshalMap(output.Attributes, &claimed); err != nil {
+		return RunSlot{}, false, fmt.Errorf("failed to unmarshal run slot: %w", err)
+	}
+	return claimed, true, nil
+}
+
+func (r *DynamoRunSlotRepository) UpdateRunSlot(ctx context.Context, slot RunSlot) error {
+	item, err := attributevalue.MarshalMap(slot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run slot: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("RunId = :runId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":runId": &types.AttributeValueMemberS{Value: slot.RunId},
+		},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return fmt.Errorf("%w: slot %d", ErrRunSlotLost, slot.Slot)
+	}
+	return err
+}
+
+func (r *DynamoRunSlotRepository) AddRunSlotStep(ctx context.Context, slot RunSlot, step string) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"Slot": &types.AttributeValueMemberN{Value: strconv.FormatInt(slot.Slot, 10)},
+		},
+		ConditionExpression: aws.String("RunId = :runId"),
+		UpdateExpression:    aws.String("SET Steps = list_append(if_not_exists(Steps, :noSteps), :step)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":runId":   &types.AttributeValueMemberS{Value: slot.RunId},
+			":noSteps": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":step":    &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: step}}},
+		},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return fmt.Errorf("%w: slot %d", ErrRunSlotLost, slot.Slot)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record step %s of run slot %d: %w", step, slot.Slot, err)
+	}
+	return nil
+}
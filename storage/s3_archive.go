@@ -1,4 +1,4 @@
-package main
+package storage
 
 import (
 	"compress/gzip"
@@ -13,11 +13,8 @@ import (
 	"time"
 
 	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
-	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/tracing"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"golang.org/x/sync/errgroup"
@@ -25,22 +22,56 @@ import (
 
 var ErrDayBackupNotFound = errors.New("day backup not found")
 
-// Handler processes EventBridge schedule rule events
-func Handler(ctx context.Context, event events.CloudWatchEvent) error {
+// firstStreamBackupDay is the first day folder of the stream backups, the
+// statuses before it are in the full table export.
+const firstStreamBackupDay = "2025-11-03"
+
+// S3StatusArchive reads the status history from the daily folders written by the
+// DynamoDB stream backup function, and from the full table export for the days
+// before the stream backups started.
+type S3StatusArchive struct {
+	client *s3.Client
+	bucket string
+	// missingDays are the days the last listing found no backup for
+	missingDays []string
+}
+
+func NewS3StatusArchive(client *s3.Client, bucket string) *S3StatusArchive {
+	return &S3StatusArchive{client: client, bucket: bucket}
+}
+
+type BackupRecord struct {
+	Timestamp float64 `json:"timestamp"`
+	Item      struct {
+		IncidentText     string  `json:"IncidentText"`
+		Status           string  `json:"Status"`
+		IncidentType     string  `json:"IncidentType"`
+		GeoId            int64   `json:"GeoId"`
+		EstimatedFixDate int64   `json:"EstimatedFixDate"`
+		Latitude         float64 `json:"Latitude"`
+		Longitude        float64 `json:"Longitude"`
+		Timestamp        int64   `json:"Timestamp"`
+		Name             string  `json:"Name"`
+	} `json:"item"`
+}
 
-	const (
-		maxMissingBackupDays     = 3
-		lastDayWithDataInBackups = "2025-11-03"
-	)
+func (a *S3StatusArchive) ListStatusesSince(ctx context.Context, cutoffTimestamp time.Time) (statuses []scrapper.HeatingStationStatus, err error) {
+	ctx, span := tracing.Start(ctx, "archive.list_statuses")
+	defer func() {
+		span.SetAttribute("archive.num_rows", len(statuses))
+		span.SetAttribute("archive.num_missing_days", len(a.missingDays))
+		span.RecordError(err)
+		span.End()
+	}()
 
-	slog.Info("Starting rank stations processing...")
+	const maxMissingBackupDays = 3
 
 	currentDayTimestamp := time.Now()
-	cutoffTimestamp := time.Now().AddDate(-1, 0, 0)
 	dataset := make([]scrapper.HeatingStationStatus, 0, 24*2*1000*365)
 	missingBackupDays := 0
 	lastDayWithData := ""
 	currentDateStr := ""
+	a.missingDays = make([]string, 0)
 
 	for currentDayTimestamp.After(cutoffTimestamp) {
 
@@ -57,14 +88,15 @@ func Handler(ctx context.Context, event events.CloudWatchEvent) error {
 			break
 		}
 
-		err := appendDayBackupToDataset(ctx, &dataset, currentDateStr)
+		err = a.appendDayBackupToDataset(ctx, &dataset, currentDateStr)
 		if err != nil && err != ErrDayBackupNotFound {
-			return err
+			return nil, err
 		}
 
 		if err == ErrDayBackupNotFound {
 			slog.Warn("Day backup not found", "date", currentDateStr)
 			missingBackupDays++
+			a.missingDays = append(a.missingDays, currentDateStr)
 		} else {
 			lastDayWithData = currentDateStr
 			missingBackupDays = 0
@@ -77,12 +109,12 @@ func Handler(ctx context.Context, event events.CloudWatchEvent) error {
 	if currentDayTimestamp.After(cutoffTimestamp) {
 		slog.Info("The earliest data in s3 kinesis backup is earlier than one year ago, more data is required")
 		// if its because we reached the period before the automated backups
-		if lastDayWithData == lastDayWithDataInBackups {
+		if lastDayWithData == firstStreamBackupDay {
 			slog.Info("earliest date in s3 kinesis backup is right after the full db backup, reading the full db backup")
 			// we load the db backup file for the dates before
-			err := loadDDBBackup(ctx, &dataset, cutoffTimestamp)
+			err := a.loadDDBBackup(ctx, &dataset, cutoffTimestamp)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			// if we miss data and the backup was not next, it means we have a gap in the backups
 		} else {
@@ -90,41 +122,93 @@ func Handler(ctx context.Context, event events.CloudWatchEvent) error {
 				"A gap was detected in the kinesis stream backups that is larger than max allowed. We need a gap friendly algorithm",
 				"lastDayBeforeGap", lastDayWithData,
 			)
-			return errors.New("a gap in the stations status history was found, please implement a gap-friendly algorithm")
+			return nil, errors.New("a gap in the stations status history was found, please implement a gap-friendly algorithm")
 		}
 	}
 
-	slog.Info("Filtered stations by date, proceeding with incident computations", "numRows", len(dataset))
-	stationsIncidentStats := scrapper.ComputeIncidentStatistics(dataset)
+	return dataset, nil
+}
 
-	slog.Info("Incident statistics computed, writing to dynamodb", "numRows", len(stationsIncidentStats))
-	err := writeStationsIncidentStats(ctx, stationsIncidentStats)
+// ListStatusesBetween reads the day folders of the period only, and the full
+// table export when the period starts before the stream backups. A missing
+// day folder is reported instead of ending the read.
+func (a *S3StatusArchive) ListStatusesBetween(ctx context.Context, from, to time.Time) (statuses []scrapper.HeatingStationStatus, missingDays []string, err error) {
+	ctx, span := tracing.Start(ctx, "archive.list_statuses_between")
+	defer func() {
+		span.SetAttribute("archive.num_rows", len(statuses))
+		span.SetAttribute("archive.num_missing_days", len(missingDays))
+		span.RecordError(err)
+		span.End()
+	}()
+
+	firstStreamDay, err := time.Parse(time.DateOnly, firstStreamBackupDay)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	return nil
+	dataset := make([]scrapper.HeatingStationStatus, 0)
+	missingDays = make([]string, 0)
+	if from.Before(firstStreamDay) {
+		// the export ends the day before the first folder, the rows after it are read from the folders
+		if err := a.loadDDBBackup(ctx, &dataset, from); err != nil {
+			return nil, nil, err
+		}
+		exported := dataset[:0]
+		for _, status := range dataset {
+			if status.FetchTime < firstStreamDay.Unix() {
+				exported = append(exported, status)
+			}
+		}
+		dataset = exported
+	}
+
+	day := from.UTC().Truncate(24 * time.Hour)
+	if day.Before(firstStreamDay) {
+		day = firstStreamDay
+	}
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		dateStr := day.Format(time.DateOnly)
+		err := a.appendDayBackupToDataset(ctx, &dataset, dateStr)
+		if errors.Is(err, ErrDayBackupNotFound) {
+			slog.Warn("Day backup not found", "date", dateStr)
+			missingDays = append(missingDays, dateStr)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	statuses = make([]scrapper.HeatingStationStatus, 0, len(dataset))
+	for _, status := range dataset {
+		if status.FetchTime > from.Unix() && status.FetchTime <= to.Unix() {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, missingDays, nil
 }
 
-type BackupRecord struct {
-	Timestamp float64 `json:"timestamp"`
-	Item      struct {
-		IncidentText     string  `json:"IncidentText"`
-		Status           string  `json:"Status"`
-		IncidentType     string  `json:"IncidentType"`
-		GeoId            int64   `json:"GeoId"`
-		EstimatedFixDate int64   `json:"EstimatedFixDate"`
-		Latitude         float64 `json:"Latitude"`
-		Longitude        float64 `json:"Longitude"`
-		Timestamp        int64   `json:"Timestamp"`
-		Name             string  `json:"Name"`
-	} `json:"item"`
+// MissingDays returns the days the last ListStatusesSince call found no backup
+// folder for, the most recent first.
+func (a *S3StatusArchive) MissingDays() []string {
+	return a.missingDays
 }
 
-func appendDayBackupToDataset(ctx context.Context, dataset *[]scrapper.HeatingStationStatus, dateStr string) error {
+func (a *S3StatusArchive) appendDayBackupToDataset(ctx context.Context, dataset *[]scrapper.HeatingStationStatus, dateStr string) (err error) {
+	ctx, span := tracing.Start(ctx, "archive.day")
+	numRowsBefore := len(*dataset)
+	defer func() {
+		span.SetAttribute("archive.day", dateStr)
+		span.SetAttribute("archive.num_rows", len(*dataset)-numRowsBefore)
+		if err != ErrDayBackupNotFound {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
 	// Check if folder exists
-	result, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket:  aws.String(S3_BUCKET),
+	result, err := a.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(a.bucket),
 		Prefix:  aws.String(dateStr + "/"),
 		MaxKeys: aws.Int32(1),
 	})
@@ -138,8 +222,8 @@ func appendDayBackupToDataset(ctx context.Context, dataset *[]scrapper.HeatingSt
 	slog.Info("Listing objects in folder", "folderName", dateStr)
 
 	// List all .json.gz files in the folder
-	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
-		Bucket: aws.String(S3_BUCKET),
+	paginator := s3.NewListObjectsV2Paginator(a.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(a.bucket),
 		Prefix: aws.String(dateStr + "/"),
 	})
 
@@ -159,7 +243,7 @@ func appendDayBackupToDataset(ctx context.Context, dataset *[]scrapper.HeatingSt
 		errG, errCtx := errgroup.WithContext(ctx)
 		errG.Go(func() error {
 			for obj := range objChan {
-				records, err := processS3Object(errCtx, &obj)
+				records, err := a.processS3Object(errCtx, &obj)
 				if err != nil {
 					return err
 				}
@@ -196,10 +280,10 @@ func appendDayBackupToDataset(ctx context.Context, dataset *[]scrapper.HeatingSt
 	return nil
 }
 
-func processS3Object(ctx context.Context, obj *types.Object) ([]scrapper.HeatingStationStatus, error) {
+func (a *S3StatusArchive) processS3Object(ctx context.Context, obj *types.Object) ([]scrapper.HeatingStationStatus, error) {
 	// Download file
-	getResult, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(S3_BUCKET),
+	getResult, err := a.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
 		Key:    obj.Key,
 	})
 	if err != nil {
@@ -241,10 +325,16 @@ func processS3Object(ctx context.Context, obj *types.Object) ([]scrapper.Heating
 	return statuses, nil
 }
 
-func loadDDBBackup(ctx context.Context, dataset *[]scrapper.HeatingStationStatus, cutoffTime time.Time) error {
+func (a *S3StatusArchive) loadDDBBackup(ctx context.Context, dataset *[]scrapper.HeatingStationStatus, cutoffTime time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "archive.full_backup")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	// Fetch dynamodb_backup.csv.gz from S3 root
-	getResult, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(S3_BUCKET),
+	getResult, err := a.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
 		Key:    aws.String("dynamodb_backup.csv.gz"),
 	})
 	if err != nil {
@@ -318,27 +408,3 @@ func loadDDBBackup(ctx context.Context, dataset *[]scrapper.HeatingStationStatus
 
 	return nil
 }
-
-func writeStationsIncidentStats(ctx context.Context, stats []scrapper.StationIncidentStatsDbRow) error {
-
-	for _, stat := range stats {
-		item, err := attributevalue.MarshalMap(stat)
-		if err != nil {
-			return fmt.Errorf("failed to marshal station stats: %w", err)
-		}
-
-		_, err = dbClient.PutItem(ctx, &dynamodb.PutItemInput{
-			TableName: aws.String(DYNAMODB_TABLE_STATIONS),
-			Item:      item,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to write station stats to DynamoDB: %w", err)
-		}
-	}
-
-	return nil
-}
-
-func main() {
-	lambda.Start(Handler)
-}
@@ -0,0 +1,200 @@
+// Package storage defines the repositories the lambdas persist data with, and
+// their DynamoDB, embedded file and in-memory implementations.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+)
+
+var ErrBatchWriteFailed = errors.New("some items could not be written")
+
+// StationRepository stores the last known state of every heating station.
+type StationRepository interface {
+	ListStations(ctx context.Context) ([]scrapper.HeatingStation, error)
+	PutStations(ctx context.Context, stations []scrapper.HeatingStation) error
+}
+
+// StatusHistoryRepository stores the status samples of the stations.
+type StatusHistoryRepository interface {
+	PutStatuses(ctx context.Context, statuses []scrapper.HeatingStationStatus) error
+	// ListStationStatuses returns the most recent statuses of a station first.
+	ListStationStatuses(ctx context.Context, geoId int64, limit int) ([]scrapper.HeatingStationStatus, error)
+}
+
+// StatusRetentionRepository gives the status rows of a period, to check them
+// against the archive before they expire.
+type StatusRetentionRepository interface {
+	StatusHistoryRepository
+	// ListStationStatusesBetween returns the statuses of a station taken between from and to included, oldest first.
+	ListStationStatusesBetween(ctx context.Context, geoId int64, from, to time.Time) ([]scrapper.HeatingStationStatus, error)
+}
+
+// StationRollupRepository stores the daily summaries of the stations that
+// outlive the status rows.
+type StationRollupRepository interface {
+	PutRollups(ctx context.Context, rollups []scrapper.StationDayRollup) error
+	// ListStationRollups returns the rollups of a station between the from and to dates included, oldest first.
+	ListStationRollups(ctx context.Context, geoId int64, fromDate, toDate string) ([]scrapper.StationDayRollup, error)
+}
+
+// DayCountsRepository stores the per snapshot counts of stations by state.
+type DayCountsRepository interface {
+	PutCounts(ctx context.Context, counts scrapper.StationStatesCount) error
+	// ListCounts returns the counts taken between from and to included, oldest first.
+	ListCounts(ctx context.Context, from, to time.Time) ([]scrapper.StationStatesCount, error)
+	// LatestCounts returns the most recent counts, found is false when there are none.
+	LatestCounts(ctx context.Context) (counts scrapper.StationStatesCount, found bool, err error)
+}
+
+// IncidentStatsRepository stores the per station incident statistics.
+type IncidentStatsRepository interface {
+	PutIncidentStats(ctx context.Context, stats []scrapper.StationIncidentStatsDbRow) error
+	ListIncidentStats(ctx context.Context, city string) ([]scrapper.StationIncidentStatsDbRow, error)
+}
+
+// RunLedgerRepository stores a row per ETL run, successful or not.
+type RunLedgerRepository interface {
+	PutRun(ctx context.Context, run scrapper.EtlRun) error
+	// ListRuns returns the runs started between from and to included, oldest first.
+	ListRuns(ctx context.Context, from, to time.Time) ([]scrapper.EtlRun, error)
+}
+
+// IncidentRepository stores the incidents maintained by the ETL.
+type IncidentRepository interface {
+	PutIncidents(ctx context.Context, incidents []scrapper.Incident) error
+	// ListOpenIncidents returns the incidents without an end time, oldest first.
+	ListOpenIncidents(ctx context.Context) ([]scrapper.Incident, error)
+	// ListStationIncidents returns the most recent incidents of a station first.
+	ListStationIncidents(ctx context.Context, geoId int64, limit int) ([]scrapper.Incident, error)
+	// ListIncidentsSince returns the incidents open or closed since the cutoff, oldest first.
+	ListIncidentsSince(ctx context.Context, cutoff time.Time) ([]scrapper.Incident, error)
+}
+
+// FixDateReliabilityRepository stores how the estimated fix dates held up,
+// city-wide and per cause.
+type FixDateReliabilityRepository interface {
+	PutFixDateReliability(ctx context.Context, rows []scrapper.FixDateReliabilityDbRow) error
+	ListFixDateReliability(ctx context.Context, city string) ([]scrapper.FixDateReliabilityDbRow, error)
+	// DeleteFixDateReliability deletes the rows of the scopes of a city, such
+	// as the causes without incident left in the window.
+	DeleteFixDateReliability(ctx context.Context, city string, scopes []string) error
+}
+
+// StatusArchive gives the full status history since a date, which the
+// aggregator computes the incident statistics from.
+type StatusArchive interface {
+	ListStatusesSince(ctx context.Context, cutoff time.Time) ([]scrapper.HeatingStationStatus, error)
+}
+
+// DayArchive is a status archive kept in day folders, some of which can be
+// missing. MissingDays reports the ones the last listing did not find.
+type DayArchive interface {
+	StatusArchive
+	MissingDays() []string
+}
+
+// StatusRangeArchive gives the archived statuses of a bounded period. The days
+// of the period without backup are returned instead of failing the read, so
+// a period around an outage can still be read.
+type StatusRangeArchive interface {
+	// ListStatusesBetween returns the statuses fetched after from and until to
+	// included, and the UTC days of the period without backup, oldest first.
+	ListStatusesBetween(ctx context.Context, from, to time.Time) (statuses []scrapper.HeatingStationStatus, missingDays []string, err error)
+}
+
+// SnapshotQuarantine keeps the snapshots refused by the plausibility rules.
+type SnapshotQuarantine interface {
+	QuarantineSnapshot(ctx context.Context, snapshot QuarantinedSnapshot) error
+}
+
+// EventOutbox keeps the domain events of the written state until they are
+// delivered, so a failed delivery is retried by the next run.
+type EventOutbox interface {
+	PutOutboxEvents(ctx context.Context, events []OutboxEvent) error
+	// ListOutboxEvents returns up to limit pending events, oldest first.
+	ListOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
+	DeleteOutboxEvents(ctx context.Context, eventIds []string) error
+}
+
+// RunSlotRepository keys the scheduled ETL runs by their schedule slot.
+type RunSlotRepository interface {
+	// ClaimRunSlot takes the slot for the attempt of claim.RunId, unless it is
+	// completed or held by an attempt whose lease has not expired at now. It
+	// returns the slot as claimed with the steps of the earlier attempts, or
+	// as found with claimed false.
+	ClaimRunSlot(ctx context.Context, claim RunSlot, now int64) (slot RunSlot, claimed bool, err error)
+	// UpdateRunSlot records the steps of the attempt holding the slot, and
+	// returns ErrRunSlotLost when another attempt took the slot over.
+	UpdateRunSlot(ctx context.Context, slot RunSlot) error
+	// AddRunSlotStep records a step of the attempt holding the slot as soon as
+	// it is done, so an attempt killed before releasing the slot keeps it. It
+	// returns ErrRunSlotLost when another attempt took the slot over.
+	AddRunSlotStep(ctx context.Context, slot RunSlot, step string) error
+}
+
+// CurrentStateStore publishes the versioned documents of the current state of
+// the stations, behind a pointer to the latest one.
+type CurrentStateStore interface {
+	// PublishCurrentState writes the document under its version, then points
+	// to it. It returns ErrCurrentStateSuperseded when the pointer already
+	// names a more recent snapshot, and leaves it unchanged.
+	PublishCurrentState(ctx context.Context, state scrapper.CurrentState) error
+	// CurrentStatePointer returns the pointer, found is false before the first publication.
+	CurrentStatePointer(ctx context.Context) (pointer CurrentStatePointer, found bool, err error)
+	// GetCurrentState returns the document of a version, or ErrCurrentStateNotFound.
+	GetCurrentState(ctx context.Context, version string) (scrapper.CurrentState, error)
+}
+
+var (
+	_ StationRepository            = (*DynamoStationRepository)(nil)
+	_ StatusHistoryRepository      = (*DynamoStatusHistoryRepository)(nil)
+	_ StatusRetentionRepository    = (*DynamoStatusHistoryRepository)(nil)
+	_ StationRollupRepository      = (*DynamoStationRollupRepository)(nil)
+	_ DayCountsRepository          = (*DynamoDayCountsRepository)(nil)
+	_ IncidentStatsRepository      = (*DynamoIncidentStatsRepository)(nil)
+	_ RunLedgerRepository          = (*DynamoRunLedgerRepository)(nil)
+	_ IncidentRepository           = (*DynamoIncidentRepository)(nil)
+	_ FixDateReliabilityRepository = (*DynamoFixDateReliabilityRepository)(nil)
+	_ StatusArchive                = (*S3StatusArchive)(nil)
+	_ DayArchive                   = (*S3StatusArchive)(nil)
+	_ StatusRangeArchive           = (*S3StatusArchive)(nil)
+	_ SnapshotQuarantine           = (*S3SnapshotQuarantine)(nil)
+	_ EventOutbox                  = (*DynamoOutboxRepository)(nil)
+	_ RunSlotRepository            = (*DynamoRunSlotRepository)(nil)
+	_ CurrentStateStore            = (*S3CurrentStateStore)(nil)
+
+	_ StationRepository            = (*MemoryStore)(nil)
+	_ StatusHistoryRepository      = (*MemoryStore)(nil)
+	_ StatusRetentionRepository    = (*MemoryStore)(nil)
+	_ StationRollupRepository      = (*MemoryStore)(nil)
+	_ DayCountsRepository          = (*MemoryStore)(nil)
+	_ IncidentStatsRepository      = (*MemoryStore)(nil)
+	_ RunLedgerRepository          = (*MemoryStore)(nil)
+	_ IncidentRepository           = (*MemoryStore)(nil)
+	_ FixDateReliabilityRepository = (*MemoryStore)(nil)
+	_ StatusArchive                = (*MemoryStore)(nil)
+	_ StatusRangeArchive           = (*MemoryStore)(nil)
+	_ EventOutbox                  = (*MemoryStore)(nil)
+	_ RunSlotRepository            = (*MemoryStore)(nil)
+	_ CurrentStateStore            = (*MemoryStore)(nil)
+
+	_ StationRepository            = (*FileStore)(nil)
+	_ StatusHistoryRepository      = (*FileStore)(nil)
+	_ StatusRetentionRepository    = (*FileStore)(nil)
+	_ StationRollupRepository      = (*FileStore)(nil)
+	_ DayCountsRepository          = (*FileStore)(nil)
+	_ IncidentStatsRepository      = (*FileStore)(nil)
+	_ RunLedgerRepository          = (*FileStore)(nil)
+	_ IncidentRepository           = (*FileStore)(nil)
+	_ FixDateReliabilityRepository = (*FileStore)(nil)
+	_ StatusArchive                = (*FileStore)(nil)
+	_ StatusRangeArchive           = (*FileStore)(nil)
+	_ SnapshotQuarantine           = (*FileStore)(nil)
+	_ EventOutbox                  = (*FileStore)(nil)
+	_ RunSlotRepository            = (*FileStore)(nil)
+	_ CurrentStateStore            = (*FileStore)(nil)
+)
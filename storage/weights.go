@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/QuentinFAIDIDE/bucuresti-termoficare-collecter/scrapper"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// LoadFileStationWeights loads the station weights table at the key path under
+// the storage directory, or the table built into the binary without key.
+func LoadFileStationWeights(dir, key string, defaultWeight float64) (*scrapper.StationWeights, error) {
+	if key == "" {
+		return loadDefaultStationWeights(defaultWeight)
+	}
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open station weights %s: %w", key, err)
+	}
+	defer file.Close()
+	return readStationWeights(file, key, defaultWeight)
+}
+
+// LoadS3StationWeights loads the station weights table at key in the bucket,
+// or the table built into the binary without key.
+func LoadS3StationWeights(ctx context.Context, client *s3.Client, bucket, key string, defaultWeight float64) (*scrapper.StationWeights, error) {
+	if key == "" {
+		return loadDefaultStationWeights(defaultWeight)
+	}
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download station weights %s: %w", key, err)
+	}
+	defer output.Body.Close()
+	return readStationWeights(output.Body, key, defaultWeight)
+}
+
+// readStationWeights refuses a configured table without rows, which would
+// weight every station the same.
+func readStationWeights(r io.Reader, key string, defaultWeight float64) (*scrapper.StationWeights, error) {
+	weights, err := scrapper.LoadStationWeights(r, defaultWeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load station weights %s: %w", key, err)
+	}
+	if weights.NumStations() == 0 {
+		return nil, fmt.Errorf("station weights %s has no rows", key)
+	}
+	slog.Info("Station weights loaded", "key", key, "numStations", weights.NumStations())
+	return weights, nil
+}
+
+func loadDefaultStationWeights(defaultWeight float64) (*scrapper.StationWeights, error) {
+	weights, err := scrapper.LoadDefaultStationWeights(defaultWeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the built-in station weights: %w", err)
+	}
+	if weights.NumStations() == 0 {
+		slog.Warn("No station weights table configured, every station gets the default weight",
+			"defaultWeight", defaultWeight)
+	}
+	return weights, nil
+}
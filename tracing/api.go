@@ -0,0 +1,32 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// APIHandler is the handler of an API Gateway proxy lambda.
+type APIHandler = func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// WrapAPIHandler runs every request of an endpoint in an invocation span
+// continuing the trace of the request, and flushes the spans before answering.
+func WrapAPIHandler(endpoint string, handler APIHandler) APIHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ctx, span := StartInvocation(ContextFromAPIRequest(ctx, request), "api."+endpoint)
+		span.SetAttribute("http.method", request.HTTPMethod)
+		span.SetAttribute("http.route", request.Resource)
+		span.SetAttribute("aws.request_id", request.RequestContext.RequestID)
+
+		response, err := handler(ctx, request)
+		span.SetAttribute("http.status_code", response.StatusCode)
+		span.RecordError(err)
+		if err == nil && response.StatusCode >= 500 {
+			span.RecordError(fmt.Errorf("status %d", response.StatusCode))
+		}
+		span.End()
+		Flush(ctx)
+		return response, err
+	}
+}
@@ -0,0 +1,226 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Exporter sends the ended spans of a service somewhere.
+type Exporter interface {
+	ExportSpans(ctx context.Context, serviceName string, spans []SpanData) error
+}
+
+// Exporter kinds, as set in the TRACING_EXPORTER environment variable.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// NewExporter returns the exporter of a kind, the OTLP one posts to the endpoint.
+func NewExporter(kind string, writer io.Writer, endpoint string) (Exporter, error) {
+	switch kind {
+	case "", ExporterNone:
+		return NoopExporter{}, nil
+	case ExporterStdout:
+		return NewWriterExporter(writer), nil
+	case ExporterOTLP:
+		if endpoint == "" {
+			return nil, fmt.Errorf("the %s exporter needs an endpoint", ExporterOTLP)
+		}
+		return NewOTLPHTTPExporter(endpoint, http.DefaultClient), nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", kind)
+	}
+}
+
+// NoopExporter drops the spans, the tracer does not even keep them.
+type NoopExporter struct{}
+
+func (NoopExporter) ExportSpans(ctx context.Context, serviceName string, spans []SpanData) error {
+	return nil
+}
+
+// WriterExporter writes a JSON line per span, usually to stdout.
+type WriterExporter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterExporter(writer io.Writer) *WriterExporter {
+	return &WriterExporter{writer: writer}
+}
+
+type writerSpan struct {
+	Service string `json:"service"`
+	SpanData
+	DurationMs float64 `json:"durationMs"`
+}
+
+func (e *WriterExporter) ExportSpans(ctx context.Context, serviceName string, spans []SpanData) error {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	for _, span := range spans {
+		err := encoder.Encode(writerSpan{
+			Service:    serviceName,
+			SpanData:   span,
+			DurationMs: float64(span.End.Sub(span.Start).Microseconds()) / 1000.0,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	_, err := e.writer.Write(buffer.Bytes())
+	return err
+}
+
+// OTLPHTTPExporter posts the spans to an OpenTelemetry collector, in the JSON
+// encoding of the OTLP/HTTP protocol.
+type OTLPHTTPExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewOTLPHTTPExporter returns an exporter posting to the traces path of the
+// endpoint, like http://localhost:4318.
+func NewOTLPHTTPExporter(endpoint string, client *http.Client) *OTLPHTTPExporter {
+	return &OTLPHTTPExporter{url: strings.TrimSuffix(endpoint, "/") + "/v1/traces", client: client}
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOk         = 1
+	otlpStatusError      = 2
+)
+
+func newOTLPAttribute(key string, value any) otlpAttribute {
+	attribute := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case string:
+		attribute.Value.StringValue = &v
+	case bool:
+		attribute.Value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		attribute.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		attribute.Value.IntValue = &s
+	case float64:
+		attribute.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		attribute.Value.StringValue = &s
+	}
+	return attribute
+}
+
+func newOTLPRequest(serviceName string, spans []SpanData) otlpRequest {
+	scopeSpans := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(spans))}
+	scopeSpans.Scope.Name = "termoficare"
+	for _, span := range spans {
+		converted := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusOk},
+		}
+		for key, value := range span.Attributes {
+			converted.Attributes = append(converted.Attributes, newOTLPAttribute(key, value))
+		}
+		if span.Error != "" {
+			converted.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, converted)
+	}
+
+	resourceSpans := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scopeSpans}}
+	resourceSpans.Resource.Attributes = []otlpAttribute{newOTLPAttribute("service.name", serviceName)}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{resourceSpans}}
+}
+
+func (e *OTLPHTTPExporter) ExportSpans(ctx context.Context, serviceName string, spans []SpanData) error {
+	body, err := json.Marshal(newOTLPRequest(serviceName, spans))
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to post spans: %w", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("collector answered with status %d", response.StatusCode)
+	}
+	return nil
+}
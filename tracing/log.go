@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adds the trace_id and span_id of the span of the context to the
+// log lines, or the ones of the running invocation for lines logged without
+// a traced context.
+type LogHandler struct {
+	inner slog.Handler
+}
+
+func NewLogHandler(inner slog.Handler) *LogHandler {
+	return &LogHandler{inner: inner}
+}
+
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		span = globalTracer.Load().invocationSpan()
+	}
+	if span != nil {
+		spanContext := span.Context()
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID), slog.String("span_id", spanContext.SpanID))
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{inner: h.inner.WithGroup(name)}
+}
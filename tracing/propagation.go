@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ParseTraceparent reads a W3C traceparent header, like
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 {
+		return SpanContext{}, false
+	}
+	parent := SpanContext{TraceID: strings.ToLower(parts[1]), SpanID: strings.ToLower(parts[2])}
+	if !parent.IsValid() || !isHex(parent.TraceID) || !isHex(parent.SpanID) {
+		return SpanContext{}, false
+	}
+	return parent, true
+}
+
+// ParseAmznTraceID reads the X-Amzn-Trace-Id header API Gateway adds, like
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1.
+// The trace id is the root without its version and dashes, the parent is
+// optional.
+func ParseAmznTraceID(header string) (SpanContext, bool) {
+	parent := SpanContext{}
+	for _, field := range strings.Split(header, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "Root":
+			root := strings.Split(value, "-")
+			if len(root) == 3 {
+				parent.TraceID = strings.ToLower(root[1] + root[2])
+			}
+		case "Parent":
+			parent.SpanID = strings.ToLower(value)
+		}
+	}
+	if len(parent.TraceID) != 32 || !isHex(parent.TraceID) {
+		return SpanContext{}, false
+	}
+	if len(parent.SpanID) != 16 || !isHex(parent.SpanID) {
+		// the root span of the request has no parent span
+		parent.SpanID = ""
+	}
+	return parent, true
+}
+
+// ContextFromAPIRequest returns a context continuing the trace of the
+// request, from its traceparent header or the one API Gateway adds.
+func ContextFromAPIRequest(ctx context.Context, request events.APIGatewayProxyRequest) context.Context {
+	if parent, ok := ParseTraceparent(header(request, "traceparent")); ok {
+		return ContextWithRemoteParent(ctx, parent)
+	}
+	if parent, ok := ParseAmznTraceID(header(request, "x-amzn-trace-id")); ok {
+		return ContextWithRemoteParent(ctx, parent)
+	}
+	return ctx
+}
+
+// header returns a request header, API Gateway keeps the case the client sent.
+func header(request events.APIGatewayProxyRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// ContextFromEventID returns a context whose trace id is derived from the
+// EventBridge event id, so the trace of a scheduled run can be found from the
+// event. Event ids are UUIDs, their hex digits are used as the trace id.
+func ContextFromEventID(ctx context.Context, eventID string) context.Context {
+	if eventID == "" {
+		return ctx
+	}
+	traceID := strings.ToLower(strings.ReplaceAll(eventID, "-", ""))
+	if len(traceID) != 32 || !isHex(traceID) {
+		sum := sha256.Sum256([]byte(eventID))
+		traceID = hex.EncodeToString(sum[:16])
+	}
+	return ContextWithRemoteParent(ctx, SpanContext{TraceID: traceID})
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
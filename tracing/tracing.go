@@ -0,0 +1,223 @@
+// Package tracing records OpenTelemetry style spans around the stages of the
+// lambdas, and exports them at the end of every invocation. The trace of an
+// invocation continues the one of the API Gateway request, or is derived from
+// the EventBridge event id.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanContext identifies a span, in lowercase hex like the W3C trace context.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+func (c SpanContext) IsValid() bool {
+	return len(c.TraceID) == 32 && len(c.SpanID) == 16
+}
+
+// SpanData is an ended span, as given to the exporters.
+type SpanData struct {
+	Name         string         `json:"name"`
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	// Error is the message of the error the span ended with, empty on success
+	Error string `json:"error,omitempty"`
+}
+
+// Span is a stage of an invocation. It is safe for concurrent use.
+type Span struct {
+	tracer *Tracer
+	mutex  sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+func (s *Span) Context() SpanContext {
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID}
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+// RecordError marks the span as failed, a nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End ends the span and hands it to the tracer, ending it again does nothing.
+func (s *Span) End() {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mutex.Unlock()
+
+	s.tracer.finish(s, data)
+}
+
+// Tracer buffers the ended spans until they are flushed to its exporter.
+type Tracer struct {
+	serviceName string
+	exporter    Exporter
+	mutex       sync.Mutex
+	finished    []SpanData
+	// invocation is the root span of the running invocation, the parent of
+	// the log lines written without a traced context
+	invocation *Span
+}
+
+func NewTracer(serviceName string, exporter Exporter) *Tracer {
+	return &Tracer{serviceName: serviceName, exporter: exporter}
+}
+
+var globalTracer atomic.Pointer[Tracer]
+
+func init() {
+	globalTracer.Store(NewTracer("", NoopExporter{}))
+}
+
+// SetTracer sets the tracer the package functions use, a no-op one until then.
+func SetTracer(tracer *Tracer) {
+	globalTracer.Store(tracer)
+}
+
+type spanContextKey struct{}
+type remoteContextKey struct{}
+
+// ContextWithRemoteParent returns a context whose next span is a child of a
+// span of another process.
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey{}, parent)
+}
+
+// SpanFromContext returns the span of the context, nil without one.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// Start starts a span, child of the span of the context if any.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return globalTracer.Load().Start(ctx, name)
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	data := SpanData{Name: name, SpanID: newID(8), Start: time.Now()}
+	if parent := SpanFromContext(ctx); parent != nil {
+		data.TraceID = parent.data.TraceID
+		data.ParentSpanID = parent.data.SpanID
+	} else if remote, ok := ctx.Value(remoteContextKey{}).(SpanContext); ok {
+		data.TraceID = remote.TraceID
+		data.ParentSpanID = remote.SpanID
+	}
+	if len(data.TraceID) != 32 {
+		data.TraceID = newID(16)
+	}
+
+	span := &Span{tracer: t, data: data}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// StartInvocation starts the root span of a lambda invocation, the parent of
+// the log lines of the invocation written without its context.
+func StartInvocation(ctx context.Context, name string) (context.Context, *Span) {
+	tracer := globalTracer.Load()
+	ctx, span := tracer.Start(ctx, name)
+	tracer.mutex.Lock()
+	tracer.invocation = span
+	tracer.mutex.Unlock()
+	return ctx, span
+}
+
+func (t *Tracer) finish(span *Span, data SpanData) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.invocation == span {
+		t.invocation = nil
+	}
+	if _, noop := t.exporter.(NoopExporter); noop {
+		return
+	}
+	t.finished = append(t.finished, data)
+}
+
+func (t *Tracer) invocationSpan() *Span {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.invocation
+}
+
+// Flush exports the ended spans. A lambda is frozen once its handler
+// returned, so every invocation flushes before returning.
+func Flush(ctx context.Context) {
+	globalTracer.Load().Flush(ctx)
+}
+
+// Flush exports the ended spans, a failed export is logged and its spans dropped.
+func (t *Tracer) Flush(ctx context.Context) {
+	const flushTimeout = 5 * time.Second
+
+	t.mutex.Lock()
+	spans := t.finished
+	t.finished = nil
+	t.mutex.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	// spans are flushed even when the invocation ran out of time
+	flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+	defer cancel()
+	if err := t.exporter.ExportSpans(flushCtx, t.serviceName, spans); err != nil {
+		slog.Warn("Failed to export spans", "numSpans", len(spans), "error_msg", err.Error())
+	}
+}
+
+func newID(numBytes int) string {
+	id := make([]byte, numBytes)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Setup sets the tracer of the service, and the default logger whose lines
+// carry the trace and span ids. The exporter kind is one of the Exporter*
+// constants.
+func Setup(serviceName, exporterKind, otlpEndpoint string) error {
+	slog.SetDefault(slog.New(NewLogHandler(slog.NewTextHandler(os.Stderr, nil))))
+
+	exporter, err := NewExporter(exporterKind, os.Stdout, otlpEndpoint)
+	if err != nil {
+		return err
+	}
+	SetTracer(NewTracer(serviceName, exporter))
+	return nil
+}
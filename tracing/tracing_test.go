@@ -0,0 +1,231 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// recordingExporter keeps the exported spans.
+type recordingExporter struct {
+	spans []SpanData
+}
+
+func (e *recordingExporter) ExportSpans(ctx context.Context, serviceName string, spans []SpanData) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func useTracer(t *testing.T, exporter Exporter) {
+	t.Helper()
+	previous := globalTracer.Load()
+	SetTracer(NewTracer("test", exporter))
+	t.Cleanup(func() { SetTracer(previous) })
+}
+
+func TestSpans(t *testing.T) {
+	exporter := &recordingExporter{}
+	useTracer(t, exporter)
+
+	ctx := ContextFromEventID(context.Background(), "6a7e8feb-b491-4cf7-a9f1-bf3703467718")
+	ctx, root := StartInvocation(ctx, "invoke")
+	_, child := Start(ctx, "stage")
+	child.SetAttribute("rows", 3)
+	child.RecordError(errors.New("write failed"))
+	child.End()
+	child.End()
+	root.End()
+
+	// nothing is exported before the flush
+	if len(exporter.spans) != 0 {
+		t.Fatalf("spans exported before flush: %+v", exporter.spans)
+	}
+	Flush(ctx)
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(exporter.spans))
+	}
+	stage, invoke := exporter.spans[0], exporter.spans[1]
+	if invoke.TraceID != "6a7e8febb4914cf7a9f1bf3703467718" || invoke.ParentSpanID != "" {
+		t.Errorf("invocation span = %+v, want the trace id of the event", invoke)
+	}
+	if stage.TraceID != invoke.TraceID || stage.ParentSpanID != invoke.SpanID {
+		t.Errorf("stage span = %+v, want a child of %s", stage, invoke.SpanID)
+	}
+	if stage.Error != "write failed" || stage.Attributes["rows"] != 3 {
+		t.Errorf("stage span = %+v", stage)
+	}
+}
+
+func TestNoopExporterKeepsNothing(t *testing.T) {
+	useTracer(t, NoopExporter{})
+	_, span := Start(context.Background(), "stage")
+	span.End()
+	if tracer := globalTracer.Load(); len(tracer.finished) != 0 {
+		t.Errorf("noop tracer kept %d spans", len(tracer.finished))
+	}
+}
+
+func TestParseTraceHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		parse  func(string) (SpanContext, bool)
+		header string
+		want   SpanContext
+		wantOk bool
+	}{
+		{
+			name:   "traceparent",
+			parse:  ParseTraceparent,
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:   SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"},
+			wantOk: true,
+		},
+		{name: "traceparent too short", parse: ParseTraceparent, header: "00-4bf92f35-00f067aa0ba902b7-01"},
+		{name: "traceparent not hex", parse: ParseTraceparent, header: "00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{
+			name:   "amzn trace id with parent",
+			parse:  ParseAmznTraceID,
+			header: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+			want:   SpanContext{TraceID: "5759e988bd862e3fe1be46a994272793", SpanID: "53995c3f42cd8ad8"},
+			wantOk: true,
+		},
+		{
+			name:   "amzn trace id without parent",
+			parse:  ParseAmznTraceID,
+			header: "Root=1-5759e988-bd862e3fe1be46a994272793",
+			want:   SpanContext{TraceID: "5759e988bd862e3fe1be46a994272793"},
+			wantOk: true,
+		},
+		{name: "amzn trace id without root", parse: ParseAmznTraceID, header: "Parent=53995c3f42cd8ad8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.parse(tt.header)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parse(%q) = %+v, %v, want %+v, %v", tt.header, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestWrapAPIHandler(t *testing.T) {
+	exporter := &recordingExporter{}
+	useTracer(t, exporter)
+
+	var handlerSpan *Span
+	handler := WrapAPIHandler("counts", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		handlerSpan = SpanFromContext(ctx)
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	})
+	_, err := handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Headers:    map[string]string{"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793"},
+	})
+	if err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+
+	if handlerSpan == nil {
+		t.Fatal("handler context has no span")
+	}
+	if len(exporter.spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(exporter.spans))
+	}
+	span := exporter.spans[0]
+	if span.Name != "api.counts" || span.TraceID != "5759e988bd862e3fe1be46a994272793" || span.Error == "" {
+		t.Errorf("span = %+v", span)
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	useTracer(t, NoopExporter{})
+	buffer := &bytes.Buffer{}
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(buffer, nil)))
+
+	ctx, invocation := StartInvocation(context.Background(), "invoke")
+	stageCtx, stage := Start(ctx, "stage")
+
+	logger.InfoContext(stageCtx, "with context")
+	logger.Info("without context")
+	invocation.End()
+	logger.Info("after invocation")
+	stage.End()
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	wantSpanIDs := []string{stage.Context().SpanID, invocation.Context().SpanID, ""}
+	for i, line := range lines {
+		decoded := make(map[string]any)
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		spanID, _ := decoded["span_id"].(string)
+		if spanID != wantSpanIDs[i] {
+			t.Errorf("line %d span_id = %q, want %q", i, spanID, wantSpanIDs[i])
+		}
+	}
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	var received otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPHTTPExporter(server.URL+"/", server.Client())
+	err := exporter.ExportSpans(context.Background(), "etl", []SpanData{{
+		Name:       "etl.fetch",
+		TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:     "00f067aa0ba902b7",
+		Attributes: map[string]any{"http.status_code": 200},
+		Error:      "timeout",
+	}})
+	if err != nil {
+		t.Fatalf("ExportSpans() error = %v", err)
+	}
+
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("received = %+v", received)
+	}
+	if service := received.ResourceSpans[0].Resource.Attributes[0]; *service.Value.StringValue != "etl" {
+		t.Errorf("service.name = %+v", service)
+	}
+	span := received.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if span.Name != "etl.fetch" || span.Status.Code != otlpStatusError || *span.Attributes[0].Value.IntValue != "200" {
+		t.Errorf("span = %+v", span)
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	if _, err := NewExporter(ExporterOTLP, io.Discard, ""); err == nil {
+		t.Error("NewExporter(otlp) without endpoint succeeded")
+	}
+	if _, err := NewExporter("zipkin", io.Discard, ""); err == nil {
+		t.Error("NewExporter(zipkin) succeeded")
+	}
+	exporter, err := NewExporter(ExporterStdout, io.Discard, "")
+	if _, ok := exporter.(*WriterExporter); err != nil || !ok {
+		t.Errorf("NewExporter(stdout) = %T, %v", exporter, err)
+	}
+}